@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Can/sysrow/pkg/config"
+	"github.com/Can/sysrow/pkg/group"
+	"github.com/Can/sysrow/pkg/logger"
+	"github.com/Can/sysrow/pkg/output"
+	"github.com/Can/sysrow/pkg/recurring"
+	"github.com/Can/sysrow/pkg/runner"
+	"github.com/Can/sysrow/pkg/schema"
+	"github.com/Can/sysrow/pkg/storage"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// Exit codes of sysrow that scripts can rely on
+const (
+	exitOK = 0
+	// exitError is used for failures without a more specific code
+	exitError = 1
+	// exitUsage means the arguments were invalid, including an ambiguous task ID
+	// prefix. The flag package uses it as well.
+	exitUsage = 2
+	// exitNotFound means a task, group, group run or recurring task does not exist
+	exitNotFound = 3
+	// exitTaskFailed means a task or group run the command waited for did not succeed
+	exitTaskFailed = 4
+)
+
+// app holds what every command works with. It is built once from the data
+// directory and configuration, and the command handlers are its methods.
+type app struct {
+	dataDir   string
+	config    *config.Config
+	store     task.Store
+	runner    *runner.Runner
+	groups    *group.GroupManager
+	recurring *recurring.Manager
+	logger    *logger.Logger
+	// output prints results in the format chosen with --output and --format
+	output *output.Printer
+}
+
+// newApp prepares the data directory, upgrading files written by an older
+// sysrow, opens the configured task store and builds the shared components
+func newApp() (*app, error) {
+	if err := task.InitializeDataDirectory(); err != nil {
+		return nil, err
+	}
+	dataDir := task.DataDirectory
+
+	// Upgrade files written by an older sysrow, refuse files of a newer one
+	upgraded, err := schema.Upgrade(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade data directory: %w", err)
+	}
+	if upgraded != nil {
+		fmt.Fprintf(os.Stderr, "Not: Veri dizini şema sürümü yükseltildi (%d -> %d), eski dosyaların yedeği: %s\n", upgraded.From, upgraded.To, upgraded.Backup)
+		for _, warning := range upgraded.Warnings {
+			fmt.Fprintf(os.Stderr, "Uyarı: %s\n", warning)
+		}
+	}
+
+	cfg, err := config.Load(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open the task storage backend chosen in config.json
+	store, err := storage.Open(dataDir, cfg.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open task storage: %w", err)
+	}
+	task.SetStore(store)
+
+	grace, err := cfg.KillGracePeriod()
+	if err != nil {
+		return nil, err
+	}
+	r := runner.NewRunner(dataDir, store)
+	r.KillGrace = grace
+
+	return &app{
+		dataDir:   dataDir,
+		config:    cfg,
+		store:     store,
+		runner:    r,
+		groups:    group.NewGroupManager(dataDir, store),
+		recurring: recurring.NewManager(dataDir),
+		logger:    logger.NewLogger(dataDir),
+	}, nil
+}
+
+// exitCode returns the exit code for an error reported by a command
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, task.ErrNotFound), errors.Is(err, group.ErrNotFound), errors.Is(err, recurring.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, task.ErrAmbiguous):
+		return exitUsage
+	default:
+		return exitError
+	}
+}
+
+// resolveTask returns the task a command line argument refers to by its ID,
+// an ID prefix, its name, latest or last-failed, and exits if there is none
+func (a *app) resolveTask(ref string) *task.Task {
+	t, err := task.Resolve(a.store, ref)
+	if err != nil {
+		if errors.Is(err, task.ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "Hata: Görev bulunamadı: %s\n", ref)
+		} else {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		}
+		os.Exit(exitCode(err))
+	}
+
+	return t
+}
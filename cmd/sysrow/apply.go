@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Can/sysrow/pkg/group"
+	"github.com/Can/sysrow/pkg/spec"
+)
+
+// parseSpecFile reads the -f option of apply and diff and parses the file
+func parseSpecFile(name string, args []string) []*group.Group {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	file := flags.String("f", "", "Grupları tanımlayan dosya")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if *file == "" && len(positional) > 0 {
+		*file = positional[0]
+	}
+	if *file == "" {
+		fmt.Println("Hata: Dosya belirtilmedi")
+		fmt.Printf("Kullanım: sysrow %s -f <dosya>\n", name)
+		os.Exit(exitUsage)
+	}
+
+	groups, err := spec.ParseFile(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	return groups
+}
+
+func (a *app) handleApplyCommand(args []string) {
+	groups := parseSpecFile("apply", args)
+	gm := a.groups
+
+	changed := 0
+	for _, g := range groups {
+		changes, err := gm.Apply(g)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+		if len(changes) == 0 {
+			fmt.Printf("= group %s (değişiklik yok)\n", g.Name)
+			continue
+		}
+		changed++
+		printChanges(changes)
+	}
+
+	fmt.Printf("\n%d grup güncellendi, %d grup değişmedi\n", changed, len(groups)-changed)
+}
+
+func (a *app) handleDiffCommand(args []string) {
+	groups := parseSpecFile("diff", args)
+	gm := a.groups
+
+	changed := 0
+	for _, g := range groups {
+		current, err := gm.Lookup(g.Name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+		if changes := group.Diff(current, g); len(changes) > 0 {
+			changed++
+			printChanges(changes)
+		}
+	}
+
+	if changed == 0 {
+		fmt.Println("Değişiklik yok, gruplar dosyayla aynı")
+		return
+	}
+
+	fmt.Printf("\n%d grup değişecek. Uygulamak için: sysrow apply -f <dosya>\n", changed)
+}
+
+// printChanges prints the change lines of a single group
+func printChanges(changes []string) {
+	for _, line := range changes {
+		fmt.Println(line)
+	}
+}
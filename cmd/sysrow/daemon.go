@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Can/sysrow/pkg/config"
+	"github.com/Can/sysrow/pkg/daemon"
+)
+
+// daemonStatus is what `sysrow daemon status` prints for scripts
+type daemonStatus struct {
+	Running bool `json:"running"`
+	PID     int  `json:"pid,omitempty"`
+}
+
+func (a *app) handleDaemonCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Hata: Alt komut belirtilmedi")
+		fmt.Println("Kullanım: sysrow daemon <start|stop|status|run> [--workers=<n>] [--reserved-high=<n>] [--stop-timeout=<süre>]")
+		os.Exit(exitUsage)
+	}
+
+	switch args[0] {
+	case "start":
+		// Validate the flags here so mistakes are reported to the user, not the daemon log
+		if _, err := a.daemonConfig(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitUsage)
+		}
+
+		pid, err := daemon.Start(a.dataDir, args[1:]...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("Daemon başlatıldı (PID %d)\n", pid)
+	case "stop":
+		pid, err := daemon.Stop(a.dataDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("Daemon durduruluyor (PID %d), çalışan görevler tamamlanınca kapanacak. Süre aşımında (stop_timeout) hâlâ çalışanlar iptal edilir\n", pid)
+	case "status":
+		pid, running := daemon.Status(a.dataDir)
+		switch {
+		case a.output.Machine():
+			status := daemonStatus{Running: running}
+			if running {
+				status.PID = pid
+			}
+			printOutput(a.output, status)
+		case running:
+			fmt.Printf("Daemon çalışıyor (PID %d)\n", pid)
+		default:
+			fmt.Println("Daemon çalışmıyor")
+		}
+		if !running {
+			os.Exit(exitError)
+		}
+	case "run":
+		// Foreground mode, used by `daemon start` and by service managers such as systemd
+		log.SetFlags(log.LstdFlags)
+		cfg, err := a.daemonConfig(args[1:])
+		if err != nil {
+			log.Printf("daemon error: %v", err)
+			os.Exit(exitError)
+		}
+
+		d, err := daemon.NewDaemon(a.dataDir, a.store, cfg)
+		if err == nil {
+			err = d.Run()
+		}
+		if err != nil {
+			log.Printf("daemon error: %v", err)
+			os.Exit(exitError)
+		}
+	default:
+		fmt.Printf("Bilinmeyen alt komut: %s\n", args[0])
+		fmt.Println("Kullanım: sysrow daemon <start|stop|status|run> [--workers=<n>] [--reserved-high=<n>] [--stop-timeout=<süre>]")
+		os.Exit(exitUsage)
+	}
+}
+
+// daemonConfig applies the daemon command line overrides to a copy of config.json
+func (a *app) daemonConfig(args []string) (*config.Config, error) {
+	copied := *a.config
+	cfg := &copied
+
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	flags.IntVar(&cfg.Workers, "workers", cfg.Workers, "Aynı anda çalışabilecek en fazla görev sayısı")
+	flags.IntVar(&cfg.ReservedHighSlots, "reserved-high", cfg.ReservedHighSlots, "Yalnızca yüksek öncelikli görevlere ayrılan slot sayısı")
+	flags.StringVar(&cfg.StopTimeout, "stop-timeout", cfg.StopTimeout, "Durdurulurken çalışan görevlerin bitmesi için beklenecek süre, sonra iptal edilirler")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// warnIfDaemonStopped reminds the user that queued tasks only run while the daemon is up
+func (a *app) warnIfDaemonStopped() {
+	if _, running := daemon.Status(a.dataDir); !running {
+		fmt.Println("Not: Daemon çalışmıyor. Görevlerin işlenmesi için 'sysrow daemon start' komutunu çalıştırın.")
+	}
+}
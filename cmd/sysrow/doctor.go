@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Can/sysrow/pkg/daemon"
+	"github.com/Can/sysrow/pkg/recovery"
+	"github.com/Can/sysrow/pkg/schema"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// doctorReport is what `sysrow doctor` found, printed as is for scripts
+type doctorReport struct {
+	DataDir       string        `json:"data_dir"`
+	Storage       string        `json:"storage"`
+	SchemaVersion int           `json:"schema_version"`
+	Daemon        daemonStatus  `json:"daemon"`
+	Quarantined   []quarantined `json:"quarantined"`
+	// Running is the number of tasks recorded as running that were checked
+	Running int        `json:"running"`
+	Lost    []lostTask `json:"lost"`
+	// TotalLost counts the lost tasks, including those marked earlier
+	TotalLost int `json:"total_lost"`
+	// CorruptFiles counts the files in the corrupt directory, quarantined
+	// now or earlier
+	CorruptFiles int    `json:"corrupt_files"`
+	CorruptDir   string `json:"corrupt_dir"`
+}
+
+// quarantined is a corrupt file that was moved out of the way
+type quarantined struct {
+	Path    string `json:"path"`
+	MovedTo string `json:"moved_to"`
+	Reason  string `json:"reason"`
+}
+
+// lostTask is a task doctor marked as lost
+type lostTask struct {
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	Reason  string `json:"reason"`
+}
+
+func (a *app) handleDoctorCommand(args []string) {
+	report := &doctorReport{
+		DataDir:     a.dataDir,
+		Storage:     a.config.Storage,
+		Quarantined: make([]quarantined, 0),
+		Lost:        make([]lostTask, 0),
+		CorruptDir:  filepath.Join(a.dataDir, "corrupt"),
+	}
+	if version, err := schema.DataVersion(a.dataDir); err == nil {
+		report.SchemaVersion = version
+	}
+	if pid, running := daemon.Status(a.dataDir); running {
+		report.Daemon = daemonStatus{Running: true, PID: pid}
+	}
+
+	corrupt, err := recovery.Quarantine(a.dataDir)
+	for _, c := range corrupt {
+		report.Quarantined = append(report.Quarantined, quarantined{Path: c.Path, MovedTo: c.MovedTo, Reason: c.Reason})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Bozuk dosyalar kontrol edilemedi: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	reconciled, err := recovery.Reconcile(a.dataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Görevler kontrol edilemedi: %v\n", err)
+		os.Exit(exitError)
+	}
+	report.Running = reconciled.Checked
+	for _, t := range reconciled.Lost {
+		report.Lost = append(report.Lost, lostTask{ID: t.ID, Command: t.Command, Reason: reconciled.Reasons[t.ID]})
+	}
+
+	// Tasks marked as lost earlier, e.g. automatically on a previous start
+	lost, err := task.FindTasks(a.store, task.Filter{Statuses: []task.TaskStatus{task.StatusLost}})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Görevler listelenemedi: %v\n", err)
+		os.Exit(exitError)
+	}
+	report.TotalLost = len(lost)
+
+	// Files quarantined now or earlier, kept for manual inspection
+	filepath.WalkDir(report.CorruptDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			report.CorruptFiles++
+		}
+		return nil
+	})
+
+	a.printDoctorReport(report)
+}
+
+// printDoctorReport prints what doctor found
+func (a *app) printDoctorReport(report *doctorReport) {
+	if a.output.Machine() {
+		printOutput(a.output, report)
+		return
+	}
+
+	fmt.Printf("Veri dizini: %s\n", report.DataDir)
+	fmt.Printf("Depolama: %s\n", report.Storage)
+	if report.SchemaVersion > 0 {
+		fmt.Printf("Şema sürümü: %d\n", report.SchemaVersion)
+	}
+
+	if report.Daemon.Running {
+		fmt.Printf("Daemon: çalışıyor (PID %d)\n", report.Daemon.PID)
+	} else {
+		fmt.Println("Daemon: çalışmıyor")
+	}
+
+	if len(report.Quarantined) > 0 {
+		fmt.Printf("%d bozuk dosya karantinaya alındı:\n", len(report.Quarantined))
+		for _, c := range report.Quarantined {
+			fmt.Printf("  %s -> %s\n    %s\n", c.Path, c.MovedTo, c.Reason)
+		}
+	}
+
+	fmt.Printf("Çalışan görünen görev sayısı: %d\n", report.Running)
+	if len(report.Lost) == 0 {
+		fmt.Println("Yeni kayıp görev bulunamadı")
+	} else {
+		fmt.Printf("%d görev 'lost' olarak işaretlendi:\n", len(report.Lost))
+		for _, t := range report.Lost {
+			fmt.Printf("  %s  %s\n    %s\n", t.ID, t.Command, t.Reason)
+		}
+	}
+
+	fmt.Printf("Toplam kayıp görev sayısı: %d\n", report.TotalLost)
+	if report.CorruptFiles > 0 {
+		fmt.Printf("Karantinadaki bozuk dosya sayısı: %d (%s)\n", report.CorruptFiles, report.CorruptDir)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Can/sysrow/pkg/archive"
+	"github.com/Can/sysrow/pkg/fsutil"
+	"github.com/Can/sysrow/pkg/schedule"
+)
+
+func (a *app) handleExportCommand(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	since := flags.String("since", "", "Yalnızca bu tarihten sonra oluşturulan görevler (örn. 2026-01-01 veya 7d)")
+	format := flags.String("format", archive.FormatJSONL, "Çıktı biçimi (jsonl, csv, tar)")
+	output := flags.String("o", "", "Çıktı dosyası (varsayılan: standart çıktı)")
+
+	if _, err := parseFlags(flags, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if _, err := archive.ParseFormat(*format); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Kullanım: sysrow export [--since <tarih|süre>] [--format jsonl|csv|tar] [-o <dosya>]")
+		os.Exit(exitUsage)
+	}
+
+	var from time.Time
+	if *since != "" {
+		var err error
+		if from, err = schedule.ParseSince(*since, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+
+	// The export goes to stdout unless a file is given, messages go to stderr.
+	// A file only appears once the export is complete.
+	var count int
+	export := func(w io.Writer) error {
+		var err error
+		count, err = archive.Export(w, *format, a.dataDir, a.store, from)
+		return err
+	}
+
+	var err error
+	if *output != "" {
+		err = fsutil.WriteFileFrom(*output, 0644, export)
+	} else {
+		err = export(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Fprintf(os.Stderr, "%d görev dışa aktarıldı\n", count)
+}
+
+func (a *app) handleImportCommand(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "Girdi biçimi (jsonl, tar), varsayılan: dosya uzantısından")
+	conflict := flags.String("on-conflict", archive.ConflictSkip, "Aynı ID'li görev varsa: skip, overwrite veya rename")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if len(positional) == 0 {
+		fmt.Println("Hata: Dosya belirtilmedi")
+		fmt.Println("Kullanım: sysrow import [--format jsonl|tar] [--on-conflict skip|overwrite|rename] <dosya|->")
+		os.Exit(exitUsage)
+	}
+	file := positional[0]
+
+	if _, err := archive.ParseConflict(*conflict); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if *format == "" {
+		*format = formatFromName(file)
+	}
+	if _, err := archive.ParseFormat(*format); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		defer f.Close()
+		r = f
+	}
+
+	result, err := archive.Import(r, *format, *conflict, a.dataDir, a.store)
+	if result != nil {
+		for oldID, newID := range result.Renamed {
+			fmt.Printf("  %s -> %s\n", oldID, newID)
+		}
+		fmt.Printf("%d görev içe aktarıldı (%d üzerine yazıldı, %d yeniden adlandırıldı), %d görev atlandı\n",
+			result.Imported, result.Overwritten, len(result.Renamed), result.Skipped)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// formatFromName guesses the format of an export from its file name
+func formatFromName(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".tar"), strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return archive.FormatTar
+	case strings.HasSuffix(name, ".csv"):
+		return archive.FormatCSV
+	default:
+		return archive.FormatJSONL
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Can/sysrow/pkg/dag"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// idList is a flag that can be repeated and also accepts comma separated IDs
+type idList []string
+
+func (l *idList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *idList) Set(value string) error {
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			*l = append(*l, id)
+		}
+	}
+
+	return nil
+}
+
+// dependencyFlags holds the options that make a task wait for other tasks
+type dependencyFlags struct {
+	afterTask idList
+	needs     idList
+	when      *string
+}
+
+// addDependencyFlags registers --after-task, --needs and --when on the flag set
+func addDependencyFlags(flags *flag.FlagSet) *dependencyFlags {
+	f := &dependencyFlags{}
+	flags.Var(&f.afterTask, "after-task", "Bu görevler bittikten sonra çalıştır (tekrarlanabilir veya virgülle ayrılmış ID'ler)")
+	flags.Var(&f.needs, "needs", "Yalnızca bu görevler başarılı olursa çalıştır (--after-task ... --when success ile aynı)")
+	f.when = flags.String("when", "", "Bağımlılıkların nasıl bitmesi gerektiği (success, failure, completion)")
+
+	return f
+}
+
+// isSet reports whether any dependency option was given
+func (f *dependencyFlags) isSet() bool {
+	return len(f.afterTask) > 0 || len(f.needs) > 0 || *f.when != ""
+}
+
+// apply sets the dependencies on a new task and marks it blocked. It fails
+// if a dependency does not exist or the task would close a cycle.
+func (f *dependencyFlags) apply(t *task.Task, store task.Store) error {
+	if !f.isSet() {
+		return nil
+	}
+
+	if len(f.afterTask) == 0 && len(f.needs) == 0 {
+		return fmt.Errorf("--when requires --after-task or --needs")
+	}
+
+	// --needs means success, a bare --after-task waits for any outcome
+	condition := task.ConditionCompletion
+	if len(f.needs) > 0 {
+		condition = task.ConditionSuccess
+	}
+	if *f.when != "" {
+		var err error
+		if condition, err = task.ParseCondition(*f.when); err != nil {
+			return err
+		}
+		if len(f.needs) > 0 && condition != task.ConditionSuccess {
+			return fmt.Errorf("--needs cannot be combined with --when %s, use --after-task", condition)
+		}
+	}
+
+	// Dependencies may be given by ID prefix or name, the task keeps full IDs
+	for _, ref := range append(append([]string{}, f.afterTask...), f.needs...) {
+		dep, err := task.Resolve(store, ref)
+		if err != nil {
+			return err
+		}
+		t.DependsOn = append(t.DependsOn, dep.ID)
+	}
+	t.Condition = condition
+	t.Status = task.StatusBlocked
+
+	tasks, err := store.ListTasks()
+	if err != nil {
+		return err
+	}
+
+	return dag.Validate(t, tasks)
+}
+
+func (a *app) handleGraphCommand(args []string) {
+	flags := flag.NewFlagSet("graph", flag.ExitOnError)
+	dependents := flags.Bool("dependents", false, "Bu göreve bağımlı olan görevleri göster")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if len(positional) == 0 {
+		fmt.Println("Hata: Görev ID'si belirtilmedi")
+		fmt.Println("Kullanım: sysrow graph [--dependents] <görev_id>")
+		os.Exit(exitUsage)
+	}
+
+	root := a.resolveTask(positional[0])
+
+	tasks, err := a.store.ListTasks()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Görevler listelenemedi: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	tree, err := dag.Tree(root.ID, tasks, *dependents)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Print(tree)
+}
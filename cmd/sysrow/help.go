@@ -154,7 +154,7 @@ func showBasicCommands() {
 		case "b", "B":
 			return
 		case "q", "Q":
-			os.Exit(0)
+			os.Exit(exitOK)
 		default:
 			fmt.Println("\n" + i18n.Get("main_menu.invalid_choice"))
 		}
@@ -172,6 +172,10 @@ func showQueueCommand() {
 	fmt.Println("   ")
 	fmt.Println("   " + i18n.Get("command_details.queue.options"))
 	fmt.Println("   " + i18n.Get("command_details.queue.option_priority"))
+	fmt.Println("   " + i18n.Get("command_details.queue.option_timeout"))
+	fmt.Println("   " + i18n.Get("command_details.queue.option_retries"))
+	fmt.Println("   " + i18n.Get("command_details.queue.option_needs"))
+	fmt.Println("   " + i18n.Get("command_details.queue.option_on"))
 
 	fmt.Println("\n" + i18n.Get("navigation.continue"))
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
@@ -204,6 +208,9 @@ func showGroupCommand() {
 	fmt.Println("   " + i18n.Get("command_details.group.example2"))
 	fmt.Println("   " + i18n.Get("command_details.group.example3"))
 	fmt.Println("   " + i18n.Get("command_details.group.example4"))
+	fmt.Println("   " + i18n.Get("command_details.group.example6"))
+	fmt.Println("   " + i18n.Get("command_details.group.example7"))
+	fmt.Println("   " + i18n.Get("command_details.group.example8"))
 	fmt.Println("   " + i18n.Get("command_details.group.example5"))
 
 	fmt.Println("\n" + i18n.Get("navigation.continue"))
@@ -231,6 +238,9 @@ func showStatusCommands() {
 	fmt.Println("   " + i18n.Get("command_details.status.example1"))
 	fmt.Println("   " + i18n.Get("command_details.status.example2"))
 	fmt.Println("   " + i18n.Get("command_details.status.example3"))
+	fmt.Println("   " + i18n.Get("command_details.status.example4"))
+	fmt.Println("   " + i18n.Get("command_details.status.example5"))
+	fmt.Println("   " + i18n.Get("command_details.status.example6"))
 
 	fmt.Println("\n" + i18n.Get("navigation.continue"))
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
@@ -33,7 +33,7 @@ func NewI18n(langPath string, defaultLang string) (*I18n, error) {
 	err := i18n.LoadLanguage(defaultLang)
 	if err != nil {
 		// If the specified language fails, try to load the fallback language
-		fmt.Printf("Warning: Could not load language '%s', falling back to '%s'\n", defaultLang, i18n.FallbackLang)
+		fmt.Fprintf(os.Stderr, "Warning: Could not load language '%s', falling back to '%s'\n", defaultLang, i18n.FallbackLang)
 		err = i18n.LoadLanguage(i18n.FallbackLang)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load fallback language: %v", err)
@@ -65,7 +65,7 @@ func (i *I18n) LoadLanguage(lang string) error {
 // Get retrieves a translation string by its key
 func (i *I18n) Get(key string) string {
 	keys := strings.Split(key, ".")
-	var current interface{} = i.Translations
+	var current interface{} = map[string]interface{}(i.Translations)
 
 	for _, k := range keys {
 		m, ok := current.(map[string]interface{})
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Can/sysrow/pkg/queue"
+	"github.com/Can/sysrow/pkg/schedule"
+	"github.com/Can/sysrow/pkg/task"
+	"github.com/Can/sysrow/pkg/term"
+)
+
+// shortIDLength is the number of characters of a task ID shown by default
+const shortIDLength = 8
+
+// minCommandWidth is the narrowest the command column is truncated to
+const minCommandWidth = 20
+
+// defaultListColumns are the columns `sysrow list` shows without --columns
+var defaultListColumns = []string{"id", "status", "priority", "slot", "created", "command"}
+
+// listView holds what the columns of `sysrow list` need besides the task
+type listView struct {
+	now time.Time
+	// groups maps group IDs to group names
+	groups map[string]string
+	// full shows whole task IDs and commands
+	full bool
+}
+
+// listColumn is a column `sysrow list --columns` can show
+type listColumn struct {
+	header string
+	value  func(v *listView, t *task.Task) string
+}
+
+// listColumns are the columns of `sysrow list` by name
+var listColumns = map[string]listColumn{
+	"id": {"ID", func(v *listView, t *task.Task) string {
+		if v.full || len(t.ID) <= shortIDLength {
+			return t.ID
+		}
+		return t.ID[:shortIDLength]
+	}},
+	"name": {"NAME", func(v *listView, t *task.Task) string {
+		if t.Name == "" {
+			return "-"
+		}
+		return t.Name
+	}},
+	"status":   {"STATUS", func(v *listView, t *task.Task) string { return string(t.Status) }},
+	"priority": {"PRIORITY", func(v *listView, t *task.Task) string { return string(t.Priority) }},
+	"queue":    {"QUEUE", func(v *listView, t *task.Task) string { return queue.NameOf(t) }},
+	"slot": {"SLOT", func(v *listView, t *task.Task) string {
+		// Only running tasks occupy a worker slot
+		if t.Status == task.StatusRunning && t.Slot != nil {
+			return fmt.Sprintf("#%d", *t.Slot)
+		}
+		return "-"
+	}},
+	"exit": {"EXIT", func(v *listView, t *task.Task) string {
+		if t.ExitCode == nil {
+			return "-"
+		}
+		return strconv.Itoa(*t.ExitCode)
+	}},
+	"created":  {"CREATED", func(v *listView, t *task.Task) string { return relativeTime(&t.CreatedAt, v.now) }},
+	"started":  {"STARTED", func(v *listView, t *task.Task) string { return relativeTime(t.StartedAt, v.now) }},
+	"finished": {"FINISHED", func(v *listView, t *task.Task) string { return relativeTime(t.FinishedAt, v.now) }},
+	"duration": {"DURATION", func(v *listView, t *task.Task) string {
+		if t.StartedAt == nil {
+			return "-"
+		}
+		return formatDuration(taskDuration(t, v.now))
+	}},
+	"attempts": {"ATTEMPTS", func(v *listView, t *task.Task) string { return strconv.Itoa(len(t.Attempts)) }},
+	"group": {"GROUP", func(v *listView, t *task.Task) string {
+		if t.GroupID == nil {
+			return "-"
+		}
+		if name, ok := v.groups[*t.GroupID]; ok {
+			return name
+		}
+		return *t.GroupID
+	}},
+	"command": {"COMMAND", func(v *listView, t *task.Task) string { return t.Command }},
+}
+
+// listSortKeys compare two tasks by a field for `sysrow list --sort`
+var listSortKeys = map[string]func(a, b *task.Task, now time.Time) bool{
+	"id":       func(a, b *task.Task, now time.Time) bool { return a.ID < b.ID },
+	"name":     func(a, b *task.Task, now time.Time) bool { return a.Name < b.Name },
+	"status":   func(a, b *task.Task, now time.Time) bool { return a.Status < b.Status },
+	"priority": func(a, b *task.Task, now time.Time) bool { return a.Priority.Rank() < b.Priority.Rank() },
+	"queue":    func(a, b *task.Task, now time.Time) bool { return queue.NameOf(a) < queue.NameOf(b) },
+	"exit":     func(a, b *task.Task, now time.Time) bool { return exitValue(a) < exitValue(b) },
+	"created":  func(a, b *task.Task, now time.Time) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	"started": func(a, b *task.Task, now time.Time) bool {
+		return timeValue(a.StartedAt).Before(timeValue(b.StartedAt))
+	},
+	"finished": func(a, b *task.Task, now time.Time) bool {
+		return timeValue(a.FinishedAt).Before(timeValue(b.FinishedAt))
+	},
+	"duration": func(a, b *task.Task, now time.Time) bool { return taskDuration(a, now) < taskDuration(b, now) },
+	"attempts": func(a, b *task.Task, now time.Time) bool { return len(a.Attempts) < len(b.Attempts) },
+	"command":  func(a, b *task.Task, now time.Time) bool { return a.Command < b.Command },
+}
+
+func (a *app) handleListCommand(args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	status := flags.String("status", "", "Yalnızca bu durumdaki görevler (virgülle ayrılmış)")
+	groupName := flags.String("group", "", "Yalnızca bu grubun görevleri")
+	since := flags.String("since", "", "Yalnızca bu tarihten sonra oluşturulan görevler (örn. 2h veya 2026-01-01)")
+	priority := flags.String("priority", "", "Yalnızca bu öncelikteki görevler (virgülle ayrılmış)")
+	sortBy := flags.String("sort", "created", "Sıralama alanı, azalan sıra için başına - eklenir (örn. -created)")
+	limit := flags.Int("limit", 0, "En fazla bu kadar görev göster (0: tümü)")
+	columns := flags.String("columns", strings.Join(defaultListColumns, ","), "Gösterilecek sütunlar (virgülle ayrılmış): "+strings.Join(sortedKeys(listColumns), ", "))
+	noTrunc := flags.Bool("no-trunc", false, "Görev ID'lerini ve komutları kısaltma")
+
+	if _, err := parseFlags(flags, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	printer := a.output
+	now := time.Now()
+	var filter task.Filter
+
+	if *status != "" {
+		for _, name := range strings.Split(*status, ",") {
+			s, err := task.ParseStatus(strings.TrimSpace(name))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+				os.Exit(exitUsage)
+			}
+			filter.Statuses = append(filter.Statuses, s)
+		}
+	}
+
+	priorities := make(map[task.TaskPriority]bool)
+	if *priority != "" {
+		for _, name := range strings.Split(*priority, ",") {
+			p, err := task.ParsePriority(strings.TrimSpace(name))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+				os.Exit(exitUsage)
+			}
+			priorities[p] = true
+		}
+	}
+
+	if *since != "" {
+		from, err := schedule.ParseSince(*since, now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		filter.Since = from
+	}
+
+	if *groupName != "" {
+		g, err := a.groups.GetGroupByName(*groupName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		filter.GroupID = g.ID
+	}
+
+	less, descending, err := parseListSort(*sortBy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if *limit < 0 {
+		fmt.Fprintln(os.Stderr, "Hata: --limit negatif olamaz")
+		os.Exit(exitUsage)
+	}
+
+	selected, err := parseListColumns(*columns)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	// Filtering covers the whole stored history
+	tasks, err := task.FindTasks(a.store, filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Görevler listelenemedi: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	if len(priorities) > 0 {
+		matching := tasks[:0]
+		for _, t := range tasks {
+			if priorities[t.Priority] {
+				matching = append(matching, t)
+			}
+		}
+		tasks = matching
+	}
+
+	if len(tasks) == 0 && !printer.Machine() {
+		fmt.Println("Kayıtlı görev yok")
+		return
+	}
+
+	// Ties keep the order of creation
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+	})
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if descending {
+			return less(tasks[j], tasks[i], now)
+		}
+		return less(tasks[i], tasks[j], now)
+	})
+
+	if *limit > 0 && len(tasks) > *limit {
+		tasks = tasks[:*limit]
+	}
+
+	// Scripts get whole tasks, --columns only applies to the table
+	if printer.Machine() {
+		printOutputList(printer, tasks)
+		return
+	}
+
+	view := &listView{now: now, full: *noTrunc}
+	for _, name := range selected {
+		if name == "group" {
+			view.groups = a.groupNames()
+		}
+	}
+
+	printTaskTable(view, selected, tasks)
+}
+
+// printTaskTable prints the tasks as an aligned table. On a terminal the
+// command column is cut so that rows fit on one line.
+func printTaskTable(view *listView, columns []string, tasks []*task.Task) {
+	rows := make([][]string, len(tasks))
+	widths := make([]int, len(columns))
+	for i, name := range columns {
+		widths[i] = utf8.RuneCountInString(listColumns[name].header)
+	}
+	for r, t := range tasks {
+		rows[r] = make([]string, len(columns))
+		for i, name := range columns {
+			// Commands spanning several lines are shown on one
+			cell := strings.Join(strings.Fields(listColumns[name].value(view, t)), " ")
+			rows[r][i] = cell
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+
+	if width, ok := term.Width(os.Stdout); ok && !view.full {
+		for i, name := range columns {
+			if name != "command" {
+				continue
+			}
+
+			// The other columns are separated by two spaces
+			others := 0
+			for j, w := range widths {
+				if j != i {
+					others += w + 2
+				}
+			}
+			limit := max(width-others, minCommandWidth)
+			for _, row := range rows {
+				row[i] = truncate(row[i], limit)
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	headers := make([]string, len(columns))
+	for i, name := range columns {
+		headers[i] = listColumns[name].header
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+// groupNames maps the IDs of all groups to their names
+func (a *app) groupNames() map[string]string {
+	names := make(map[string]string)
+
+	groups, err := a.groups.ListGroups()
+	if err != nil {
+		return names
+	}
+	for _, g := range groups {
+		names[g.ID] = g.Name
+	}
+
+	return names
+}
+
+// parseListSort parses --sort, a field name optionally prefixed with - for
+// descending order
+func parseListSort(value string) (func(a, b *task.Task, now time.Time) bool, bool, error) {
+	name := strings.TrimSpace(value)
+	descending := strings.HasPrefix(name, "-")
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "-"), "+")
+
+	less, ok := listSortKeys[name]
+	if !ok {
+		return nil, false, fmt.Errorf("invalid sort field %q (expected one of %s)", name, strings.Join(sortedKeys(listSortKeys), ", "))
+	}
+
+	return less, descending, nil
+}
+
+// parseListColumns parses --columns
+func parseListColumns(value string) ([]string, error) {
+	columns := make([]string, 0)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := listColumns[name]; !ok {
+			return nil, fmt.Errorf("invalid column %q (expected one of %s)", name, strings.Join(sortedKeys(listColumns), ", "))
+		}
+		columns = append(columns, name)
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+
+	return columns, nil
+}
+
+// sortedKeys returns the keys of a map in alphabetical order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// relativeTime formats a time relative to now, e.g. 5m ago or in 2h.
+// Times more than a month away are shown as a date.
+func relativeTime(t *time.Time, now time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+
+	d := now.Sub(*t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	var amount string
+	switch {
+	case d < time.Minute:
+		amount = fmt.Sprintf("%ds", int(d/time.Second))
+	case d < time.Hour:
+		amount = fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		amount = fmt.Sprintf("%dh", int(d/time.Hour))
+	case d < 30*24*time.Hour:
+		amount = fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	default:
+		return t.Format("2006-01-02")
+	}
+
+	if future {
+		return "in " + amount
+	}
+	return amount + " ago"
+}
+
+// taskDuration returns how long a task ran, or has been running so far
+func taskDuration(t *task.Task, now time.Time) time.Duration {
+	if t.StartedAt == nil {
+		return 0
+	}
+	if t.FinishedAt == nil {
+		return now.Sub(*t.StartedAt)
+	}
+
+	return t.FinishedAt.Sub(*t.StartedAt)
+}
+
+// formatDuration rounds a duration for display
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+
+	return d.Round(time.Second).String()
+}
+
+// exitValue returns the exit code of a task for sorting, tasks without one come first
+func exitValue(t *task.Task) int {
+	if t.ExitCode == nil {
+		return -1 << 31
+	}
+
+	return *t.ExitCode
+}
+
+// timeValue returns an optional time for sorting, a missing time comes first
+func timeValue(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	return *t
+}
+
+// truncate shortens s to at most width characters, marking the cut with …
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
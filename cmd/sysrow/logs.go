@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Can/sysrow/pkg/output"
+	"github.com/Can/sysrow/pkg/schedule"
+	"github.com/Can/sysrow/pkg/task"
+	"github.com/Can/sysrow/pkg/tasklog"
+)
+
+// logLine is what `sysrow logs` prints for scripts. Records are written as
+// the lines are read, so logs of any size are streamed.
+type logLine struct {
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+	// Partial is set when the text continues in the next record, or the
+	// task has not ended the line yet
+	Partial bool `json:"partial,omitempty"`
+}
+
+func (a *app) handleLogsCommand(args []string) {
+	flags := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := flags.Bool("follow", false, "Görev bitene kadar yeni çıktıyı yazıldıkça göster")
+	flags.BoolVar(follow, "f", false, "Görev bitene kadar yeni çıktıyı göster (kısa form)")
+	tail := flags.Int("tail", 0, "Yalnızca son N satırı göster (0: tümü)")
+	since := flags.String("since", "", "Yalnızca bu zamandan sonra yazılan çıktı (örn. 10m veya 2026-01-01T12:00)")
+	stdoutOnly := flags.Bool("stdout-only", false, "Yalnızca standart çıktıyı göster")
+	stderrOnly := flags.Bool("stderr-only", false, "Yalnızca standart hata çıktısını göster")
+	merge := flags.Bool("merge", false, "İki akışı yazıldıkları sırayla, her satırın başında akış adıyla standart çıktıda birleştir")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if len(positional) == 0 {
+		fmt.Println("Hata: Görev ID'si belirtilmedi")
+		fmt.Println("Kullanım: sysrow logs [-f] [--tail=<n>] [--since=<zaman>] [--stdout-only|--stderr-only] [--merge] <görev_id>")
+		os.Exit(exitUsage)
+	}
+	printer := a.output
+
+	if *stdoutOnly && *stderrOnly {
+		fmt.Fprintln(os.Stderr, "Hata: --stdout-only ve --stderr-only birlikte kullanılamaz")
+		os.Exit(exitUsage)
+	}
+	if *tail < 0 {
+		fmt.Fprintln(os.Stderr, "Hata: --tail negatif olamaz")
+		os.Exit(exitUsage)
+	}
+
+	opts := tasklog.Options{Tail: *tail}
+	if *since != "" {
+		if opts.Since, err = schedule.ParseSince(*since, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+
+	streams := tasklog.Streams
+	switch {
+	case *stdoutOnly:
+		streams = []string{tasklog.Stdout}
+	case *stderrOnly:
+		streams = []string{tasklog.Stderr}
+	}
+
+	t := a.resolveTask(positional[0])
+
+	l, err := tasklog.Open(a.dataDir, t.ID, streams)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitError)
+	}
+	defer l.Close()
+
+	if !l.Exists() {
+		if !*follow || t.Status.IsTerminal() {
+			// Scripts get no records
+			if printer.Machine() {
+				printOutputList(printer, []logLine{})
+			} else {
+				fmt.Printf("Görev henüz çalışmadı, durum: %s\n", t.Status)
+			}
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Görev henüz çalışmadı, durum: %s. Çıktısı bekleniyor...\n", t.Status)
+	}
+
+	p := newLogPrinter(*merge)
+	if printer.Machine() {
+		p.records = printer.Stream(p.stdout)
+	}
+	err = l.Read(opts, !*follow, p.print)
+	if err == nil {
+		err = p.flush()
+	}
+
+	if err == nil && *follow {
+		p.live = true
+		err = l.Follow(func() (bool, error) {
+			current, err := a.store.LoadTask(t.ID)
+			if errors.Is(err, task.ErrNotFound) {
+				// The task was deleted meanwhile, nothing more will be written
+				return true, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			return current.Status.IsTerminal(), nil
+		}, p.print)
+	}
+
+	// JSON and YAML records form one array, which is only now complete
+	if err == nil && p.records != nil {
+		if err = p.records.Close(); err == nil {
+			err = p.flush()
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+// logPrinter writes the lines of task logs, every stream to the file
+// descriptor it was written to, or both to stdout with the stream name in
+// front of every line
+type logPrinter struct {
+	merge bool
+	// records prints every line as a record to stdout for scripts
+	records *output.Stream
+	stdout  *bufio.Writer
+	stderr  *bufio.Writer
+	// live writes every line at once while a task is followed
+	live bool
+	// last is the stream of the last line written
+	last string
+}
+
+// newLogPrinter returns a printer that merges the streams if merge is set
+func newLogPrinter(merge bool) *logPrinter {
+	return &logPrinter{
+		merge:  merge,
+		stdout: bufio.NewWriter(os.Stdout),
+		stderr: bufio.NewWriter(os.Stderr),
+	}
+}
+
+// print writes a line of a task log
+func (p *logPrinter) print(line tasklog.Line) error {
+	if p.records != nil {
+		record := logLine{Stream: line.Stream, Text: string(line.Text), Partial: !line.Newline}
+		if err := p.records.Add(record); err != nil {
+			return err
+		}
+		if p.live {
+			return p.flush()
+		}
+		return nil
+	}
+
+	// A terminal showing both streams must get the lines in order
+	if line.Stream != p.last {
+		if err := p.flush(); err != nil {
+			return err
+		}
+		p.last = line.Stream
+	}
+
+	w := p.stdout
+	if line.Stream == tasklog.Stderr && !p.merge {
+		w = p.stderr
+	}
+
+	if p.merge {
+		w.WriteByte('[')
+		w.WriteString(line.Stream)
+		w.WriteString("] ")
+	}
+	w.Write(line.Text)
+	if line.Newline || p.merge {
+		w.WriteByte('\n')
+	}
+
+	if p.live {
+		return p.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered lines
+func (p *logPrinter) flush() error {
+	if err := p.stdout.Flush(); err != nil {
+		return err
+	}
+
+	return p.stderr.Flush()
+}
@@ -1,12 +1,25 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/Can/sysrow/pkg/group"
+	"github.com/Can/sysrow/pkg/output"
+	"github.com/Can/sysrow/pkg/proc"
+	"github.com/Can/sysrow/pkg/recovery"
+	"github.com/Can/sysrow/pkg/schedule"
 	"github.com/Can/sysrow/pkg/task"
+	"github.com/Can/sysrow/pkg/tasklog"
 )
 
 // Command represents a CLI command
@@ -68,7 +81,7 @@ func (c *Command) PrintUsage() {
 func printUsage() {
 	// Print app name and description
 	fmt.Printf("\n%s - %s\n\n", i18n.Get("app_name"), i18n.Get("app_description"))
-	
+
 	// Print usage
 	fmt.Println(i18n.Get("cli_messages.usage"))
 	fmt.Print("  sysrow [command] [arguments]\n\n")
@@ -83,22 +96,51 @@ func printUsage() {
 	fmt.Printf("  %-10s %s\n", "status", i18n.Get("commands_menu.status"))
 	fmt.Printf("  %-10s %s\n", "logs", i18n.Get("commands_menu.status"))
 	fmt.Printf("  %-10s %s\n", "cancel", i18n.Get("commands_menu.cancel"))
+	fmt.Printf("  %-10s %s\n", "daemon", i18n.Get("commands_menu.daemon"))
+	fmt.Printf("  %-10s %s\n", "every", i18n.Get("commands_menu.every"))
+	fmt.Printf("  %-10s %s\n", "doctor", i18n.Get("commands_menu.doctor"))
+	fmt.Printf("  %-10s %s\n", "recurring", i18n.Get("commands_menu.recurring"))
+	fmt.Printf("  %-10s %s\n", "graph", i18n.Get("commands_menu.graph"))
+	fmt.Printf("  %-10s %s\n", "apply", i18n.Get("commands_menu.apply"))
+	fmt.Printf("  %-10s %s\n", "diff", i18n.Get("commands_menu.diff"))
+	fmt.Printf("  %-10s %s\n", "storage", i18n.Get("commands_menu.storage"))
+	fmt.Printf("  %-10s %s\n", "prune", i18n.Get("commands_menu.prune"))
+	fmt.Printf("  %-10s %s\n", "export", i18n.Get("commands_menu.export"))
+	fmt.Printf("  %-10s %s\n", "import", i18n.Get("commands_menu.import"))
 	fmt.Printf("  %-10s %s\n", "help", "Detailed help information")
 
 	// Print help hint
 	fmt.Printf("\n%s\n", i18n.Get("cli_messages.help_hint"))
 }
 
-func main() {
-	// Initialize the application
-	if err := task.InitializeDataDirectory(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error initializing data directory: %v\n", err)
-		os.Exit(1)
-	}
+// commands are the handlers of the commands that work on the data directory
+var commands = map[string]func(*app, []string){
+	"queue":     (*app).handleQueueCommand,
+	"delay":     (*app).handleDelayCommand,
+	"run":       (*app).handleRunCommand,
+	"group":     (*app).handleGroupCommand,
+	"list":      (*app).handleListCommand,
+	"status":    (*app).handleStatusCommand,
+	"logs":      (*app).handleLogsCommand,
+	"cancel":    (*app).handleCancelCommand,
+	"daemon":    (*app).handleDaemonCommand,
+	"doctor":    (*app).handleDoctorCommand,
+	"every":     (*app).handleEveryCommand,
+	"recurring": (*app).handleRecurringCommand,
+	"graph":     (*app).handleGraphCommand,
+	"apply":     (*app).handleApplyCommand,
+	"diff":      (*app).handleDiffCommand,
+	"storage":   (*app).handleStorageCommand,
+	"prune":     (*app).handlePruneCommand,
+	"export":    (*app).handleExportCommand,
+	"import":    (*app).handleImportCommand,
+	"__exec":    (*app).handleExecCommand,
+}
 
+func main() {
 	// Initialize i18n system
 	language := ""
-	
+
 	// Check for language flag
 	for i, arg := range os.Args {
 		if arg == "--lang" && i+1 < len(os.Args) {
@@ -125,16 +167,29 @@ func main() {
 				"app_name":        "SysRow",
 				"app_description": "CLI-based background task manager",
 				"cli_messages": map[string]interface{}{
-					"usage":     "Usage:",
-					"help_hint": "Use 'sysrow help' for more information.",
+					"usage":        "Usage:",
+					"help_hint":    "Use 'sysrow help' for more information.",
+					"task_queued":  "Task queued. ID: %s",
+					"task_delayed": "Task scheduled. ID: %s",
 				},
 				"commands_menu": map[string]interface{}{
-					"queue":  "Add a task to the queue",
-					"delay":  "Schedule a task for later execution",
-					"run":    "Run a task immediately, optionally in background",
-					"group":  "Manage task groups",
-					"status": "List tasks and check their status",
-					"cancel": "Cancel a task",
+					"queue":     "Add a task to the queue",
+					"delay":     "Schedule a task for later execution",
+					"run":       "Run a task immediately, optionally in background",
+					"group":     "Manage task groups",
+					"status":    "List tasks and check their status",
+					"cancel":    "Cancel a task",
+					"daemon":    "Start, stop or inspect the scheduler daemon",
+					"every":     "Run a task repeatedly on a cron schedule or interval",
+					"recurring": "Manage recurring tasks",
+					"doctor":    "Detect crashed tasks and check the data directory",
+					"graph":     "Show the dependency tree of a task",
+					"apply":     "Create or update groups from a file",
+					"diff":      "Preview the changes apply would make",
+					"storage":   "Move tasks to another storage backend",
+					"prune":     "Delete old finished tasks and their logs",
+					"export":    "Export task history with logs (jsonl, csv, tar)",
+					"import":    "Import task history exported on another machine",
 				},
 			},
 			FallbackLang: "en",
@@ -144,123 +199,501 @@ func main() {
 	// Parse command line arguments
 	if len(os.Args) < 2 {
 		printUsage()
-		os.Exit(0)
+		os.Exit(exitOK)
 	}
 
 	// Extract the command
 	cmd := strings.ToLower(os.Args[1])
 
-	// Process the command
-	switch cmd {
-	case "queue":
-		handleQueueCommand(os.Args[2:])
-	case "delay":
-		handleDelayCommand(os.Args[2:])
-	case "run":
-		handleRunCommand(os.Args[2:])
-	case "group":
-		handleGroupCommand(os.Args[2:])
-	case "list":
-		handleListCommand(os.Args[2:])
-	case "status":
-		handleStatusCommand(os.Args[2:])
-	case "logs":
-		handleLogsCommand(os.Args[2:])
-	case "cancel":
-		handleCancelCommand(os.Args[2:])
-	case "help":
+	// Help and unknown commands do not touch the data directory
+	handler, ok := commands[cmd]
+	switch {
+	case cmd == "help":
 		showDetailedHelp()
-	case "--help", "-h":
+		return
+	case cmd == "--help" || cmd == "-h":
 		printUsage()
-	default:
+		return
+	case !ok:
 		fmt.Fprintf(os.Stderr, "Bilinmeyen komut: %s\n\n", cmd)
 		printUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
+	}
+
+	// --output and --format apply to every command, wherever they are given
+	format, template, args, err := splitOutputFlags(cmd, os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	printer, err := output.New(format, template)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
 	}
+
+	// Initialize the application
+	a, err := newApp()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitError)
+	}
+	a.output = printer
+
+	// Mark tasks whose process vanished, e.g. after a crash, as lost.
+	// `doctor` does this itself and reports the details, and the executor
+	// started for a single task leaves it to the command that started it.
+	if cmd != "doctor" && cmd != "__exec" {
+		report, err := recovery.Reconcile(a.dataDir)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Uyarı: Çalışan görevler kontrol edilemedi: %v\n", err)
+		case len(report.Lost) > 0:
+			fmt.Fprintf(os.Stderr, "Uyarı: %d görevin süreci bulunamadı, görevler 'lost' olarak işaretlendi. Ayrıntılar için: sysrow doctor\n", len(report.Lost))
+		}
+	}
+
+	// Process the command
+	handler(a, args)
+}
+
+// parseFlags parses flags that may appear before or after the positional
+// arguments, so that both `queue --priority high cmd` and `queue cmd --priority high` work
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// taskFlags holds the options shared by every command that creates a task
+type taskFlags struct {
+	priority    *string
+	timeout     *string
+	retries     *int
+	backoff     *string
+	retryDelay  *string
+	retryOnExit *string
+	name        *string
+}
+
+// addTaskFlags registers --priority, -p, --timeout, --name and the retry options on the flag set
+func addTaskFlags(flags *flag.FlagSet) *taskFlags {
+	f := &taskFlags{
+		priority:    flags.String("priority", "normal", "Görev önceliği (low, normal, high)"),
+		timeout:     flags.String("timeout", "", "Görev bu süreden uzun sürerse sonlandırılır (30m, 2h gibi)"),
+		retries:     flags.Int("retries", 0, "Başarısız görevin en fazla kaç kez yeniden deneneceği"),
+		backoff:     flags.String("backoff", "", "Denemeler arasındaki bekleme politikası (fixed, exponential)"),
+		retryDelay:  flags.String("retry-delay", "", "Yeniden denemeden önce beklenecek süre (varsayılan 10s)"),
+		retryOnExit: flags.String("retry-on-exit", "", "Yalnızca bu çıkış kodlarında yeniden dene (1,75 gibi)"),
+		name:        flags.String("name", "", "Görev ID'si yerine kullanılabilecek ad (örn. nightly-backup)"),
+	}
+	flags.StringVar(f.priority, "p", "normal", "Görev önceliği (kısa form)")
+
+	return f
+}
+
+// timeoutDuration parses --timeout, an empty value means no timeout
+func (f *taskFlags) timeoutDuration() (time.Duration, error) {
+	if *f.timeout == "" {
+		return 0, nil
+	}
+
+	timeout, err := schedule.ParseDuration(*f.timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	return timeout, nil
+}
+
+// newTask creates a pending task for the command using the parsed flags
+func (f *taskFlags) newTask(command string) (*task.Task, error) {
+	taskPriority, err := task.ParsePriority(*f.priority)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := f.timeoutDuration()
+	if err != nil {
+		return nil, err
+	}
+
+	retry, err := f.retryPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	if *f.name != "" {
+		if err := task.ValidateName(*f.name); err != nil {
+			return nil, err
+		}
+	}
+
+	t := task.NewTask(command, taskPriority)
+	t.Name = *f.name
+	t.Timeout = timeout
+	t.Retry = retry
+
+	return t, nil
+}
+
+// retryPolicy builds the retry policy from the flags, nil when --retries is not set
+func (f *taskFlags) retryPolicy() (*task.RetryPolicy, error) {
+	if *f.retries < 0 {
+		return nil, fmt.Errorf("--retries must not be negative")
+	}
+
+	if *f.retries == 0 {
+		if *f.backoff != "" || *f.retryDelay != "" || *f.retryOnExit != "" {
+			return nil, fmt.Errorf("retry options require --retries")
+		}
+		return nil, nil
+	}
+
+	policy := &task.RetryPolicy{
+		MaxRetries: *f.retries,
+		Backoff:    task.BackoffFixed,
+		Delay:      task.DefaultRetryDelay,
+	}
+
+	var err error
+	if *f.backoff != "" {
+		if policy.Backoff, err = task.ParseBackoff(*f.backoff); err != nil {
+			return nil, err
+		}
+	}
+
+	if *f.retryDelay != "" {
+		if policy.Delay, err = schedule.ParseDuration(*f.retryDelay); err != nil {
+			return nil, fmt.Errorf("invalid retry delay: %w", err)
+		}
+	}
+
+	if *f.retryOnExit != "" {
+		if policy.OnExitCodes, err = task.ParseExitCodes(*f.retryOnExit); err != nil {
+			return nil, err
+		}
+	}
+
+	return policy, nil
 }
 
 // Command handlers
-func handleQueueCommand(args []string) {
+func (a *app) handleQueueCommand(args []string) {
 	flags := flag.NewFlagSet("queue", flag.ExitOnError)
-	priority := flags.String("priority", "normal", "Görev önceliği (low, normal, high)")
-	flags.StringVar(priority, "p", "normal", "Görev önceliği (kısa form)")
+	opts := addTaskFlags(flags)
+	deps := addDependencyFlags(flags)
+	on := addQueueFlag(flags)
+	every := flags.String("every", "", "Görevi sabit aralıkla tekrarla (15m, 2h, 1d gibi)")
 
-	if err := flags.Parse(args); err != nil {
+	positional, err := parseFlags(flags, args)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitUsage)
+	}
+
+	// `queue pause <name>` and `queue resume <name>` manage a named queue
+	if len(positional) == 2 && (positional[0] == "pause" || positional[0] == "resume") {
+		a.handleQueuePauseCommand(positional[0], positional[1])
+		return
 	}
 
-	command := flags.Arg(0)
-	if command == "" {
+	if len(positional) == 0 || positional[0] == "" {
 		fmt.Println("Hata: Çalıştırılacak komut belirtilmedi")
-		fmt.Println("Kullanım: sysrow queue [--priority=<öncelik>] <komut>")
-		os.Exit(1)
+		fmt.Println("Kullanım: sysrow queue [--on=<kuyruk>] [--priority=<öncelik>] [--timeout=<süre>] [--needs=<id>] [--every=<süre>] <komut>")
+		fmt.Println("          sysrow queue <pause|resume> <kuyruk>")
+		os.Exit(exitUsage)
+	}
+	command := positional[0]
+	printer := a.output
+
+	queueName, err := parseQueueName(*on)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
 	}
 
-	fmt.Printf("Sıraya ekleniyor: '%s' (öncelik: %s)\n", command, *priority)
-	fmt.Println("Queue command not yet implemented")
+	if *every != "" {
+		if deps.isSet() {
+			fmt.Fprintln(os.Stderr, "Hata: Tekrarlanan görevler bağımlılık alamaz")
+			os.Exit(exitUsage)
+		}
+		a.createRecurring(command, opts, queueName, "", *every, printer)
+		return
+	}
+
+	t, err := opts.newTask(command)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	t.Queue = queueName
+
+	if err := deps.apply(t, a.store); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if err := t.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Görev kaydedilemedi: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	// Scripts read the new task, including its ID, from the output
+	if printer.Machine() {
+		printOutput(printer, t)
+		return
+	}
+
+	fmt.Println(i18n.GetWithFormat("cli_messages.task_queued", t.ID))
+	if t.Status == task.StatusBlocked {
+		fmt.Printf("Bağımlılıklar bekleniyor: %s (%s)\n", strings.Join(t.DependsOn, ", "), t.Condition)
+	}
+	a.warnIfQueuePaused(t)
+	a.warnIfDaemonStopped()
 }
 
-func handleDelayCommand(args []string) {
+func (a *app) handleDelayCommand(args []string) {
 	flags := flag.NewFlagSet("delay", flag.ExitOnError)
-	at := flags.String("at", "", "Belirli bir saatte çalıştır (HH:MM formatında)")
+	at := flags.String("at", "", "Belirli bir saatte çalıştır (HH:MM veya RFC3339 formatında)")
 	after := flags.String("after", "", "Belirli bir süre sonra çalıştır (5m, 2h, 1d gibi)")
+	opts := addTaskFlags(flags)
+	deps := addDependencyFlags(flags)
+	on := addQueueFlag(flags)
 
-	if err := flags.Parse(args); err != nil {
+	positional, err := parseFlags(flags, args)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
-	command := flags.Arg(0)
-	if command == "" {
+	if len(positional) == 0 || positional[0] == "" {
 		fmt.Println("Hata: Çalıştırılacak komut belirtilmedi")
 		fmt.Println("Kullanım: sysrow delay [--at=<zaman>|--after=<süre>] <komut>")
-		os.Exit(1)
+		os.Exit(exitUsage)
+	}
+	command := positional[0]
+
+	if (*at == "") == (*after == "") {
+		fmt.Println("Hata: --at veya --after parametrelerinden yalnızca biri belirtilmeli")
+		fmt.Println("Kullanım: sysrow delay [--at=<zaman>|--after=<süre>] <komut>")
+		os.Exit(exitUsage)
+	}
+
+	queueName, err := parseQueueName(*on)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
 	}
 
+	var scheduledAt time.Time
 	if *at != "" {
-		fmt.Printf("Zamanlanıyor: '%s' (saat: %s)\n", command, *at)
-	} else if *after != "" {
-		fmt.Printf("Zamanlanıyor: '%s' (%s sonra)\n", command, *after)
+		scheduledAt, err = schedule.ParseAt(*at, time.Now())
 	} else {
-		fmt.Println("Hata: --at veya --after parametresi belirtilmedi")
-		fmt.Println("Kullanım: sysrow delay [--at=<zaman>|--after=<süre>] <komut>")
-		os.Exit(1)
+		scheduledAt, err = schedule.ParseAfter(*after, time.Now())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	t, err := opts.newTask(command)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	t.ScheduledAt = &scheduledAt
+	t.Queue = queueName
+	if err := deps.apply(t, a.store); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if err := t.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Görev kaydedilemedi: %v\n", err)
+		os.Exit(exitError)
 	}
 
-	fmt.Println("Delay command not yet implemented")
+	fmt.Println(i18n.GetWithFormat("cli_messages.task_delayed", t.ID))
+	fmt.Printf("Çalışma zamanı: %s\n", scheduledAt.Format("2006-01-02 15:04:05 MST"))
+	a.warnIfQueuePaused(t)
+	a.warnIfDaemonStopped()
 }
 
-func handleRunCommand(args []string) {
+func (a *app) handleRunCommand(args []string) {
 	flags := flag.NewFlagSet("run", flag.ExitOnError)
 	background := flags.Bool("bg", false, "Arka planda çalıştır")
+	opts := addTaskFlags(flags)
 
-	if err := flags.Parse(args); err != nil {
+	positional, err := parseFlags(flags, args)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
-	command := flags.Arg(0)
-	if command == "" {
+	if len(positional) == 0 || positional[0] == "" {
 		fmt.Println("Hata: Çalıştırılacak komut belirtilmedi")
-		fmt.Println("Kullanım: sysrow run [--bg] <komut>")
-		os.Exit(1)
+		fmt.Println("Kullanım: sysrow run [--bg] [--timeout=<süre>] <komut>")
+		os.Exit(exitUsage)
+	}
+	command := positional[0]
+
+	t, err := opts.newTask(command)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
 	}
+	t.Manual = true
 
 	if *background {
 		fmt.Printf("Arka planda çalıştırılıyor: '%s'\n", command)
-	} else {
-		fmt.Printf("Çalıştırılıyor: '%s'\n", command)
+		a.runInBackground(t)
+		return
+	}
+
+	fmt.Printf("Çalıştırılıyor: '%s'\n", command)
+	a.runInForeground(t)
+}
+
+// runInForeground runs the task and waits for it. The task has its own
+// process group, so Ctrl-C is forwarded to it as a cancellation.
+func (a *app) runInForeground(t *task.Task) {
+	r := a.runner
+
+	// The runner claims the task through the store, so it must be saved first
+	if err := t.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Görev kaydedilemedi: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	go func() {
+		for sig := range interrupt {
+			// The task may be running or waiting for its next attempt
+			current, err := a.store.LoadTask(t.ID)
+			if err != nil || (current.Status != task.StatusRunning && current.Status != task.StatusPending) {
+				continue
+			}
+			current.Cancel(sig.(syscall.Signal), r.KillGrace)
+		}
+	}()
+
+	if err := r.RunAttempts(t); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	// Show the output of the last attempt without loading it into memory
+	if l, err := tasklog.Open(a.dataDir, t.ID, nil); err == nil {
+		p := newLogPrinter(false)
+		if err := l.Read(tasklog.Options{}, true, p.print); err == nil {
+			p.flush()
+		}
+		l.Close()
+	}
+
+	fmt.Printf("Görev %s tamamlandı, durum: %s\n", t.ID, t.Status)
+	if len(t.Attempts) > 1 {
+		fmt.Printf("%d deneme yapıldı, ayrıntılar için: sysrow status %s\n", len(t.Attempts), t.ID)
 	}
+	if t.Status != task.StatusCompleted {
+		os.Exit(exitTaskFailed)
+	}
+}
+
+// runInBackground hands the task to a detached `sysrow __exec` process so
+// that it keeps running, and its timeout keeps being enforced, after the
+// command returns
+func (a *app) runInBackground(t *task.Task) {
+	// Mark the task running right away so the daemon does not pick it up
+	now := time.Now()
+	t.Status = task.StatusRunning
+	t.StartedAt = &now
+	if err := t.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Görev kaydedilemedi: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		a.failToStart(t, err)
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		a.failToStart(t, err)
+	}
+	defer devNull.Close()
+
+	if _, err := proc.StartDetached(executable, []string{"__exec", t.ID}, devNull); err != nil {
+		a.failToStart(t, err)
+	}
+
+	fmt.Printf("Görev başlatıldı. ID: %s\n", t.ID)
+}
+
+// failToStart records that the executor of a background task could not be
+// started and exits
+func (a *app) failToStart(t *task.Task, err error) {
+	now := time.Now()
+	t.Status = task.StatusFailed
+	t.FinishedAt = &now
+	a.logger.LogError(t.ID, "Failed to start the background executor: "+err.Error())
+	fmt.Fprintf(os.Stderr, "Hata: Görev başlatılamadı: %v\n", err)
+
+	if err := t.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Görev kaydedilemedi: %v\n", err)
+	}
+	os.Exit(exitError)
+}
 
-	fmt.Println("Run command not yet implemented")
+// handleExecCommand is the hidden entry point of `run --bg`. It runs a task
+// that was prepared by runInBackground and records its result.
+func (a *app) handleExecCommand(args []string) {
+	if len(args) != 1 {
+		os.Exit(exitError)
+	}
+
+	t, err := a.store.LoadTask(args[0])
+	if err != nil {
+		os.Exit(exitError)
+	}
+
+	// The task may have been cancelled before this process got to it
+	if t.Status != task.StatusRunning || t.PID != nil {
+		return
+	}
+
+	if err := a.runner.RunAttempts(t); err != nil {
+		// Another executor won the claim or the task was cancelled meanwhile
+		if errors.Is(err, task.ErrClaimed) || errors.Is(err, task.ErrNotPending) {
+			return
+		}
+		a.logger.LogError(t.ID, err.Error())
+		os.Exit(exitError)
+	}
 }
 
-func handleGroupCommand(args []string) {
+func (a *app) handleGroupCommand(args []string) {
 	if len(args) == 0 {
 		fmt.Println("Hata: Alt komut belirtilmedi")
-		fmt.Println("Kullanım: sysrow group <create|add|run|delete> [argümanlar]")
-		os.Exit(1)
+		fmt.Println("Kullanım: sysrow group <create|add|list|run|history|delete> [argümanlar]")
+		os.Exit(exitUsage)
 	}
 
 	subCmd := args[0]
@@ -268,106 +701,334 @@ func handleGroupCommand(args []string) {
 
 	switch subCmd {
 	case "create":
-		handleGroupCreateCommand(subArgs)
+		a.handleGroupCreateCommand(subArgs)
 	case "add":
-		handleGroupAddCommand(subArgs)
+		a.handleGroupAddCommand(subArgs)
+	case "list":
+		a.handleGroupListCommand(subArgs)
 	case "run":
-		handleGroupRunCommand(subArgs)
+		a.handleGroupRunCommand(subArgs)
 	case "delete":
-		handleGroupDeleteCommand(subArgs)
+		a.handleGroupDeleteCommand(subArgs)
+	case "history":
+		a.handleGroupHistoryCommand(subArgs)
 	default:
 		fmt.Printf("Bilinmeyen alt komut: %s\n", subCmd)
-		fmt.Println("Kullanım: sysrow group <create|add|run|delete> [argümanlar]")
-		os.Exit(1)
+		fmt.Println("Kullanım: sysrow group <create|add|list|run|history|delete> [argümanlar]")
+		os.Exit(exitUsage)
 	}
 }
 
-func handleGroupCreateCommand(args []string) {
+func (a *app) handleGroupCreateCommand(args []string) {
 	if len(args) == 0 {
 		fmt.Println("Hata: Grup adı belirtilmedi")
 		fmt.Println("Kullanım: sysrow group create <grup_adı>")
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	groupName := args[0]
-	fmt.Printf("Grup oluşturuluyor: '%s'\n", groupName)
-	fmt.Println("Group create command not yet implemented")
+	g, err := a.groups.CreateGroup(groupName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Printf("Grup oluşturuldu: '%s' (ID: %s)\n", g.Name, g.ID)
 }
 
-func handleGroupAddCommand(args []string) {
-	if len(args) < 2 {
+func (a *app) handleGroupAddCommand(args []string) {
+	flags := flag.NewFlagSet("group add", flag.ExitOnError)
+	opts := addTaskFlags(flags)
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if len(positional) < 2 {
 		fmt.Println("Hata: Grup adı veya komut belirtilmedi")
-		fmt.Println("Kullanım: sysrow group add <grup_adı> <komut>")
-		os.Exit(1)
+		fmt.Println("Kullanım: sysrow group add [--priority=<öncelik>] [--timeout=<süre>] <grup_adı> <komut>")
+		os.Exit(exitUsage)
 	}
 
-	groupName := args[0]
-	command := args[1]
-	fmt.Printf("Gruba ekleniyor: '%s' -> '%s'\n", command, groupName)
-	fmt.Println("Group add command not yet implemented")
+	groupName := positional[0]
+	command := positional[1]
+
+	t, err := opts.newTask(command)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if err := a.groups.AddStep(groupName, group.StepFromTask(t)); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Printf("Gruba eklendi: '%s' -> '%s'\n", command, groupName)
 }
 
-func handleGroupRunCommand(args []string) {
-	if len(args) == 0 {
+func (a *app) handleGroupListCommand(args []string) {
+	flags := flag.NewFlagSet("group list", flag.ExitOnError)
+
+	if _, err := parseFlags(flags, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	printer := a.output
+
+	groups, err := a.groups.ListGroups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Gruplar listelenemedi: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+
+	if printer.Machine() {
+		printOutputList(printer, groups)
+		return
+	}
+
+	if len(groups) == 0 {
+		fmt.Println("Kayıtlı grup yok")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tSTEPS\tRUNS")
+	for _, g := range groups {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", g.Name, g.ID, len(g.Steps), g.RunCount)
+	}
+	w.Flush()
+}
+
+func (a *app) handleGroupRunCommand(args []string) {
+	flags := flag.NewFlagSet("group run", flag.ExitOnError)
+	parallel := flags.Int("parallel", 1, "Aynı anda çalışabilecek en fazla görev sayısı")
+	failFast := flags.Bool("fail-fast", false, "İlk başarısız görevden sonra kalan görevleri atla (varsayılan)")
+	continueOnError := flags.Bool("continue-on-error", false, "Başarısız görevlere rağmen kalan görevleri çalıştır")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if len(positional) == 0 {
 		fmt.Println("Hata: Grup adı belirtilmedi")
-		fmt.Println("Kullanım: sysrow group run <grup_adı>")
-		os.Exit(1)
+		fmt.Println("Kullanım: sysrow group run [--parallel=<n>] [--fail-fast|--continue-on-error] <grup_adı>")
+		os.Exit(exitUsage)
 	}
 
-	groupName := args[0]
-	fmt.Printf("Grup çalıştırılıyor: '%s'\n", groupName)
-	fmt.Println("Group run command not yet implemented")
+	if *failFast && *continueOnError {
+		fmt.Fprintln(os.Stderr, "Hata: --fail-fast ve --continue-on-error birlikte kullanılamaz")
+		os.Exit(exitUsage)
+	}
+
+	if *parallel < 1 {
+		fmt.Fprintln(os.Stderr, "Hata: --parallel en az 1 olmalı")
+		os.Exit(exitUsage)
+	}
+
+	groupName := positional[0]
+	run, err := a.groups.NewRun(groupName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	r := a.runner
+
+	// Ctrl-C cancels the running tasks, the rest of the group is then skipped
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	go func() {
+		for sig := range interrupt {
+			for _, id := range run.TaskIDs {
+				if t, err := a.store.LoadTask(id); err == nil && (t.Status == task.StatusRunning || t.Status == task.StatusPending) {
+					t.Cancel(sig.(syscall.Signal), r.KillGrace)
+				}
+			}
+		}
+	}()
+
+	fmt.Printf("Grup çalıştırılıyor: '%s' #%d (%d görev, paralel: %d)\n", groupName, run.Number, len(run.TaskIDs), *parallel)
+
+	err = a.groups.RunGroup(run, r, group.RunOptions{
+		Parallel:        *parallel,
+		ContinueOnError: *continueOnError,
+		OnFinish: func(t *task.Task, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+				return
+			}
+			fmt.Printf("  %s  %-9s  %s\n", t.ID, t.Status, t.Command)
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Printf("Özet: %d başarılı, %d başarısız, %d atlandı, süre %s\n", run.Succeeded, run.Failed, run.Skipped, run.Duration.Round(time.Millisecond))
+	if run.Status != group.RunSucceeded {
+		os.Exit(exitTaskFailed)
+	}
 }
 
-func handleGroupDeleteCommand(args []string) {
+func (a *app) handleGroupHistoryCommand(args []string) {
 	if len(args) == 0 {
 		fmt.Println("Hata: Grup adı belirtilmedi")
-		fmt.Println("Kullanım: sysrow group delete <grup_adı>")
-		os.Exit(1)
+		fmt.Println("Kullanım: sysrow group history <grup_adı> [çalıştırma_no]")
+		os.Exit(exitUsage)
 	}
 
 	groupName := args[0]
-	fmt.Printf("Grup siliniyor: '%s'\n", groupName)
-	fmt.Println("Group delete command not yet implemented")
-}
 
-func handleListCommand(args []string) {
-	fmt.Println("Görevler listeleniyor...")
-	fmt.Println("List command not yet implemented")
+	// With a run number, show the tasks of that run
+	if len(args) > 1 {
+		n, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: Geçersiz çalıştırma numarası: %s\n", args[1])
+			os.Exit(exitUsage)
+		}
+
+		run, err := a.groups.GetRun(groupName, n)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+		if a.output.Machine() {
+			printOutput(a.output, run)
+			return
+		}
+		a.printGroupRun(run)
+		return
+	}
+
+	history, err := a.groups.History(groupName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	if a.output.Machine() {
+		printOutputList(a.output, history)
+		return
+	}
+
+	if len(history) == 0 {
+		fmt.Printf("'%s' grubu henüz çalıştırılmadı\n", groupName)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tSTATUS\tSTARTED\tDURATION\tSUCCEEDED\tFAILED\tSKIPPED\tID")
+	for _, run := range history {
+		duration := "-"
+		if run.FinishedAt != nil {
+			duration = run.Duration.Round(time.Millisecond).String()
+		}
+
+		fmt.Fprintf(w, "#%d\t%s\t%s\t%s\t%d\t%d\t%d\t%s\n", run.Number, run.Status, run.StartedAt.Format("2006-01-02 15:04:05"), duration, run.Succeeded, run.Failed, run.Skipped, run.ID)
+	}
+	w.Flush()
 }
 
-func handleStatusCommand(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Hata: Görev ID'si belirtilmedi")
-		fmt.Println("Kullanım: sysrow status <görev_id>")
-		os.Exit(1)
+// printGroupRun prints a run of a group with the outcome of each of its tasks
+func (a *app) printGroupRun(run *group.GroupRun) {
+	fmt.Printf("Grup: %s, çalıştırma #%d (%s)\n", run.GroupName, run.Number, run.Status)
+	fmt.Printf("Başlangıç: %s", run.StartedAt.Format("2006-01-02 15:04:05"))
+	if run.FinishedAt != nil {
+		fmt.Printf(", süre: %s", run.Duration.Round(time.Millisecond))
 	}
+	fmt.Printf("\nÖzet: %d başarılı, %d başarısız, %d atlandı\n\n", run.Succeeded, run.Failed, run.Skipped)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tID\tSTATUS\tEXIT\tCOMMAND")
+	for i, id := range run.TaskIDs {
+		t, err := a.store.LoadTask(id)
+		if err != nil {
+			fmt.Fprintf(w, "%d\t%s\t?\t-\t-\n", i+1, id)
+			continue
+		}
 
-	taskID := args[0]
-	fmt.Printf("Görev durumu kontrol ediliyor: '%s'\n", taskID)
-	fmt.Println("Status command not yet implemented")
+		exitCode := "-"
+		if t.ExitCode != nil {
+			exitCode = strconv.Itoa(*t.ExitCode)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i+1, t.ID, t.Status, exitCode, t.Command)
+	}
+	w.Flush()
 }
 
-func handleLogsCommand(args []string) {
+func (a *app) handleGroupDeleteCommand(args []string) {
 	if len(args) == 0 {
-		fmt.Println("Hata: Görev ID'si belirtilmedi")
-		fmt.Println("Kullanım: sysrow logs <görev_id>")
-		os.Exit(1)
+		fmt.Println("Hata: Grup adı belirtilmedi")
+		fmt.Println("Kullanım: sysrow group delete <grup_adı>")
+		os.Exit(exitUsage)
 	}
 
-	taskID := args[0]
-	fmt.Printf("Görev günlükleri görüntüleniyor: '%s'\n", taskID)
-	fmt.Println("Logs command not yet implemented")
+	groupName := args[0]
+	if err := a.groups.DeleteGroup(groupName); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Printf("Grup silindi: '%s'\n", groupName)
 }
 
-func handleCancelCommand(args []string) {
-	if len(args) == 0 {
+func (a *app) handleCancelCommand(args []string) {
+	flags := flag.NewFlagSet("cancel", flag.ExitOnError)
+	signalName := flags.String("signal", "TERM", "Görevin süreç grubuna gönderilecek sinyal (TERM, INT, HUP, KILL veya numara)")
+	grace := flags.String("grace", a.config.KillGrace, "SIGKILL gönderilmeden önce beklenecek süre")
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if len(positional) == 0 {
 		fmt.Println("Hata: Görev ID'si belirtilmedi")
-		fmt.Println("Kullanım: sysrow cancel <görev_id>")
-		os.Exit(1)
+		fmt.Println("Kullanım: sysrow cancel [--signal=<sinyal>] [--grace=<süre>] <görev_id>")
+		os.Exit(exitUsage)
+	}
+	taskID := positional[0]
+
+	sig, err := proc.ParseSignal(*signalName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	gracePeriod, err := schedule.ParseDuration(*grace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
 	}
 
-	taskID := args[0]
-	fmt.Printf("Görev iptal ediliyor: '%s'\n", taskID)
-	fmt.Println("Cancel command not yet implemented")
+	t := a.resolveTask(taskID)
+
+	if err := t.Cancel(sig, gracePeriod); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	if a.output.Machine() {
+		printOutput(a.output, t)
+		return
+	}
+
+	fmt.Println(i18n.GetWithFormat("cli_messages.task_cancelled", t.ID))
+	if t.Signal != "" {
+		fmt.Printf("Sonlandıran sinyal: %s\n", t.Signal)
+	}
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Can/sysrow/pkg/output"
+)
+
+// splitOutputFlags removes --output and --format, which choose how a command
+// prints its result for scripts, from the arguments of any command. Like
+// --lang they are global. Arguments after "--" are left alone, and export and
+// import keep --format for the format of their files.
+func splitOutputFlags(cmd string, args []string) (format, template string, rest []string, err error) {
+	if cmd == "export" || cmd == "import" {
+		return "", "", args, nil
+	}
+
+	rest = make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+
+		var target *string
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+		switch {
+		case !strings.HasPrefix(arg, "-"):
+		case name == "output":
+			target = &format
+		case name == "format":
+			target = &template
+		}
+		if target == nil {
+			rest = append(rest, arg)
+			continue
+		}
+
+		if !hasValue {
+			if i+1 == len(args) {
+				return "", "", nil, fmt.Errorf("flag needs an argument: %s", arg)
+			}
+			i++
+			value = args[i]
+		}
+		*target = value
+	}
+
+	return format, template, rest, nil
+}
+
+// printOutput prints a single value in the chosen format
+func printOutput(p *output.Printer, v interface{}) {
+	if err := p.Print(os.Stdout, v); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+// printOutputList prints the items of a slice in the chosen format
+func printOutputList(p *output.Printer, items interface{}) {
+	if err := p.List(os.Stdout, items); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitError)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Can/sysrow/pkg/retention"
+)
+
+func (a *app) handlePruneCommand(args []string) {
+	flags := flag.NewFlagSet("prune", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "Silmeden yalnızca silinecek görevleri listele")
+	keepLast := flags.Int("keep-last", -1, "Her grubun ve tekrarlanan görevin son N çalıştırmasını sakla")
+	maxAge := flags.String("max-age", "", "Biten görevleri bu süreden sonra sil (örn. 7d veya completed=7d,failed=30d)")
+	maxLogSize := flags.String("max-log-size", "", "Görev günlüklerinin toplam boyut sınırı (örn. 500M)")
+
+	if _, err := parseFlags(flags, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	// The options override the policy of config.json
+	policy, err := a.config.RetentionPolicy()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	if *keepLast >= 0 {
+		policy.KeepLast = *keepLast
+	}
+	if *maxAge != "" {
+		if policy.MaxAge, err = retention.ParseMaxAge(*maxAge); err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+	if *maxLogSize != "" {
+		if policy.MaxLogSize, err = retention.ParseSize(*maxLogSize); err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+
+	if policy.IsEmpty() {
+		fmt.Println("Hata: Saklama politikası belirtilmedi")
+		fmt.Println("Kullanım: sysrow prune [--dry-run] [--keep-last <n>] [--max-age <süre>|<durum>=<süre>,...] [--max-log-size <boyut>]")
+		fmt.Println("Politika config.json dosyasındaki \"retention\" ayarıyla da belirlenebilir")
+		os.Exit(exitUsage)
+	}
+
+	result, err := retention.NewPruner(a.dataDir, a.store).Prune(policy, time.Now(), *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	if len(result.Tasks) == 0 && len(result.Runs) == 0 {
+		fmt.Println("Silinecek görev yok")
+		return
+	}
+
+	if len(result.Tasks) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATUS\tFINISHED\tLOGS\tREASON")
+		for _, c := range result.Tasks {
+			finished := "-"
+			if c.Task.FinishedAt != nil {
+				finished = c.Task.FinishedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Task.ID, c.Task.Status, finished, retention.FormatSize(c.LogSize), c.Reason)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+
+	if *dryRun {
+		fmt.Printf("%d görev ve %d grup çalıştırma kaydı silinecek, %s günlük boşalacak (deneme, hiçbir şey silinmedi)\n", len(result.Tasks), len(result.Runs), retention.FormatSize(result.LogSize))
+		return
+	}
+	fmt.Printf("%d görev ve %d grup çalıştırma kaydı silindi, %s günlük boşaltıldı\n", len(result.Tasks), len(result.Runs), retention.FormatSize(result.LogSize))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Can/sysrow/pkg/queue"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// addQueueFlag registers --on, the named queue a task is added to
+func addQueueFlag(flags *flag.FlagSet) *string {
+	return flags.String("on", "", "Görevin ekleneceği kuyruk (varsayılan: default)")
+}
+
+// parseQueueName checks the value of --on and returns the queue name to
+// store in the task, which is empty for the default queue
+func parseQueueName(name string) (string, error) {
+	if name == "" || name == queue.DefaultName {
+		return "", nil
+	}
+
+	if err := queue.ValidateName(name); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// handleQueuePauseCommand pauses or resumes a named queue
+func (a *app) handleQueuePauseCommand(action, name string) {
+	if err := queue.ValidateName(name); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if action == "pause" {
+		changed, err := queue.Pause(a.dataDir, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if !changed {
+			fmt.Printf("'%s' kuyruğu zaten duraklatılmış\n", name)
+			return
+		}
+		fmt.Printf("'%s' kuyruğu duraklatıldı, çalışan görevler tamamlanacak ancak yeni görev başlatılmayacak\n", name)
+		return
+	}
+
+	changed, err := queue.Resume(a.dataDir, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if !changed {
+		fmt.Printf("'%s' kuyruğu duraklatılmamış\n", name)
+		return
+	}
+	fmt.Printf("'%s' kuyruğu devam ettirildi\n", name)
+}
+
+// warnIfQueuePaused reminds the user that a task added to a paused queue
+// waits until the queue is resumed
+func (a *app) warnIfQueuePaused(t *task.Task) {
+	state, err := queue.LoadState(a.dataDir)
+	if err != nil {
+		return
+	}
+
+	name := queue.NameOf(t)
+	if _, paused := state.Paused[name]; paused {
+		fmt.Printf("Not: '%s' kuyruğu duraklatılmış. Görev 'sysrow queue resume %s' komutundan sonra çalışacak.\n", name, name)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Can/sysrow/pkg/cron"
+	"github.com/Can/sysrow/pkg/output"
+	"github.com/Can/sysrow/pkg/recurring"
+	"github.com/Can/sysrow/pkg/schedule"
+)
+
+func (a *app) handleEveryCommand(args []string) {
+	flags := flag.NewFlagSet("every", flag.ExitOnError)
+	every := flags.String("every", "", "Sabit aralıkla tekrarla (15m, 2h, 1d gibi)")
+	opts := addTaskFlags(flags)
+	on := addQueueFlag(flags)
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	// With --every only the command is given, otherwise the cron expression comes first
+	var cronExpr, command string
+	switch {
+	case *every != "" && len(positional) == 1:
+		command = positional[0]
+	case *every == "" && len(positional) == 2:
+		cronExpr, command = positional[0], positional[1]
+	default:
+		fmt.Println("Hata: Zamanlama veya komut belirtilmedi")
+		fmt.Println("Kullanım: sysrow every \"<cron ifadesi>\" <komut>")
+		fmt.Println("          sysrow every --every=<süre> <komut>")
+		os.Exit(exitUsage)
+	}
+
+	queueName, err := parseQueueName(*on)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	a.createRecurring(command, opts, queueName, cronExpr, *every, a.output)
+}
+
+// createRecurring validates the schedule and stores a new recurring
+// definition whose tasks go to the given queue
+func (a *app) createRecurring(command string, opts *taskFlags, queueName, cronExpr, every string, printer *output.Printer) {
+	template, err := opts.newTask(command)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	template.Queue = queueName
+
+	var interval time.Duration
+	if every != "" {
+		if interval, err = schedule.ParseDuration(every); err != nil {
+			fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	} else if _, err := cron.Parse(cronExpr); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	r, err := a.recurring.Create(template, cronExpr, interval)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Tekrarlanan görev oluşturulamadı: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	if printer.Machine() {
+		printOutput(printer, r)
+		return
+	}
+
+	fmt.Printf("Tekrarlanan görev oluşturuldu. ID: %s\n", r.ID)
+	fmt.Printf("Zamanlama: %s, ilk çalışma: %s\n", r.Schedule(), r.NextRunAt.Format("2006-01-02 15:04:05 MST"))
+	a.warnIfDaemonStopped()
+}
+
+func (a *app) handleRecurringCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Hata: Alt komut belirtilmedi")
+		fmt.Println("Kullanım: sysrow recurring <list|pause|resume|delete> [id]")
+		os.Exit(exitUsage)
+	}
+
+	subCmd := args[0]
+
+	if subCmd == "list" {
+		listRecurring(a.recurring)
+		return
+	}
+
+	if len(args) < 2 {
+		fmt.Println("Hata: Tekrarlanan görev ID'si belirtilmedi")
+		fmt.Printf("Kullanım: sysrow recurring %s <id>\n", subCmd)
+		os.Exit(exitUsage)
+	}
+	id := args[1]
+
+	var err error
+	switch subCmd {
+	case "pause":
+		if _, err = a.recurring.Pause(id); err == nil {
+			fmt.Printf("Tekrarlanan görev duraklatıldı: %s\n", id)
+		}
+	case "resume":
+		var r *recurring.Recurring
+		if r, err = a.recurring.Resume(id); err == nil {
+			fmt.Printf("Tekrarlanan görev devam ettirildi: %s (sonraki çalışma: %s)\n", id, r.NextRunAt.Format("2006-01-02 15:04:05 MST"))
+		}
+	case "delete":
+		if err = a.recurring.Delete(id); err == nil {
+			fmt.Printf("Tekrarlanan görev silindi: %s\n", id)
+		}
+	default:
+		fmt.Printf("Bilinmeyen alt komut: %s\n", subCmd)
+		fmt.Println("Kullanım: sysrow recurring <list|pause|resume|delete> [id]")
+		os.Exit(exitUsage)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// listRecurring prints all recurring definitions as a table
+func listRecurring(manager *recurring.Manager) {
+	list, err := manager.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: Tekrarlanan görevler listelenemedi: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	if len(list) == 0 {
+		fmt.Println("Tekrarlanan görev yok")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSCHEDULE\tSTATE\tNEXT RUN\tRUNS\tCOMMAND")
+	for _, r := range list {
+		state := "active"
+		next := "-"
+		if r.Paused {
+			state = "paused"
+		} else if r.NextRunAt != nil {
+			next = r.NextRunAt.Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", r.ID, r.Schedule(), state, next, r.RunCount, r.Command)
+	}
+	w.Flush()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Can/sysrow/pkg/task"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+func (a *app) handleStatusCommand(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if len(positional) == 0 {
+		fmt.Println("Hata: Görev ID'si belirtilmedi")
+		fmt.Println("Kullanım: sysrow status [--output=<biçim>] <görev_id>")
+		os.Exit(exitUsage)
+	}
+	printer := a.output
+
+	taskID := positional[0]
+	t := a.resolveTask(taskID)
+
+	if printer.Machine() {
+		printOutput(printer, t)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%s\n", t.ID)
+	if t.Name != "" {
+		fmt.Fprintf(w, "Ad:\t%s\n", t.Name)
+	}
+	fmt.Fprintf(w, "Komut:\t%s\n", t.Command)
+	fmt.Fprintf(w, "Durum:\t%s\n", t.Status)
+	fmt.Fprintf(w, "Öncelik:\t%s\n", t.Priority)
+	if t.Queue != "" {
+		fmt.Fprintf(w, "Kuyruk:\t%s\n", t.Queue)
+	}
+	fmt.Fprintf(w, "Oluşturulma:\t%s\n", t.CreatedAt.Format(timeLayout))
+	if t.ScheduledAt != nil {
+		fmt.Fprintf(w, "Zamanlanan:\t%s\n", t.ScheduledAt.Format(timeLayout))
+	}
+	if t.StartedAt != nil {
+		fmt.Fprintf(w, "Başlangıç:\t%s\n", t.StartedAt.Format(timeLayout))
+	}
+	if t.FinishedAt != nil {
+		fmt.Fprintf(w, "Bitiş:\t%s\n", t.FinishedAt.Format(timeLayout))
+	}
+	if t.ExitCode != nil {
+		fmt.Fprintf(w, "Çıkış kodu:\t%d\n", *t.ExitCode)
+	}
+	if t.Signal != "" {
+		fmt.Fprintf(w, "Sinyal:\t%s\n", t.Signal)
+	}
+	if t.PID != nil {
+		fmt.Fprintf(w, "PID:\t%d\n", *t.PID)
+	}
+	if t.Timeout > 0 {
+		fmt.Fprintf(w, "Zaman aşımı:\t%s\n", t.Timeout)
+	}
+	if len(t.DependsOn) > 0 {
+		fmt.Fprintf(w, "Bağımlılıklar:\t%s (%s)\n", strings.Join(t.DependsOn, ", "), t.Condition)
+	}
+	if t.Retry != nil {
+		fmt.Fprintf(w, "Yeniden deneme:\t%s\n", describeRetry(t.Retry))
+	}
+	w.Flush()
+
+	if len(t.Attempts) > 0 {
+		fmt.Println()
+		printAttempts(t)
+	}
+}
+
+// describeRetry summarizes a retry policy on one line
+func describeRetry(p *task.RetryPolicy) string {
+	desc := fmt.Sprintf("en fazla %d kez, %s, %s", p.MaxRetries, p.Backoff, p.Delay)
+	if len(p.OnExitCodes) > 0 {
+		codes := make([]string, len(p.OnExitCodes))
+		for i, code := range p.OnExitCodes {
+			codes[i] = strconv.Itoa(code)
+		}
+		desc += ", çıkış kodları " + strings.Join(codes, ",")
+	}
+
+	return desc
+}
+
+// printAttempts prints the attempt timeline of a task, including the
+// attempt that is running or waiting to run
+func printAttempts(t *task.Task) {
+	fmt.Println("Denemeler:")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tSTATUS\tSTARTED\tDURATION\tEXIT\tSIGNAL\tLOGS")
+	for _, a := range t.Attempts {
+		exitCode := "-"
+		if a.ExitCode != nil {
+			exitCode = strconv.Itoa(*a.ExitCode)
+		}
+		signal := "-"
+		if a.Signal != "" {
+			signal = a.Signal
+		}
+
+		duration := a.FinishedAt.Sub(a.StartedAt).Round(time.Millisecond)
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", a.Number, a.Status, a.StartedAt.Format(timeLayout), duration, exitCode, signal, strings.TrimSuffix(a.StdoutLog, ".stdout.log")+".{stdout,stderr}.log")
+	}
+
+	switch {
+	case t.Status == task.StatusRunning && t.StartedAt != nil:
+		fmt.Fprintf(w, "%d\t%s\t%s\t-\t-\t-\t-\n", t.Attempt(), t.Status, t.StartedAt.Format(timeLayout))
+	case t.Status == task.StatusPending && t.ScheduledAt != nil:
+		fmt.Fprintf(w, "%d\twaiting\t%s\t-\t-\t-\t-\n", t.Attempt(), t.ScheduledAt.Format(timeLayout))
+	}
+	w.Flush()
+}
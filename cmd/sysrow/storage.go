@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Can/sysrow/pkg/config"
+	"github.com/Can/sysrow/pkg/daemon"
+	"github.com/Can/sysrow/pkg/lock"
+	"github.com/Can/sysrow/pkg/storage"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+func (a *app) handleStorageCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Hata: Alt komut belirtilmedi")
+		fmt.Println("Kullanım: sysrow storage migrate --to <json|log>")
+		os.Exit(exitUsage)
+	}
+
+	switch args[0] {
+	case "migrate":
+		a.handleStorageMigrate(args[1:])
+	default:
+		fmt.Printf("Bilinmeyen alt komut: %s\n", args[0])
+		fmt.Println("Kullanım: sysrow storage migrate --to <json|log>")
+		os.Exit(exitUsage)
+	}
+}
+
+// handleStorageMigrate moves all tasks to another storage backend
+func (a *app) handleStorageMigrate(args []string) {
+	flags := flag.NewFlagSet("storage migrate", flag.ExitOnError)
+	to := flags.String("to", "", "Hedef depolama altyapısı (json, log)")
+
+	if _, err := parseFlags(flags, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Argüman ayrıştırma hatası: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if *to == "" {
+		fmt.Println("Hata: Hedef belirtilmedi")
+		fmt.Println("Kullanım: sysrow storage migrate --to <json|log>")
+		os.Exit(exitUsage)
+	}
+
+	// A running daemon would keep writing to the old backend
+	if pid, running := daemon.Status(a.dataDir); running {
+		fmt.Fprintf(os.Stderr, "Hata: Daemon çalışıyor (PID %d), önce durdurun: sysrow daemon stop\n", pid)
+		os.Exit(exitError)
+	}
+
+	l, err := lock.DataDir(a.dataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	defer l.Release()
+
+	cfg, err := config.Load(a.dataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if cfg.Storage == *to {
+		fmt.Printf("Görevler zaten '%s' altyapısında\n", *to)
+		return
+	}
+
+	// A running task is saved to the old backend when it finishes, and so is
+	// a task an executor has claimed but not started yet
+	tasks, err := a.store.ListTasks()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	busy := 0
+	for _, t := range tasks {
+		if t.Status == task.StatusRunning || task.Claimed(t.ID) {
+			busy++
+		}
+	}
+	if busy > 0 {
+		fmt.Fprintf(os.Stderr, "Hata: %d görev çalışıyor, bitmelerini bekleyin veya iptal edin: sysrow list --status running\n", busy)
+		os.Exit(exitError)
+	}
+
+	target, err := storage.Open(a.dataDir, *to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	count, err := storage.Migrate(a.store, target.(storage.Importer))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	from := cfg.Storage
+	cfg.Storage = *to
+	if err := cfg.Save(a.dataDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Hata: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Printf("%d görev '%s' altyapısından '%s' altyapısına taşındı\n", count, from, *to)
+}
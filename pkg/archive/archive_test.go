@@ -0,0 +1,363 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Can/sysrow/pkg/logger"
+	"github.com/Can/sysrow/pkg/schema"
+	"github.com/Can/sysrow/pkg/storage"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+const (
+	buildID  = "11111111-1111-1111-1111-111111111111"
+	deployID = "22222222-2222-2222-2222-222222222222"
+)
+
+// newTask returns a finished task with a fixed ID
+func newTask(id, command string, status task.TaskStatus, created time.Time) *task.Task {
+	t := task.NewTask(command, task.PriorityNormal)
+	t.ID = id
+	t.Status = status
+	t.CreatedAt = created
+	finished := created.Add(time.Minute)
+	t.FinishedAt = &finished
+	return t
+}
+
+// writeLogs writes log files of a task, keyed by the name without the task ID
+func writeLogs(t *testing.T, dataDir, id string, logs map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dataDir, "logs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range logs {
+		if err := os.WriteFile(filepath.Join(dataDir, "logs", id+"."+name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readLogs reads the log files of a task, keyed by the name without the task ID
+func readLogs(t *testing.T, dataDir, id string) map[string]string {
+	t.Helper()
+	files, err := logger.TaskLogFiles(dataDir, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := make(map[string]string)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logs[logName(id, file)] = string(data)
+	}
+	return logs
+}
+
+// export writes the tasks of a source data directory in a format. The gzip
+// format is a gzip compressed tar export.
+func export(t *testing.T, format, dataDir string, store task.Store) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+
+	exportFormat := format
+	if format == "gzip" {
+		exportFormat = FormatTar
+	}
+	n, err := Export(&buf, exportFormat, dataDir, store, time.Time{})
+	if err != nil {
+		t.Fatalf("Export(%s) error = %v", format, err)
+	}
+	if n != 2 {
+		t.Fatalf("Export(%s) = %d, want 2 finished tasks", format, n)
+	}
+
+	if format == "gzip" {
+		var gz bytes.Buffer
+		w := gzip.NewWriter(&gz)
+		w.Write(buf.Bytes())
+		w.Close()
+		return &gz
+	}
+	return &buf
+}
+
+func TestExportImport(t *testing.T) {
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// Output that is not UTF-8 and a log longer than a JSONL record
+	buildLogs := map[string]string{
+		"stdout.log": "ok \xff\xfe\x00 done\n",
+		"stderr.log": "",
+		"app.log":    strings.Repeat("line\n", logChunkSize/5+10),
+	}
+	deployLogs := map[string]string{
+		"attempt-1.stderr.log": "first try failed\n",
+		"stderr.log":           "failed again\n",
+	}
+
+	source := t.TempDir()
+	sourceStore := storage.NewStorage(source)
+	build := newTask(buildID, "make", task.StatusCompleted, created)
+	deploy := newTask(deployID, "deploy", task.StatusFailed, created.Add(time.Hour))
+	deploy.DependsOn = []string{buildID}
+	deploy.Attempts = []task.Attempt{{
+		Number:    1,
+		Status:    task.StatusFailed,
+		StderrLog: filepath.Join(source, "logs", deployID+".attempt-1.stderr.log"),
+	}}
+	pending := task.NewTask("later", task.PriorityNormal)
+	for _, tk := range []*task.Task{build, deploy, pending} {
+		if err := sourceStore.SaveTask(tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeLogs(t, source, buildID, buildLogs)
+	writeLogs(t, source, deployID, deployLogs)
+
+	tests := []struct {
+		name     string
+		conflict string
+		// existing is a task with the ID of build that is already stored
+		existing *task.Task
+		want     ImportResult
+		// wantBuild are the command and logs of the build task afterwards
+		wantBuild     string
+		wantBuildLogs map[string]string
+	}{
+		{
+			name:          "empty data directory",
+			conflict:      ConflictSkip,
+			want:          ImportResult{Imported: 2},
+			wantBuild:     "make",
+			wantBuildLogs: buildLogs,
+		},
+		{
+			name:          "skip",
+			conflict:      ConflictSkip,
+			existing:      newTask(buildID, "local", task.StatusFailed, created),
+			want:          ImportResult{Imported: 1, Skipped: 1},
+			wantBuild:     "local",
+			wantBuildLogs: map[string]string{"stdout.log": "local\n", "extra.log": "local\n"},
+		},
+		{
+			name:          "overwrite",
+			conflict:      ConflictOverwrite,
+			existing:      newTask(buildID, "local", task.StatusFailed, created),
+			want:          ImportResult{Imported: 2, Overwritten: 1},
+			wantBuild:     "make",
+			wantBuildLogs: buildLogs,
+		},
+		{
+			name:          "overwrite keeps unfinished tasks",
+			conflict:      ConflictOverwrite,
+			existing:      newTask(buildID, "local", task.StatusRunning, created),
+			want:          ImportResult{Imported: 1, Skipped: 1},
+			wantBuild:     "local",
+			wantBuildLogs: map[string]string{"stdout.log": "local\n", "extra.log": "local\n"},
+		},
+		{
+			name:          "rename",
+			conflict:      ConflictRename,
+			existing:      newTask(buildID, "local", task.StatusFailed, created),
+			want:          ImportResult{Imported: 2},
+			wantBuild:     "local",
+			wantBuildLogs: map[string]string{"stdout.log": "local\n", "extra.log": "local\n"},
+		},
+	}
+
+	for _, format := range []string{FormatJSONL, FormatTar, "gzip"} {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				dataDir := t.TempDir()
+				store := storage.NewStorage(dataDir)
+				if tt.existing != nil {
+					if err := store.SaveTask(tt.existing); err != nil {
+						t.Fatal(err)
+					}
+					writeLogs(t, dataDir, buildID, map[string]string{"stdout.log": "local\n", "extra.log": "local\n"})
+				}
+
+				result, err := Import(export(t, format, source, sourceStore), importFormat(format), tt.conflict, dataDir, store)
+				if err != nil {
+					t.Fatalf("Import() error = %v", err)
+				}
+
+				renamed := result.Renamed
+				result.Renamed = nil
+				if !reflect.DeepEqual(*result, tt.want) {
+					t.Errorf("Import() = %+v, want %+v", *result, tt.want)
+				}
+
+				got, err := store.LoadTask(buildID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Command != tt.wantBuild {
+					t.Errorf("build command = %q, want %q", got.Command, tt.wantBuild)
+				}
+				if logs := readLogs(t, dataDir, buildID); !reflect.DeepEqual(logs, tt.wantBuildLogs) {
+					t.Errorf("build logs = %q, want %q", logs, tt.wantBuildLogs)
+				}
+
+				// Pending tasks are not history and are not exported
+				if _, err := store.LoadTask(pending.ID); !errors.Is(err, task.ErrNotFound) {
+					t.Errorf("pending task was imported, error = %v", err)
+				}
+
+				deployed, err := store.LoadTask(deployID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if logs := readLogs(t, dataDir, deployID); !reflect.DeepEqual(logs, deployLogs) {
+					t.Errorf("deploy logs = %q, want %q", logs, deployLogs)
+				}
+
+				if tt.conflict != ConflictRename {
+					if len(renamed) != 0 {
+						t.Errorf("Renamed = %v, want none", renamed)
+					}
+					if !reflect.DeepEqual(deployed.DependsOn, []string{buildID}) {
+						t.Errorf("deploy depends on %v, want %s", deployed.DependsOn, buildID)
+					}
+					return
+				}
+
+				// The renamed task keeps its logs and its dependents follow it
+				newID := renamed[buildID]
+				if len(renamed) != 1 || newID == "" || newID == buildID {
+					t.Fatalf("Renamed = %v, want a new ID for %s", renamed, buildID)
+				}
+				moved, err := store.LoadTask(newID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if moved.Command != "make" {
+					t.Errorf("renamed command = %q, want make", moved.Command)
+				}
+				if logs := readLogs(t, dataDir, newID); !reflect.DeepEqual(logs, buildLogs) {
+					t.Errorf("renamed logs = %q, want %q", logs, buildLogs)
+				}
+				if !reflect.DeepEqual(deployed.DependsOn, []string{newID}) {
+					t.Errorf("deploy depends on %v, want %s", deployed.DependsOn, newID)
+				}
+			})
+		}
+	}
+}
+
+// importFormat returns the import format of an export format of the test
+func importFormat(format string) string {
+	if format == "gzip" {
+		return FormatTar
+	}
+	return format
+}
+
+func TestImportRenameAttemptLogs(t *testing.T) {
+	source := t.TempDir()
+	sourceStore := storage.NewStorage(source)
+	deploy := newTask(deployID, "deploy", task.StatusFailed, time.Now())
+	deploy.Attempts = []task.Attempt{{
+		Number:    1,
+		Status:    task.StatusFailed,
+		StdoutLog: filepath.Join(source, "logs", deployID+".attempt-1.stdout.log"),
+		StderrLog: filepath.Join(source, "logs", deployID+".attempt-1.stderr.log"),
+	}}
+	sourceStore.SaveTask(deploy)
+
+	var buf bytes.Buffer
+	if _, err := Export(&buf, FormatJSONL, source, sourceStore, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir := t.TempDir()
+	store := storage.NewStorage(dataDir)
+	store.SaveTask(newTask(deployID, "local", task.StatusCompleted, time.Now()))
+
+	result, err := Import(&buf, FormatJSONL, ConflictRename, dataDir, store)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	newID := result.Renamed[deployID]
+	got, err := store.LoadTask(newID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{got.Attempts[0].StdoutLog, got.Attempts[0].StderrLog} {
+		if !strings.Contains(filepath.Base(path), newID) || strings.Contains(path, deployID) {
+			t.Errorf("attempt log %s is not named after %s", path, newID)
+		}
+	}
+}
+
+func TestImportRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "unfinished task",
+			format:  FormatJSONL,
+			input:   `{"task":{"id":"` + buildID + `","command":"make","status":"pending"}}`,
+			wantErr: "only finished tasks",
+		},
+		{
+			name:    "invalid task ID",
+			format:  FormatJSONL,
+			input:   `{"task":{"id":"../x","command":"make","status":"completed"}}`,
+			wantErr: "invalid task ID",
+		},
+		{
+			name:   "log name outside the logs directory",
+			format: FormatJSONL,
+			input: `{"task":{"id":"` + buildID + `","command":"make","status":"completed"}}` + "\n" +
+				`{"log":{"task_id":"` + buildID + `","name":"../../x.log","data":""}}`,
+			wantErr: "invalid log file name",
+		},
+		{
+			name:    "empty record",
+			format:  FormatJSONL,
+			input:   `{}`,
+			wantErr: "neither a task nor a log",
+		},
+		{
+			name:    "csv",
+			format:  FormatCSV,
+			wantErr: "cannot be imported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			_, err := Import(strings.NewReader(tt.input), tt.format, ConflictSkip, dataDir, storage.NewStorage(dataDir))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Import() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestImportTooNew(t *testing.T) {
+	dataDir := t.TempDir()
+	input := `{"task":{"id":"` + buildID + `","command":"make","status":"completed","schema_version":99}}`
+
+	_, err := Import(strings.NewReader(input), FormatJSONL, ConflictSkip, dataDir, storage.NewStorage(dataDir))
+	if !errors.Is(err, schema.ErrTooNew) {
+		t.Errorf("Import() error = %v, want ErrTooNew", err)
+	}
+}
@@ -0,0 +1,342 @@
+// Package archive exports the history of finished tasks together with their
+// logs to portable formats, and imports such an export into another data
+// directory.
+package archive
+
+import (
+	"archive/tar"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Can/sysrow/pkg/logger"
+	"github.com/Can/sysrow/pkg/schema"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// Export formats
+const (
+	// FormatJSONL writes one JSON record per task followed by records
+	// holding its logs in pieces
+	FormatJSONL = "jsonl"
+	// FormatCSV writes one row of metadata per task, without logs
+	FormatCSV = "csv"
+	// FormatTar writes a manifest, a JSON file per task and the log files
+	FormatTar = "tar"
+)
+
+// manifestFormat identifies the manifest of a tar export
+const manifestFormat = "sysrow-export"
+
+// Manifest is the first entry of a tar export
+type Manifest struct {
+	Format        string    `json:"format"`
+	SchemaVersion int       `json:"schema_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	Tasks         int       `json:"tasks"`
+}
+
+// logChunkSize is the most log data a JSONL record holds, so that neither
+// export nor import has to load a whole log
+const logChunkSize = 1 << 20
+
+// Record is a line of a JSONL export. It holds either a task or a piece of
+// one of its log files.
+type Record struct {
+	Task *task.Task `json:"task,omitempty"`
+	Log  *LogChunk  `json:"log,omitempty"`
+}
+
+// LogChunk is a piece of a log file. The pieces of a file follow each other
+// in order. Data is base64 encoded in JSON, so output that is not UTF-8 is
+// restored byte for byte.
+type LogChunk struct {
+	TaskID string `json:"task_id"`
+	// Name is the name of the log file without the task ID, e.g. stdout.log
+	// or attempt-1.stderr.log
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+// csvHeader are the columns of a CSV export
+var csvHeader = []string{
+	"id", "command", "status", "priority", "exit_code", "attempts",
+	"created_at", "started_at", "finished_at", "group_id", "run_id", "recurring_id",
+}
+
+// ParseFormat checks the name of an export format
+func ParseFormat(value string) (string, error) {
+	switch value {
+	case FormatJSONL, FormatCSV, FormatTar:
+		return value, nil
+	}
+
+	return "", fmt.Errorf("invalid format %q (expected jsonl, csv or tar)", value)
+}
+
+// Export writes the finished tasks created at or after since, oldest first,
+// and returns how many were written
+func Export(w io.Writer, format, dataDir string, store task.Store, since time.Time) (int, error) {
+	tasks, err := history(store, since)
+	if err != nil {
+		return 0, err
+	}
+
+	switch format {
+	case FormatJSONL:
+		err = exportJSONL(w, dataDir, tasks)
+	case FormatCSV:
+		err = exportCSV(w, tasks)
+	case FormatTar:
+		err = exportTar(w, dataDir, tasks)
+	default:
+		_, err = ParseFormat(format)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return len(tasks), nil
+}
+
+// history returns the finished tasks created at or after since, oldest first
+func history(store task.Store, since time.Time) ([]*task.Task, error) {
+	tasks, err := task.FindTasks(store, task.Filter{Since: since})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	finished := make([]*task.Task, 0, len(tasks))
+	for _, t := range tasks {
+		if t.Status.IsTerminal() {
+			finished = append(finished, t)
+		}
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].CreatedAt.Before(finished[j].CreatedAt)
+	})
+
+	return finished, nil
+}
+
+// exportJSONL writes a record per task, each followed by the records of its
+// log files
+func exportJSONL(w io.Writer, dataDir string, tasks []*task.Task) error {
+	encoder := json.NewEncoder(w)
+	for _, t := range tasks {
+		if err := encoder.Encode(Record{Task: t}); err != nil {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+
+		files, err := logger.TaskLogFiles(dataDir, t.ID)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := exportLogJSONL(encoder, t.ID, file); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// exportLogJSONL writes a log file as records of at most logChunkSize bytes.
+// An empty file is written as one empty record so that it is restored too.
+func exportLogJSONL(encoder *json.Encoder, taskID, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	// A log that is still being appended to is cut at its current size
+	r := io.LimitReader(f, info.Size())
+	buf := make([]byte, logChunkSize)
+	for first := true; ; first = false {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read log file: %w", err)
+		}
+		if n > 0 || first {
+			chunk := &LogChunk{TaskID: taskID, Name: logName(taskID, path), Data: buf[:n]}
+			if err := encoder.Encode(Record{Log: chunk}); err != nil {
+				return fmt.Errorf("failed to write record: %w", err)
+			}
+		}
+		if n < len(buf) {
+			return nil
+		}
+	}
+}
+
+// exportCSV writes a row of metadata per task
+func exportCSV(w io.Writer, tasks []*task.Task) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+
+	for _, t := range tasks {
+		row := []string{
+			t.ID,
+			t.Command,
+			string(t.Status),
+			string(t.Priority),
+			"",
+			strconv.Itoa(len(t.Attempts)),
+			formatTime(&t.CreatedAt),
+			formatTime(t.StartedAt),
+			formatTime(t.FinishedAt),
+			optional(t.GroupID),
+			optional(t.RunID),
+			optional(t.RecurringID),
+		}
+		if t.ExitCode != nil {
+			row[4] = strconv.Itoa(*t.ExitCode)
+		}
+
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write csv: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+
+	return nil
+}
+
+// exportTar writes the manifest, then every task followed by its log files.
+// Log files are streamed, they are never loaded as a whole.
+func exportTar(w io.Writer, dataDir string, tasks []*task.Task) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+
+	manifest, err := json.MarshalIndent(Manifest{
+		Format:        manifestFormat,
+		SchemaVersion: schema.Version,
+		ExportedAt:    now,
+		Tasks:         len(tasks),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := writeTarFile(tw, "manifest.json", now, manifest); err != nil {
+		return err
+	}
+
+	for _, t := range tasks {
+		data, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal task: %w", err)
+		}
+		if err := writeTarFile(tw, "tasks/"+t.ID+".json", t.CreatedAt, data); err != nil {
+			return err
+		}
+
+		files, err := logger.TaskLogFiles(dataDir, t.ID)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := copyTarFile(tw, "logs/"+filepath.Base(file), file); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write tar archive: %w", err)
+	}
+
+	return nil
+}
+
+// writeTarFile adds a file with the given contents to the archive
+func writeTarFile(tw *tar.Writer, name string, modTime time.Time, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write tar archive: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write tar archive: %w", err)
+	}
+
+	return nil
+}
+
+// copyTarFile adds a file from disk to the archive
+func copyTarFile(tw *tar.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write tar archive: %w", err)
+	}
+
+	// A log that is still being appended to is cut at the size in the header
+	if _, err := io.CopyN(tw, f, info.Size()); err != nil {
+		return fmt.Errorf("failed to write tar archive: %w", err)
+	}
+
+	return nil
+}
+
+// logName returns the name of a log file without the task ID
+func logName(taskID, path string) string {
+	return strings.TrimPrefix(filepath.Base(path), taskID+".")
+}
+
+// formatTime formats an optional time for CSV
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// optional returns the value of an optional string for CSV
+func optional(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
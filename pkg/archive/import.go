@@ -0,0 +1,327 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Can/sysrow/pkg/lock"
+	"github.com/Can/sysrow/pkg/logger"
+	"github.com/Can/sysrow/pkg/schema"
+	"github.com/Can/sysrow/pkg/task"
+	"github.com/google/uuid"
+)
+
+// What to do with an imported task whose ID exists already
+const (
+	// ConflictSkip keeps the existing task and ignores the imported one
+	ConflictSkip = "skip"
+	// ConflictOverwrite replaces the existing task and its logs, unless the
+	// task has not finished yet
+	ConflictOverwrite = "overwrite"
+	// ConflictRename imports the task under a new ID
+	ConflictRename = "rename"
+)
+
+// ImportResult counts what an import did
+type ImportResult struct {
+	Imported    int
+	Skipped     int
+	Overwritten int
+	// Renamed maps the ID of every renamed task to its new ID
+	Renamed map[string]string
+}
+
+// importer restores tasks and logs into a data directory
+type importer struct {
+	dataDir  string
+	store    task.Store
+	conflict string
+	result   *ImportResult
+
+	// ids maps the ID of every task seen so far to its ID in the store, or
+	// to an empty string if the task was skipped
+	ids map[string]string
+	// logs are the log files written so far, later pieces are appended
+	logs map[string]bool
+}
+
+// ParseConflict checks the name of a conflict policy
+func ParseConflict(value string) (string, error) {
+	switch value {
+	case ConflictSkip, ConflictOverwrite, ConflictRename:
+		return value, nil
+	}
+
+	return "", fmt.Errorf("invalid conflict policy %q (expected skip, overwrite or rename)", value)
+}
+
+// Import restores a JSONL or tar export into the data directory. Tar
+// archives may be gzip compressed.
+func Import(r io.Reader, format, conflict, dataDir string, store task.Store) (*ImportResult, error) {
+	l, err := lock.DataDir(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	imp := &importer{
+		dataDir:  dataDir,
+		store:    store,
+		conflict: conflict,
+		result:   &ImportResult{Renamed: make(map[string]string)},
+		ids:      make(map[string]string),
+		logs:     make(map[string]bool),
+	}
+
+	switch format {
+	case FormatJSONL:
+		err = imp.readJSONL(r)
+	case FormatTar:
+		err = imp.readTar(r)
+	case FormatCSV:
+		err = fmt.Errorf("csv exports hold no logs and cannot be imported, use jsonl or tar")
+	default:
+		_, err = ParseFormat(format)
+	}
+
+	return imp.result, err
+}
+
+// readJSONL imports the records of a JSONL export
+func (imp *importer) readJSONL(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(data))) > 0 {
+			var record Record
+			if err := json.Unmarshal(data, &record); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+
+			switch {
+			case record.Task != nil:
+				err = imp.addTask(record.Task)
+			case record.Log != nil:
+				err = imp.addLog(record.Log.TaskID, record.Log.Name, bytes.NewReader(record.Log.Data))
+			default:
+				err = fmt.Errorf("record has neither a task nor a log")
+			}
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read export: %w", err)
+		}
+	}
+}
+
+// readTar imports the entries of a tar export in the order they were written
+func (imp *importer) readTar(r io.Reader) error {
+	reader := bufio.NewReader(r)
+
+	// Exports piped through gzip are unpacked on the fly
+	var archive io.Reader = reader
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read gzip stream: %w", err)
+		}
+		defer gz.Close()
+		archive = gz
+	}
+
+	tr := tar.NewReader(archive)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		dir, name := path.Split(path.Clean(header.Name))
+		switch {
+		case dir == "" && name == "manifest.json":
+			if err := readManifest(tr); err != nil {
+				return err
+			}
+		case dir == "tasks/" && strings.HasSuffix(name, ".json"):
+			var t task.Task
+			if err := json.NewDecoder(tr).Decode(&t); err != nil {
+				return fmt.Errorf("%s: %w", header.Name, err)
+			}
+			if err := imp.addTask(&t); err != nil {
+				return fmt.Errorf("%s: %w", header.Name, err)
+			}
+		case dir == "logs/":
+			id, logName, found := strings.Cut(name, ".")
+			if !found {
+				continue
+			}
+			if err := imp.addLog(id, logName, tr); err != nil {
+				return fmt.Errorf("%s: %w", header.Name, err)
+			}
+		}
+	}
+}
+
+// readManifest checks that a tar archive is an export this sysrow can read
+func readManifest(r io.Reader) error {
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return fmt.Errorf("manifest.json: %w", err)
+	}
+
+	if manifest.Format != manifestFormat {
+		return fmt.Errorf("manifest.json: not a sysrow export")
+	}
+	if manifest.SchemaVersion > schema.Version {
+		return fmt.Errorf("%w: export has schema version %d, this sysrow supports up to %d", schema.ErrTooNew, manifest.SchemaVersion, schema.Version)
+	}
+
+	return nil
+}
+
+// addTask stores an imported task, applying the conflict policy if its ID
+// is taken
+func (imp *importer) addTask(t *task.Task) error {
+	if _, err := uuid.Parse(t.ID); err != nil {
+		return fmt.Errorf("invalid task ID %q", t.ID)
+	}
+	if err := schema.CheckRecord("task", t.ID, t.SchemaVersion); err != nil {
+		return err
+	}
+
+	// Only history is imported, a pending task would be run on this machine
+	if !t.Status.IsTerminal() {
+		return fmt.Errorf("task %s is %s, only finished tasks can be imported", t.ID, t.Status)
+	}
+
+	oldID := t.ID
+	existing, err := imp.store.LoadTask(t.ID)
+	switch {
+	case err == nil:
+		switch {
+		case imp.conflict == ConflictOverwrite && !existing.Status.IsTerminal():
+			// A pending or running task and its live logs are kept
+			imp.ids[oldID] = ""
+			imp.result.Skipped++
+			return nil
+		case imp.conflict == ConflictOverwrite:
+			// Logs of earlier attempts must not be mixed with the imported ones
+			if err := imp.removeLogs(t.ID); err != nil {
+				return err
+			}
+			imp.result.Overwritten++
+		case imp.conflict == ConflictRename:
+			t.ID = uuid.New().String()
+			imp.result.Renamed[oldID] = t.ID
+
+			// Attempts name their log files after the task
+			for i := range t.Attempts {
+				a := &t.Attempts[i]
+				a.StdoutLog = strings.Replace(a.StdoutLog, oldID, t.ID, 1)
+				a.StderrLog = strings.Replace(a.StderrLog, oldID, t.ID, 1)
+			}
+		default:
+			imp.ids[oldID] = ""
+			imp.result.Skipped++
+			return nil
+		}
+	case !errors.Is(err, task.ErrNotFound):
+		return err
+	}
+
+	// Dependencies on renamed tasks follow them
+	for i, id := range t.DependsOn {
+		if newID := imp.ids[id]; newID != "" {
+			t.DependsOn[i] = newID
+		}
+	}
+
+	if err := imp.store.SaveTask(t); err != nil {
+		return fmt.Errorf("failed to save task %s: %w", t.ID, err)
+	}
+	imp.ids[oldID] = t.ID
+	imp.result.Imported++
+
+	return nil
+}
+
+// addLog writes a log file of an imported task, or appends to it if an
+// earlier piece of the file was written. Logs of tasks that were skipped, or
+// that are not part of the export, are ignored.
+func (imp *importer) addLog(taskID, name string, r io.Reader) error {
+	newID := imp.ids[taskID]
+	if newID == "" {
+		return nil
+	}
+
+	// The name comes from the archive, it must not lead out of the logs directory
+	if name != filepath.Base(name) || strings.ContainsAny(name, `/\`) || !strings.HasSuffix(name, ".log") {
+		return fmt.Errorf("invalid log file name %q", name)
+	}
+
+	logsDir := filepath.Join(imp.dataDir, "logs")
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create logs directory: %w", err)
+	}
+
+	path := filepath.Join(logsDir, newID+"."+name)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if imp.logs[path] {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	imp.logs[path] = true
+
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %w", err)
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write log file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write log file: %w", err)
+	}
+
+	return nil
+}
+
+// removeLogs deletes the log files of a task that is overwritten
+func (imp *importer) removeLogs(taskID string) error {
+	files, err := logger.TaskLogFiles(imp.dataDir, taskID)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete log file: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Can/sysrow/pkg/fsutil"
+	"github.com/Can/sysrow/pkg/queue"
+	"github.com/Can/sysrow/pkg/retention"
+	"github.com/Can/sysrow/pkg/schedule"
+)
+
+// DefaultWorkers is the number of tasks the daemon runs at the same time by default
+const DefaultWorkers = 4
+
+// DefaultKillGrace is how long a task may take to exit after SIGTERM before it is killed
+const DefaultKillGrace = "10s"
+
+// DefaultStopTimeout is how long a stopping daemon waits for running tasks before it cancels them
+const DefaultStopTimeout = "5m"
+
+// Storage backends for tasks
+const (
+	// StorageJSON keeps every task in its own JSON file
+	StorageJSON = "json"
+	// StorageLog keeps all tasks in an append-only log with indexes
+	StorageLog = "log"
+)
+
+// Config holds user settings stored in config.json inside the data directory
+type Config struct {
+	// Workers is the maximum number of tasks that may run concurrently
+	Workers int `json:"workers"`
+	// ReservedHighSlots is the number of worker slots only high priority tasks may use
+	ReservedHighSlots int `json:"reserved_high_slots"`
+	// KillGrace is the time between SIGTERM and SIGKILL when a task is stopped
+	KillGrace string `json:"kill_grace"`
+	// StopTimeout is how long a stopping daemon waits for running tasks before it cancels them
+	StopTimeout string `json:"stop_timeout"`
+	// Storage is the task storage backend, changed with `sysrow storage migrate`
+	Storage string `json:"storage"`
+	// Retention decides which finished tasks `sysrow prune` and the daemon delete
+	Retention Retention `json:"retention"`
+	// Queues holds the settings of named queues, queues not listed here have no limit
+	Queues map[string]Queue `json:"queues,omitempty"`
+}
+
+// Queue holds the settings of a named queue
+type Queue struct {
+	// Concurrency is the number of tasks of the queue that may run at the same time
+	Concurrency int `json:"concurrency"`
+}
+
+// Retention is the retention policy as written in config.json, see retention.Policy
+type Retention struct {
+	// KeepLast keeps the last N runs of every group and tasks of every recurring task
+	KeepLast int `json:"keep_last,omitempty"`
+	// MaxAge maps a status, or "*" for all finished statuses, to how long tasks are kept
+	MaxAge map[string]string `json:"max_age,omitempty"`
+	// MaxLogSize limits the size of all task logs, e.g. "500M"
+	MaxLogSize string `json:"max_log_size,omitempty"`
+}
+
+// Default returns the configuration used when no config file exists
+func Default() *Config {
+	return &Config{
+		Workers:           DefaultWorkers,
+		ReservedHighSlots: 0,
+		KillGrace:         DefaultKillGrace,
+		StopTimeout:       DefaultStopTimeout,
+		Storage:           StorageJSON,
+	}
+}
+
+// Load reads the configuration from the data directory, falling back to defaults
+func Load(dataDir string) (*Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(configPath(dataDir))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Save writes the configuration to the data directory
+func (c *Config) Save(dataDir string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if err := fsutil.WriteFile(configPath(dataDir), data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	return nil
+}
+
+// Validate checks that the configuration values are usable
+func (c *Config) Validate() error {
+	if c.Workers < 1 {
+		return fmt.Errorf("workers must be at least 1, got %d", c.Workers)
+	}
+
+	if c.ReservedHighSlots < 0 || c.ReservedHighSlots >= c.Workers {
+		return fmt.Errorf("reserved high priority slots must be between 0 and %d, got %d", c.Workers-1, c.ReservedHighSlots)
+	}
+
+	if _, err := c.KillGracePeriod(); err != nil {
+		return err
+	}
+
+	if _, err := c.StopTimeoutPeriod(); err != nil {
+		return err
+	}
+
+	if _, err := c.RetentionPolicy(); err != nil {
+		return err
+	}
+
+	if c.Storage != StorageJSON && c.Storage != StorageLog {
+		return fmt.Errorf("storage must be %q or %q, got %q", StorageJSON, StorageLog, c.Storage)
+	}
+
+	for name, q := range c.Queues {
+		if err := queue.ValidateName(name); err != nil {
+			return err
+		}
+		if q.Concurrency < 1 {
+			return fmt.Errorf("concurrency of queue %s must be at least 1, got %d", name, q.Concurrency)
+		}
+	}
+
+	return nil
+}
+
+// KillGracePeriod returns the kill grace period as a duration
+func (c *Config) KillGracePeriod() (time.Duration, error) {
+	grace, err := schedule.ParseDuration(c.KillGrace)
+	if err != nil {
+		return 0, fmt.Errorf("invalid kill_grace: %w", err)
+	}
+
+	return grace, nil
+}
+
+// StopTimeoutPeriod returns the stop timeout as a duration
+func (c *Config) StopTimeoutPeriod() (time.Duration, error) {
+	timeout, err := schedule.ParseDuration(c.StopTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid stop_timeout: %w", err)
+	}
+
+	return timeout, nil
+}
+
+// RetentionPolicy returns the retention policy
+func (c *Config) RetentionPolicy() (*retention.Policy, error) {
+	if c.Retention.KeepLast < 0 {
+		return nil, fmt.Errorf("retention keep_last must not be negative, got %d", c.Retention.KeepLast)
+	}
+
+	policy := &retention.Policy{KeepLast: c.Retention.KeepLast}
+
+	var err error
+	if len(c.Retention.MaxAge) > 0 {
+		if policy.MaxAge, err = retention.MaxAges(c.Retention.MaxAge); err != nil {
+			return nil, fmt.Errorf("invalid retention max_age: %w", err)
+		}
+	}
+
+	if c.Retention.MaxLogSize != "" {
+		if policy.MaxLogSize, err = retention.ParseSize(c.Retention.MaxLogSize); err != nil {
+			return nil, fmt.Errorf("invalid retention max_log_size: %w", err)
+		}
+	}
+
+	return policy, nil
+}
+
+// QueueLimits returns the concurrency limit of every configured queue by name
+func (c *Config) QueueLimits() map[string]int {
+	limits := make(map[string]int, len(c.Queues))
+	for name, q := range c.Queues {
+		limits[name] = q.Concurrency
+	}
+
+	return limits
+}
+
+// configPath returns the path of the config file
+func configPath(dataDir string) string {
+	return filepath.Join(dataDir, "config.json")
+}
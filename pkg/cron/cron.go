@@ -0,0 +1,295 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	expr string
+
+	second uint64
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	// domStar and dowStar record whether the day fields were left
+	// unrestricted, which changes how they are combined
+	domStar bool
+	dowStar bool
+}
+
+// bounds describes the allowed values of a cron field
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondBounds = bounds{0, 59, nil}
+	minuteBounds = bounds{0, 59, nil}
+	hourBounds   = bounds{0, 23, nil}
+	domBounds    = bounds{1, 31, nil}
+	monthBounds  = bounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Both 0 and 7 mean Sunday
+	dowBounds = bounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// macros maps the supported shorthands to their six field equivalent
+var macros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// Parse parses a cron expression. Five fields (minute hour day-of-month month
+// day-of-week) and six fields (with a leading seconds field) are accepted,
+// as well as the @yearly, @monthly, @weekly, @daily and @hourly shorthands.
+// Fields support *, lists (1,2,3), ranges (1-5), steps (*/15, 0-30/10) and
+// month and weekday names (jan, mon).
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if strings.HasPrefix(spec, "@") {
+		expanded, ok := macros[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cron macro %q", spec)
+		}
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 or 6 fields, got %d", expr, len(fields))
+	}
+
+	s := &Schedule{expr: strings.TrimSpace(expr)}
+
+	var err error
+	if s.second, _, err = parseField(fields[0], secondBounds); err != nil {
+		return nil, fmt.Errorf("invalid seconds field: %w", err)
+	}
+	if s.minute, _, err = parseField(fields[1], minuteBounds); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if s.hour, _, err = parseField(fields[2], hourBounds); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if s.dom, s.domStar, err = parseField(fields[3], domBounds); err != nil {
+		return nil, fmt.Errorf("invalid day of month field: %w", err)
+	}
+	if s.month, _, err = parseField(fields[4], monthBounds); err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+	if s.dow, s.dowStar, err = parseField(fields[5], dowBounds); err != nil {
+		return nil, fmt.Errorf("invalid day of week field: %w", err)
+	}
+
+	// Fold 7 (Sunday) onto 0
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+
+	return s, nil
+}
+
+// String returns the expression the schedule was parsed from
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first activation time strictly after the given time.
+// It returns the zero time if the expression can never match (e.g. 30 February).
+func (s *Schedule) Next(after time.Time) time.Time {
+	// Start at the beginning of the next second
+	t := after.Add(time.Second - time.Duration(after.Nanosecond())*time.Nanosecond)
+	added := false
+	yearLimit := t.Year() + 5
+
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for !has(s.month, int(t.Month())) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		}
+		t = t.AddDate(0, 0, 1)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for !has(s.hour, t.Hour()) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for !has(s.minute, t.Minute()) {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for !has(s.second, t.Second()) {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	return t
+}
+
+// dayMatches applies the classic cron rule: when both day fields are
+// restricted a day matches if either of them does, otherwise both must match
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := has(s.dom, t.Day())
+	dowMatch := has(s.dow, int(t.Weekday()))
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// has reports whether bit n is set
+func has(bits uint64, n int) bool {
+	return bits&(1<<uint(n)) != 0
+}
+
+// parseField parses a single cron field into a bit set. The second return
+// value reports whether the field was an unrestricted * or ?.
+func parseField(field string, b bounds) (uint64, bool, error) {
+	if field == "" {
+		return 0, false, fmt.Errorf("empty field")
+	}
+
+	var bits uint64
+	star := false
+	for _, part := range strings.Split(field, ",") {
+		partBits, partStar, err := parsePart(part, b)
+		if err != nil {
+			return 0, false, err
+		}
+		bits |= partBits
+		star = star || partStar
+	}
+
+	return bits, star, nil
+}
+
+// parsePart parses one element of a list: *, a value, a range, each with an optional step
+func parsePart(part string, b bounds) (uint64, bool, error) {
+	rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+	step := 1
+	if hasStep {
+		var err error
+		step, err = strconv.Atoi(stepPart)
+		if err != nil || step < 1 {
+			return 0, false, fmt.Errorf("invalid step %q", stepPart)
+		}
+	}
+
+	var start, end int
+	star := false
+	switch {
+	case rangePart == "*" || rangePart == "?":
+		start, end = b.min, b.max
+		star = !hasStep
+	case strings.Contains(rangePart, "-"):
+		low, high, _ := strings.Cut(rangePart, "-")
+		var err error
+		if start, err = parseValue(low, b); err != nil {
+			return 0, false, err
+		}
+		if end, err = parseValue(high, b); err != nil {
+			return 0, false, err
+		}
+		if start > end {
+			return 0, false, fmt.Errorf("invalid range %q", rangePart)
+		}
+	default:
+		var err error
+		if start, err = parseValue(rangePart, b); err != nil {
+			return 0, false, err
+		}
+		// "5/15" means every 15 starting at 5
+		end = start
+		if hasStep {
+			end = b.max
+		}
+	}
+
+	var bits uint64
+	for v := start; v <= end; v += step {
+		bits |= 1 << uint(v)
+	}
+
+	return bits, star, nil
+}
+
+// parseValue parses a number or a name and checks it against the field bounds
+func parseValue(value string, b bounds) (int, error) {
+	if n, ok := b.names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+
+	if n < b.min || n > b.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", n, b.min, b.max)
+	}
+
+	return n, nil
+}
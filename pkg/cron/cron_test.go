@@ -0,0 +1,105 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// A Friday
+	after := time.Date(2026, 10, 16, 12, 34, 56, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 10, 16, 12, 45, 0, 0, time.UTC)},
+		{"35 12 * * *", time.Date(2026, 10, 16, 12, 35, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2026, 10, 16, 12, 45, 0, 0, time.UTC)},
+		{"0 0 * * *", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{"30 * * * * *", time.Date(2026, 10, 16, 12, 35, 30, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 16, 13, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 12 * * 5", time.Date(2026, 10, 23, 12, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// 7 and sun are Sunday as well as 0
+		{"0 0 * * 0", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		// Restricted day of month and day of week match if either does
+		{"0 0 13 * 5", time.Date(2026, 10, 23, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * *", time.Date(2026, 11, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Days that never exist give the zero time
+		{"0 0 30 2 *", time.Time{}},
+		{"0 0 31 4,6,9,11 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(after); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next(%s) = %s, want %s", tt.expr, after, got, tt.want)
+		}
+	}
+}
+
+func TestNextIsStrictlyAfter(t *testing.T) {
+	s, err := Parse("*/15 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := time.Date(2026, 10, 16, 12, 45, 0, 0, time.UTC)
+	want := time.Date(2026, 10, 16, 13, 0, 0, 0, time.UTC)
+	if got := s.Next(after); !got.Equal(want) {
+		t.Errorf("Next(%s) = %s, want %s", after, got, want)
+	}
+
+	// Fractions of a second do not count as a match of the current second
+	after = after.Add(-time.Millisecond)
+	want = time.Date(2026, 10, 16, 12, 45, 0, 0, time.UTC)
+	if got := s.Next(after); !got.Equal(want) {
+		t.Errorf("Next(%s) = %s, want %s", after, got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+		"@every",
+	}
+
+	for _, expr := range tests {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	s, err := Parse("  @daily ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.String(); got != "@daily" {
+		t.Errorf("String() = %q, want %q", got, "@daily")
+	}
+}
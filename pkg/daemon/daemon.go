@@ -0,0 +1,462 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Can/sysrow/pkg/config"
+	"github.com/Can/sysrow/pkg/dag"
+	"github.com/Can/sysrow/pkg/fsutil"
+	"github.com/Can/sysrow/pkg/lock"
+	"github.com/Can/sysrow/pkg/logger"
+	"github.com/Can/sysrow/pkg/pool"
+	"github.com/Can/sysrow/pkg/proc"
+	"github.com/Can/sysrow/pkg/queue"
+	"github.com/Can/sysrow/pkg/recovery"
+	"github.com/Can/sysrow/pkg/recurring"
+	"github.com/Can/sysrow/pkg/retention"
+	"github.com/Can/sysrow/pkg/runner"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// DefaultPollInterval is how often the daemon looks for new tasks on disk
+const DefaultPollInterval = 2 * time.Second
+
+// RetentionInterval is how often the daemon applies the retention policy
+const RetentionInterval = time.Hour
+
+// Daemon is the long-running scheduler that drains the task queues
+type Daemon struct {
+	dataDir      string
+	store        task.Store
+	pollInterval time.Duration
+	queues       *queue.Set
+	pool         *pool.Pool
+	recurring    *recurring.Manager
+	logger       *logger.Logger
+	retention    *retention.Policy
+	pruner       *retention.Pruner
+	// stopTimeout is how long a stop waits for running tasks before they are cancelled
+	stopTimeout time.Duration
+	killGrace   time.Duration
+
+	// wake is signalled when a task finishes so the next one can start early
+	wake chan struct{}
+}
+
+// NewDaemon creates a new daemon working on the given data directory and task store
+func NewDaemon(dataDir string, store task.Store, cfg *config.Config) (*Daemon, error) {
+	grace, err := cfg.KillGracePeriod()
+	if err != nil {
+		return nil, err
+	}
+
+	stopTimeout, err := cfg.StopTimeoutPeriod()
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := cfg.RetentionPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	r := runner.NewRunner(dataDir, store)
+	r.KillGrace = grace
+
+	workers, err := pool.NewPool(r, cfg.Workers, cfg.ReservedHighSlots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worker pool: %w", err)
+	}
+
+	return &Daemon{
+		dataDir:      dataDir,
+		store:        store,
+		pollInterval: DefaultPollInterval,
+		queues:       queue.NewSet(dataDir, store, cfg.QueueLimits()),
+		pool:         workers,
+		recurring:    recurring.NewManager(dataDir),
+		logger:       logger.NewLogger(dataDir),
+		retention:    policy,
+		pruner:       retention.NewPruner(dataDir, store),
+		stopTimeout:  stopTimeout,
+		killGrace:    grace,
+		wake:         make(chan struct{}, 1),
+	}, nil
+}
+
+// Run runs the scheduler loop in the foreground until SIGTERM or SIGINT is received
+func (d *Daemon) Run() error {
+	// Refuse to start a second daemon on the same data directory. The lock
+	// is held for the lifetime of the daemon and closes the gap between two
+	// daemons checking the PID file at the same time.
+	instance, err := lock.TryAcquire(filepath.Join(lock.Dir(d.dataDir), "daemon.lock"))
+	if err != nil {
+		if pid, running := Status(d.dataDir); running && pid != os.Getpid() {
+			return fmt.Errorf("daemon is already running with PID %d", pid)
+		}
+		return fmt.Errorf("daemon is already running: %w", err)
+	}
+	defer instance.Release()
+
+	if err := writePIDFile(d.dataDir, os.Getpid()); err != nil {
+		return err
+	}
+	defer os.Remove(pidFilePath(d.dataDir))
+
+	// The daemon must outlive the session that started it
+	signal.Ignore(syscall.SIGHUP)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(stop)
+
+	log.Printf("sysrow daemon started (PID %d, data directory %s)", os.Getpid(), d.dataDir)
+	log.Printf("worker pool: %d slots", d.pool.Size())
+	for _, q := range d.queues.Queues() {
+		log.Printf("queue %s: at most %d running task(s)", q.Name(), q.Limit())
+	}
+
+	// Files corrupted by a crash are set aside once, not on every load
+	corrupt, err := recovery.Quarantine(d.dataDir)
+	for _, c := range corrupt {
+		log.Printf("corrupt file %s moved to %s: %s", c.Path, c.MovedTo, c.Reason)
+	}
+	if err != nil {
+		log.Printf("failed to check for corrupt files: %v", err)
+	}
+
+	// Tasks that were running when a previous daemon died cannot be resumed
+	report, err := recovery.Reconcile(d.dataDir)
+	if err != nil {
+		log.Printf("failed to reconcile running tasks: %v", err)
+	} else {
+		for _, t := range report.Lost {
+			log.Printf("task %s marked as lost: %s", t.ID, report.Reasons[t.ID])
+		}
+	}
+
+	// The ticker picks up tasks added by other sysrow processes, the timer
+	// fires when the earliest delayed or recurring task becomes due
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	timer := time.NewTimer(d.pollInterval)
+	defer timer.Stop()
+
+	d.refresh()
+
+	// Pruning reads every task and log and must not hold up dispatching
+	done := make(chan struct{})
+	defer close(done)
+	go d.retain(done)
+
+	for {
+		d.dispatch()
+		d.resetTimer(timer)
+
+		select {
+		case sig := <-stop:
+			d.stop(sig)
+			return nil
+		case <-ticker.C:
+			d.refresh()
+		case <-timer.C:
+		case <-d.wake:
+			// A finished task may unblock the tasks depending on it
+			d.refresh()
+		}
+	}
+}
+
+// stop waits up to stopTimeout for the running tasks to finish and cancels
+// the ones that are still running then
+func (d *Daemon) stop(sig os.Signal) {
+	log.Printf("received %s, waiting up to %s for %d running task(s) to finish", sig, d.stopTimeout, d.pool.Running())
+	if d.pool.WaitTimeout(d.stopTimeout) {
+		log.Printf("sysrow daemon stopped")
+		return
+	}
+
+	for _, id := range d.pool.Tasks() {
+		// Cancel works on its own copy, the runner keeps the cancelled status
+		t, err := d.store.LoadTask(id)
+		if err != nil {
+			log.Printf("failed to load task %s: %v", id, err)
+			continue
+		}
+
+		log.Printf("task %s still running after %s, cancelling it", id, d.stopTimeout)
+		d.logger.LogInfo(id, "Task cancelled because the daemon stopped")
+		if err := t.Cancel(syscall.SIGTERM, d.killGrace); err != nil {
+			log.Printf("failed to cancel task %s: %v", id, err)
+		}
+	}
+
+	d.pool.Wait()
+	log.Printf("sysrow daemon stopped")
+}
+
+// refresh releases blocked tasks whose dependencies are resolved and
+// reloads the queues from disk
+func (d *Daemon) refresh() {
+	d.resolveDependencies()
+
+	if err := d.queues.Reload(); err != nil {
+		log.Printf("failed to reload queues: %v", err)
+	}
+}
+
+// retain applies the retention policy on start and every RetentionInterval
+// until done is closed
+func (d *Daemon) retain(done <-chan struct{}) {
+	if d.retention.IsEmpty() {
+		return
+	}
+
+	ticker := time.NewTicker(RetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		d.prune()
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune applies the retention policy once
+func (d *Daemon) prune() {
+	result, err := d.pruner.Prune(d.retention, time.Now(), false)
+	if err != nil {
+		log.Printf("failed to apply retention policy: %v", err)
+		return
+	}
+
+	if len(result.Tasks) > 0 || len(result.Runs) > 0 {
+		log.Printf("retention deleted %d task(s) and %d group run(s), freeing %s of logs", len(result.Tasks), len(result.Runs), retention.FormatSize(result.LogSize))
+	}
+}
+
+// resolveDependencies moves blocked tasks to pending or skipped
+func (d *Daemon) resolveDependencies() {
+	// Hold the data directory lock so a concurrent cancel is not overwritten
+	l, err := lock.DataDir(d.dataDir)
+	if err != nil {
+		log.Printf("%v", err)
+		return
+	}
+	defer l.Release()
+
+	tasks, err := d.store.ListTasks()
+	if err != nil {
+		log.Printf("failed to list tasks: %v", err)
+		return
+	}
+
+	for _, res := range dag.Resolve(tasks) {
+		t := res.Task
+		if current, err := d.store.LoadTask(t.ID); err != nil || current.Status != task.StatusBlocked {
+			continue
+		}
+		if err := d.store.SaveTask(t); err != nil {
+			log.Printf("failed to save task %s: %v", t.ID, err)
+			continue
+		}
+
+		if t.Status == task.StatusSkipped {
+			log.Printf("task %s skipped: %s", t.ID, res.Reason)
+			d.logger.LogInfo(t.ID, "Task skipped: "+res.Reason)
+		} else {
+			log.Printf("task %s unblocked", t.ID)
+			d.logger.LogInfo(t.ID, "Dependencies resolved, task is ready to run")
+		}
+	}
+}
+
+// resetTimer arms the timer for the next delayed or recurring task deadline
+func (d *Daemon) resetTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+
+	wait := d.pollInterval
+	if deadline, ok := d.queues.NextDeadline(); ok && time.Until(deadline) < wait {
+		wait = time.Until(deadline)
+	}
+	if deadline, ok := d.recurring.NextDeadline(); ok && time.Until(deadline) < wait {
+		wait = time.Until(deadline)
+	}
+	if wait < 0 {
+		wait = 0
+	}
+
+	timer.Reset(wait)
+}
+
+// dispatch spawns due recurring tasks, releases due delayed tasks and
+// starts tasks while the pool has room for them
+func (d *Daemon) dispatch() {
+	now := time.Now()
+
+	spawned, err := d.recurring.SpawnDue(now)
+	if err != nil {
+		log.Printf("failed to spawn recurring tasks: %v", err)
+	}
+	for _, t := range spawned {
+		log.Printf("recurring task %s spawned task %s", *t.RecurringID, t.ID)
+		d.queues.Add(t)
+	}
+
+	if released := d.queues.Promote(now); released > 0 {
+		log.Printf("%d delayed task(s) became due", released)
+	}
+
+	for {
+		// Paused queues and queues at their concurrency limit are passed over
+		next := d.queues.Next()
+		if next == nil {
+			return
+		}
+
+		// Next is the most urgent task that may start, so if it cannot get a
+		// slot no other task can either
+		if !d.pool.CanAccept(next.Priority) {
+			return
+		}
+		d.queues.Start(next)
+
+		// The task may have been cancelled since the queue was loaded
+		t, err := d.store.LoadTask(next.ID)
+		if err != nil || t.Status != task.StatusPending {
+			d.queues.Done(next)
+			continue
+		}
+
+		if !d.pool.Submit(t, d.finished) {
+			d.queues.Done(t)
+			return
+		}
+
+		log.Printf("started task %s in slot %d: %s", t.ID, *t.Slot, t.Command)
+		d.logger.LogInfo(t.ID, fmt.Sprintf("Task dispatched by daemon to slot %d", *t.Slot))
+	}
+}
+
+// finished is called by the pool when a task is done
+func (d *Daemon) finished(t *task.Task, err error) {
+	d.queues.Done(t)
+
+	if errors.Is(err, task.ErrClaimed) || errors.Is(err, task.ErrNotPending) {
+		// Started by another executor or cancelled before it could start
+		log.Printf("task %s not started: %v", t.ID, err)
+	} else if err != nil {
+		log.Printf("task %s failed to run: %v", t.ID, err)
+		d.logger.LogError(t.ID, err.Error())
+	} else if t.Status == task.StatusPending && t.ScheduledAt != nil {
+		// The attempt failed and the retry policy wants another one
+		log.Printf("task %s attempt %d failed, retrying at %s", t.ID, len(t.Attempts), t.ScheduledAt.Format(time.RFC3339))
+		d.logger.LogInfo(t.ID, fmt.Sprintf("Attempt %d failed, retrying at %s", len(t.Attempts), t.ScheduledAt.Format(time.RFC3339)))
+		d.queues.Add(t)
+	} else {
+		log.Printf("task %s finished with status %s", t.ID, t.Status)
+	}
+
+	// A slot is free again, try to start the next task right away
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start launches the daemon as a detached background process
+func Start(dataDir string, extraArgs ...string) (int, error) {
+	if pid, running := Status(dataDir); running {
+		return pid, fmt.Errorf("daemon is already running with PID %d", pid)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	// Daemon output goes to its own log file
+	logPath := filepath.Join(dataDir, "logs", "daemon.log")
+	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open daemon log file: %w", err)
+	}
+	defer logFile.Close()
+
+	args := append([]string{"daemon", "run"}, extraArgs...)
+	pid, err := proc.StartDetached(executable, args, logFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start daemon: %w", err)
+	}
+
+	// Give the daemon a moment to write its PID file
+	for i := 0; i < 20; i++ {
+		if _, running := Status(dataDir); running {
+			return pid, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return 0, fmt.Errorf("daemon did not start, see %s", logPath)
+}
+
+// Stop asks the running daemon to shut down
+func Stop(dataDir string) (int, error) {
+	pid, running := Status(dataDir)
+	if !running {
+		return 0, fmt.Errorf("daemon is not running")
+	}
+
+	if err := proc.Signal(pid, syscall.SIGTERM); err != nil {
+		return pid, fmt.Errorf("failed to signal daemon: %w", err)
+	}
+
+	return pid, nil
+}
+
+// Status returns the PID of the daemon and whether it is running
+func Status(dataDir string) (int, bool) {
+	data, err := os.ReadFile(pidFilePath(dataDir))
+	if err != nil {
+		return 0, false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+
+	return pid, proc.Alive(pid)
+}
+
+// pidFilePath returns the path of the daemon PID file
+func pidFilePath(dataDir string) string {
+	return filepath.Join(dataDir, "daemon.pid")
+}
+
+// writePIDFile records the daemon PID on disk
+func writePIDFile(dataDir string, pid int) error {
+	if err := fsutil.WriteFile(pidFilePath(dataDir), []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write PID file: %w", err)
+	}
+
+	return nil
+}
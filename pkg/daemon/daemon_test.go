@@ -0,0 +1,275 @@
+package daemon
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Can/sysrow/pkg/config"
+	"github.com/Can/sysrow/pkg/queue"
+	"github.com/Can/sysrow/pkg/storage"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// newDaemon returns a daemon on a fresh data directory with an in-memory store
+func newDaemon(t *testing.T, cfg *config.Config) (*Daemon, task.Store) {
+	t.Helper()
+	dataDir := t.TempDir()
+	store := storage.NewMemory()
+	task.DataDirectory = dataDir
+	task.SetStore(store)
+
+	d, err := NewDaemon(dataDir, store, cfg)
+	if err != nil {
+		t.Fatalf("NewDaemon() error = %v", err)
+	}
+	return d, store
+}
+
+// waitFor polls the store until the task has the status
+func waitFor(t *testing.T, store task.Store, id string, status task.TaskStatus) *task.Task {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		current, err := store.LoadTask(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Status == status && (status != task.StatusRunning || current.PID != nil) {
+			return current
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task %s is %s, want %s", id, current.Status, status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands are shell commands")
+	}
+
+	type queued struct {
+		queue    string
+		priority task.TaskPriority
+		// dependsOn are indexes of the tasks the task waits for
+		dependsOn []int
+	}
+
+	tests := []struct {
+		name     string
+		workers  int
+		reserved int
+		limits   map[string]int
+		paused   []string
+		tasks    []queued
+		// want are the indexes of the tasks started by one dispatch
+		want []int
+	}{
+		{
+			name:    "fills the pool",
+			workers: 2,
+			tasks:   []queued{{priority: task.PriorityNormal}, {priority: task.PriorityNormal}, {priority: task.PriorityNormal}},
+			want:    []int{0, 1},
+		},
+		{
+			name:    "most urgent first",
+			workers: 1,
+			tasks:   []queued{{priority: task.PriorityLow}, {priority: task.PriorityHigh}, {priority: task.PriorityNormal}},
+			want:    []int{1},
+		},
+		{
+			name:     "reserved slots wait for high priority tasks",
+			workers:  2,
+			reserved: 1,
+			tasks:    []queued{{priority: task.PriorityNormal}, {priority: task.PriorityNormal}},
+			want:     []int{0},
+		},
+		{
+			name:     "high priority tasks use the reserved slots",
+			workers:  2,
+			reserved: 1,
+			tasks:    []queued{{priority: task.PriorityNormal}, {priority: task.PriorityHigh}, {priority: task.PriorityHigh}},
+			want:     []int{1, 2},
+		},
+		{
+			name:    "queue limit",
+			workers: 3,
+			limits:  map[string]int{"a": 1},
+			tasks:   []queued{{queue: "a", priority: task.PriorityHigh}, {queue: "a", priority: task.PriorityHigh}, {queue: "b", priority: task.PriorityLow}},
+			want:    []int{0, 2},
+		},
+		{
+			name:    "paused queue",
+			workers: 2,
+			paused:  []string{"a"},
+			tasks:   []queued{{queue: "a", priority: task.PriorityHigh}, {priority: task.PriorityLow}},
+			want:    []int{1},
+		},
+		{
+			name:    "blocked tasks wait for their dependencies",
+			workers: 2,
+			tasks:   []queued{{priority: task.PriorityNormal}, {priority: task.PriorityHigh, dependsOn: []int{0}}},
+			want:    []int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Workers = tt.workers
+			cfg.ReservedHighSlots = tt.reserved
+			cfg.Queues = make(map[string]config.Queue)
+			for name, limit := range tt.limits {
+				cfg.Queues[name] = config.Queue{Concurrency: limit}
+			}
+			d, store := newDaemon(t, cfg)
+
+			ids := make([]string, len(tt.tasks))
+			created := time.Now().Add(-time.Hour)
+			for i, q := range tt.tasks {
+				tk := task.NewTask("sleep 0.2", q.priority)
+				tk.Queue = q.queue
+				tk.CreatedAt = created.Add(time.Duration(i) * time.Second)
+				for _, dep := range q.dependsOn {
+					tk.DependsOn = append(tk.DependsOn, ids[dep])
+					tk.Status = task.StatusBlocked
+				}
+				ids[i] = tk.ID
+				store.SaveTask(tk)
+			}
+			for _, name := range tt.paused {
+				if _, err := queue.Pause(d.dataDir, name); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			d.refresh()
+			d.dispatch()
+			started := d.pool.Tasks()
+			d.pool.Wait()
+
+			want := make([]string, 0, len(tt.want))
+			for _, i := range tt.want {
+				want = append(want, ids[i])
+			}
+			sort.Strings(started)
+			sort.Strings(want)
+			if len(started) != len(want) {
+				t.Fatalf("started %v, want %v", started, want)
+			}
+			for i := range want {
+				if started[i] != want[i] {
+					t.Fatalf("started %v, want %v", started, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatchSkipsCancelled(t *testing.T) {
+	d, store := newDaemon(t, config.Default())
+
+	tk := task.NewTask("true", task.PriorityNormal)
+	store.SaveTask(tk)
+	d.refresh()
+
+	// Cancelled by another process after the queues were loaded
+	tk.Status = task.StatusCancelled
+	store.SaveTask(tk)
+
+	d.dispatch()
+	if started := d.pool.Tasks(); len(started) != 0 {
+		t.Errorf("started %v, want nothing", started)
+	}
+	if next := d.queues.Next(); next != nil {
+		t.Errorf("Next() = %v, want the cancelled task gone from the queue", next.ID)
+	}
+}
+
+func TestStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands are shell commands")
+	}
+
+	tests := []struct {
+		name        string
+		command     string
+		stopTimeout string
+		want        task.TaskStatus
+	}{
+		{name: "task finishing in time", command: "sleep 0.2", stopTimeout: "10s", want: task.StatusCompleted},
+		{name: "task still running is cancelled", command: "sleep 30", stopTimeout: "1s", want: task.StatusCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.StopTimeout = tt.stopTimeout
+			cfg.KillGrace = "1s"
+			d, store := newDaemon(t, cfg)
+
+			tk := task.NewTask(tt.command, task.PriorityNormal)
+			store.SaveTask(tk)
+			d.refresh()
+			d.dispatch()
+			waitFor(t, store, tk.ID, task.StatusRunning)
+
+			start := time.Now()
+			d.stop(syscall.SIGTERM)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("stop() took %v", elapsed)
+			}
+
+			if running := d.pool.Running(); running != 0 {
+				t.Errorf("Running() = %d after stop, want 0", running)
+			}
+			if got, _ := store.LoadTask(tk.ID); got.Status != tt.want {
+				t.Errorf("status = %s, want %s", got.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	// A process that has exited leaves a PID that is not running
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pidFile string
+		wantPID int
+		running bool
+	}{
+		{name: "no PID file"},
+		{name: "this process", pidFile: strconv.Itoa(os.Getpid()) + "\n", wantPID: os.Getpid(), running: true},
+		{name: "exited process", pidFile: strconv.Itoa(exited.Process.Pid), wantPID: exited.Process.Pid},
+		{name: "garbage", pidFile: "daemon\n"},
+		{name: "negative", pidFile: "-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			if tt.pidFile != "" {
+				if err := os.WriteFile(pidFilePath(dataDir), []byte(tt.pidFile), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			pid, running := Status(dataDir)
+			if pid != tt.wantPID || running != tt.running {
+				t.Errorf("Status() = %d, %v, want %d, %v", pid, running, tt.wantPID, tt.running)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// Resolution describes a blocked task whose dependencies were resolved
+type Resolution struct {
+	Task   *task.Task
+	Reason string
+}
+
+// index maps task IDs to tasks
+func index(tasks []*task.Task) map[string]*task.Task {
+	byID := make(map[string]*task.Task, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+	}
+
+	return byID
+}
+
+// Validate checks that every task t depends on exists and that adding t
+// to the existing tasks does not create a dependency cycle
+func Validate(t *task.Task, tasks []*task.Task) error {
+	byID := index(tasks)
+	byID[t.ID] = t
+
+	for _, parentID := range t.DependsOn {
+		if parentID == t.ID {
+			return fmt.Errorf("task cannot depend on itself")
+		}
+		if _, ok := byID[parentID]; !ok {
+			return fmt.Errorf("dependency %s not found", parentID)
+		}
+	}
+
+	if cycle := findCycle(t.ID, byID); cycle != nil {
+		return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	return nil
+}
+
+// FindCycle returns the path of a dependency cycle among the tasks, or nil
+func FindCycle(tasks []*task.Task) []string {
+	byID := index(tasks)
+	for _, t := range tasks {
+		if cycle := findCycle(t.ID, byID); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+// findCycle returns the path of a cycle that goes through start, or nil
+func findCycle(start string, byID map[string]*task.Task) []string {
+	visited := make(map[string]bool)
+
+	var walk func(id string, path []string) []string
+	walk = func(id string, path []string) []string {
+		t, ok := byID[id]
+		if !ok {
+			return nil
+		}
+
+		for _, parentID := range t.DependsOn {
+			if parentID == start {
+				return append(path, parentID)
+			}
+			if visited[parentID] {
+				continue
+			}
+			visited[parentID] = true
+
+			if cycle := walk(parentID, append(path, parentID)); cycle != nil {
+				return cycle
+			}
+		}
+
+		return nil
+	}
+
+	return walk(start, []string{start})
+}
+
+// Resolve looks at every blocked task and moves it to pending once its
+// dependencies satisfy its condition, or to skipped once they no longer can.
+// Skipping cascades, so tasks depending on a skipped task are resolved in
+// the same call. The returned tasks have been changed but not saved.
+func Resolve(tasks []*task.Task) []Resolution {
+	byID := index(tasks)
+	resolved := make([]Resolution, 0)
+
+	for changed := true; changed; {
+		changed = false
+		for _, t := range tasks {
+			if t.Status != task.StatusBlocked {
+				continue
+			}
+
+			ready, reason := Check(t, lookup(byID))
+			if !ready && reason == "" {
+				continue
+			}
+
+			if ready {
+				t.Status = task.StatusPending
+			} else {
+				now := time.Now()
+				t.Status = task.StatusSkipped
+				t.FinishedAt = &now
+			}
+			resolved = append(resolved, Resolution{Task: t, Reason: reason})
+			changed = true
+		}
+	}
+
+	return resolved
+}
+
+// StatusFunc returns the status of a task and whether the task exists
+type StatusFunc func(id string) (task.TaskStatus, bool)
+
+// lookup returns a StatusFunc backed by a map of tasks
+func lookup(byID map[string]*task.Task) StatusFunc {
+	return func(id string) (task.TaskStatus, bool) {
+		t, ok := byID[id]
+		if !ok {
+			return "", false
+		}
+		return t.Status, true
+	}
+}
+
+// Check reports whether the task can run. If it cannot run yet and never
+// will, the reason is returned; an empty reason means it has to keep waiting.
+func Check(t *task.Task, statusOf StatusFunc) (bool, string) {
+	for _, parentID := range t.DependsOn {
+		status, ok := statusOf(parentID)
+		if !ok {
+			return false, fmt.Sprintf("dependency %s no longer exists", parentID)
+		}
+
+		if !status.IsTerminal() {
+			return false, ""
+		}
+
+		if !Satisfies(status, t.Condition) {
+			return false, fmt.Sprintf("dependency %s ended with status %s, condition is %s", parentID, status, conditionOf(t))
+		}
+	}
+
+	return true, ""
+}
+
+// Satisfies reports whether a dependency that ended with the given status
+// meets the condition
+func Satisfies(status task.TaskStatus, condition string) bool {
+	switch condition {
+	case task.ConditionFailure:
+		return status == task.StatusFailed || status == task.StatusTimedOut || status == task.StatusLost
+	case task.ConditionCompletion:
+		return status.IsTerminal()
+	default:
+		return status == task.StatusCompleted
+	}
+}
+
+// Tree renders the dependencies of a task as an ASCII tree. With dependents
+// set the tree shows the tasks that depend on it instead.
+func Tree(rootID string, tasks []*task.Task, dependents bool) (string, error) {
+	byID := index(tasks)
+	root, ok := byID[rootID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", task.ErrNotFound, rootID)
+	}
+
+	// children returns the next level of the tree for a task
+	children := func(t *task.Task) []string {
+		return t.DependsOn
+	}
+	if dependents {
+		reverse := make(map[string][]string)
+		for _, t := range tasks {
+			for _, parentID := range t.DependsOn {
+				reverse[parentID] = append(reverse[parentID], t.ID)
+			}
+		}
+		for _, ids := range reverse {
+			sort.Slice(ids, func(i, j int) bool {
+				return byID[ids[i]].CreatedAt.Before(byID[ids[j]].CreatedAt)
+			})
+		}
+		children = func(t *task.Task) []string {
+			return reverse[t.ID]
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(label(root) + "\n")
+
+	var walk func(t *task.Task, prefix string, path map[string]bool)
+	walk = func(t *task.Task, prefix string, path map[string]bool) {
+		ids := children(t)
+		for i, id := range ids {
+			branch, indent := "|-- ", "|   "
+			if i == len(ids)-1 {
+				branch, indent = "`-- ", "    "
+			}
+
+			child, ok := byID[id]
+			switch {
+			case !ok:
+				b.WriteString(prefix + branch + id + " (missing)\n")
+			case path[id]:
+				b.WriteString(prefix + branch + label(child) + " (cycle)\n")
+			default:
+				b.WriteString(prefix + branch + label(child) + "\n")
+				path[id] = true
+				walk(child, prefix+indent, path)
+				delete(path, id)
+			}
+		}
+	}
+	walk(root, "", map[string]bool{root.ID: true})
+
+	return b.String(), nil
+}
+
+// label describes a task on one line of the tree
+func label(t *task.Task) string {
+	s := fmt.Sprintf("%s [%s] %s", t.ID, t.Status, t.Command)
+	if len(t.DependsOn) > 0 {
+		s += fmt.Sprintf(" (on %s)", conditionOf(t))
+	}
+
+	return s
+}
+
+// conditionOf returns the condition of a task, success when none is set
+func conditionOf(t *task.Task) string {
+	if t.Condition == "" {
+		return task.ConditionSuccess
+	}
+
+	return t.Condition
+}
@@ -0,0 +1,222 @@
+package dag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// newTask returns a task with the given ID and status depending on parents
+func newTask(id string, status task.TaskStatus, parents ...string) *task.Task {
+	return &task.Task{ID: id, Status: status, Command: "echo " + id, DependsOn: parents}
+}
+
+func TestValidate(t *testing.T) {
+	existing := []*task.Task{
+		newTask("a", task.StatusCompleted),
+		newTask("b", task.StatusBlocked, "a"),
+	}
+
+	tests := []struct {
+		name    string
+		task    *task.Task
+		wantErr string
+	}{
+		{"no dependencies", newTask("c", task.StatusPending), ""},
+		{"existing dependencies", newTask("c", task.StatusBlocked, "a", "b"), ""},
+		{"itself", newTask("c", task.StatusBlocked, "c"), "itself"},
+		{"missing dependency", newTask("c", task.StatusBlocked, "x"), "dependency x not found"},
+		// a is changed to depend on b, which depends on a
+		{"cycle", newTask("a", task.StatusBlocked, "b"), "dependency cycle: a -> b -> a"},
+	}
+
+	for _, tt := range tests {
+		err := Validate(tt.task, existing)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: Validate() failed: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: Validate() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks []*task.Task
+		want  []string
+	}{
+		{
+			"chain",
+			[]*task.Task{newTask("a", task.StatusBlocked, "b"), newTask("b", task.StatusBlocked, "c"), newTask("c", task.StatusPending)},
+			nil,
+		},
+		{
+			"diamond",
+			[]*task.Task{newTask("a", task.StatusBlocked, "b", "c"), newTask("b", task.StatusBlocked, "d"), newTask("c", task.StatusBlocked, "d"), newTask("d", task.StatusPending)},
+			nil,
+		},
+		{
+			"missing dependency",
+			[]*task.Task{newTask("a", task.StatusBlocked, "x")},
+			nil,
+		},
+		{
+			"two tasks",
+			[]*task.Task{newTask("a", task.StatusBlocked, "b"), newTask("b", task.StatusBlocked, "a")},
+			[]string{"a", "b", "a"},
+		},
+		{
+			"cycle behind a task outside it",
+			[]*task.Task{newTask("a", task.StatusBlocked, "b"), newTask("b", task.StatusBlocked, "c"), newTask("c", task.StatusBlocked, "d"), newTask("d", task.StatusBlocked, "b")},
+			[]string{"b", "c", "d", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := FindCycle(tt.tasks); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: FindCycle() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		status    task.TaskStatus
+		condition string
+		want      bool
+	}{
+		{task.StatusCompleted, "", true},
+		{task.StatusCompleted, task.ConditionSuccess, true},
+		{task.StatusFailed, task.ConditionSuccess, false},
+		{task.StatusCancelled, task.ConditionSuccess, false},
+		{task.StatusFailed, task.ConditionFailure, true},
+		{task.StatusTimedOut, task.ConditionFailure, true},
+		{task.StatusLost, task.ConditionFailure, true},
+		{task.StatusCompleted, task.ConditionFailure, false},
+		{task.StatusCancelled, task.ConditionFailure, false},
+		{task.StatusSkipped, task.ConditionFailure, false},
+		{task.StatusCompleted, task.ConditionCompletion, true},
+		{task.StatusCancelled, task.ConditionCompletion, true},
+		{task.StatusSkipped, task.ConditionCompletion, true},
+		{task.StatusRunning, task.ConditionCompletion, false},
+	}
+
+	for _, tt := range tests {
+		if got := Satisfies(tt.status, tt.condition); got != tt.want {
+			t.Errorf("Satisfies(%s, %q) = %t, want %t", tt.status, tt.condition, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		parent    task.TaskStatus
+		condition string
+		want      task.TaskStatus
+	}{
+		{"parent running", task.StatusRunning, "", task.StatusBlocked},
+		{"parent pending", task.StatusPending, task.ConditionCompletion, task.StatusBlocked},
+		{"parent completed", task.StatusCompleted, "", task.StatusPending},
+		{"parent failed", task.StatusFailed, "", task.StatusSkipped},
+		{"parent failed on failure", task.StatusFailed, task.ConditionFailure, task.StatusPending},
+		{"parent completed on failure", task.StatusCompleted, task.ConditionFailure, task.StatusSkipped},
+		{"parent cancelled on completion", task.StatusCancelled, task.ConditionCompletion, task.StatusPending},
+	}
+
+	for _, tt := range tests {
+		child := newTask("child", task.StatusBlocked, "parent")
+		child.Condition = tt.condition
+
+		resolved := Resolve([]*task.Task{newTask("parent", tt.parent), child})
+		if child.Status != tt.want {
+			t.Errorf("%s: child status = %s, want %s", tt.name, child.Status, tt.want)
+		}
+		if wantResolved := tt.want != task.StatusBlocked; (len(resolved) == 1) != wantResolved {
+			t.Errorf("%s: Resolve() returned %d resolutions", tt.name, len(resolved))
+		}
+		if (child.FinishedAt != nil) != (tt.want == task.StatusSkipped) {
+			t.Errorf("%s: FinishedAt = %v, want it set only for skipped tasks", tt.name, child.FinishedAt)
+		}
+	}
+}
+
+func TestResolveCascades(t *testing.T) {
+	// d runs whatever c does, c needs b, which needs the failed a
+	a := newTask("a", task.StatusFailed)
+	b := newTask("b", task.StatusBlocked, "a")
+	c := newTask("c", task.StatusBlocked, "b")
+	d := newTask("d", task.StatusBlocked, "c")
+	d.Condition = task.ConditionCompletion
+	missing := newTask("e", task.StatusBlocked, "gone")
+
+	resolved := Resolve([]*task.Task{d, c, b, a, missing})
+
+	for _, tt := range []struct {
+		task *task.Task
+		want task.TaskStatus
+	}{
+		{b, task.StatusSkipped},
+		{c, task.StatusSkipped},
+		{d, task.StatusPending},
+		{missing, task.StatusSkipped},
+	} {
+		if tt.task.Status != tt.want {
+			t.Errorf("task %s status = %s, want %s", tt.task.ID, tt.task.Status, tt.want)
+		}
+	}
+
+	if len(resolved) != 4 {
+		t.Errorf("Resolve() returned %d resolutions, want 4", len(resolved))
+	}
+	for _, res := range resolved {
+		if res.Task == missing && !strings.Contains(res.Reason, "gone no longer exists") {
+			t.Errorf("reason for missing dependency = %q", res.Reason)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	now := time.Now()
+	tasks := []*task.Task{
+		newTask("a", task.StatusBlocked, "b", "x"),
+		newTask("b", task.StatusCompleted, "a"),
+		newTask("c", task.StatusBlocked, "b"),
+	}
+	for i, tk := range tasks {
+		tk.CreatedAt = now.Add(time.Duration(i) * time.Second)
+	}
+
+	got, err := Tree("a", tasks, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a [blocked] echo a (on success)\n" +
+		"|-- b [completed] echo b (on success)\n" +
+		"|   `-- a [blocked] echo a (on success) (cycle)\n" +
+		"`-- x (missing)\n"
+	if got != want {
+		t.Errorf("Tree() =\n%s\nwant\n%s", got, want)
+	}
+
+	got, err = Tree("b", tasks, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "b [completed] echo b (on success)\n" +
+		"|-- a [blocked] echo a (on success)\n" +
+		"|   `-- b [completed] echo b (on success) (cycle)\n" +
+		"`-- c [blocked] echo c (on success)\n"
+	if got != want {
+		t.Errorf("Tree(dependents) =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := Tree("nope", tasks, false); err == nil {
+		t.Error("Tree() of a missing task succeeded")
+	}
+}
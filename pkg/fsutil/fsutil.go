@@ -0,0 +1,96 @@
+// Package fsutil writes metadata files so that a crash or a full disk never
+// leaves a truncated file behind, and moves files that are corrupt anyway
+// out of the way.
+package fsutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tempMarker is part of the name of every temporary file WriteFile creates
+const tempMarker = ".tmp-"
+
+// WriteFile writes data to a temporary file next to path, syncs it and
+// renames it over path. Readers see either the old or the new contents,
+// never a partial write.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	return WriteFileFrom(path, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// WriteFileFrom writes a file like WriteFile, with the contents written by
+// write, for files too large to hold in memory. If write fails, path is
+// left untouched.
+func WriteFileFrom(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	dir := filepath.Dir(path)
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+tempMarker+"*")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	// Remove the temporary file unless it was renamed
+	defer func() {
+		if tmpPath != "" {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	tmpPath = ""
+
+	// Make the rename itself durable
+	return syncDir(dir)
+}
+
+// IsTemp reports whether a file name belongs to a temporary file of WriteFile
+func IsTemp(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, tempMarker)
+}
+
+// Quarantine moves a corrupt file into the corrupt directory of the data
+// directory, keeping the name of the directory it came from, e.g.
+// tasks/<id>.json becomes corrupt/tasks/<id>.json.<timestamp>. It returns
+// the new path.
+func Quarantine(dataDir, path string) (string, error) {
+	dir := filepath.Join(dataDir, "corrupt", filepath.Base(filepath.Dir(path)))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create corrupt directory: %w", err)
+	}
+
+	target := filepath.Join(dir, filepath.Base(path)+"."+time.Now().Format("20060102-150405"))
+	if err := os.Rename(path, target); err != nil {
+		return "", fmt.Errorf("failed to move %s: %w", path, err)
+	}
+
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		return target, err
+	}
+
+	return target, syncDir(dir)
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package fsutil
+
+// syncDir is a no-op on Windows, where directories cannot be synced
+func syncDir(dir string) error {
+	return nil
+}
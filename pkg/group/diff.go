@@ -0,0 +1,231 @@
+package group
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Can/sysrow/pkg/lock"
+	"github.com/google/uuid"
+)
+
+// Diff describes the changes that turn the stored group into the desired
+// one, one line per change. current is nil for a group that does not exist
+// yet. An empty result means the groups are equal.
+func Diff(current, desired *Group) []string {
+	if current == nil {
+		changes := []string{fmt.Sprintf("+ group %s", desired.Name)}
+		changes = append(changes, diffEnv("  ", nil, desired.Env)...)
+		for i := range desired.Steps {
+			changes = append(changes, fmt.Sprintf("  + step %s: %s", stepLabel(&desired.Steps[i], i), desired.Steps[i].Command))
+		}
+		return changes
+	}
+
+	changes := make([]string, 0)
+	changes = append(changes, diffEnv("  ", current.Env, desired.Env)...)
+
+	currentSteps := make(map[string]*Step, len(current.Steps))
+	for i := range current.Steps {
+		currentSteps[stepLabel(&current.Steps[i], i)] = &current.Steps[i]
+	}
+	desiredSteps := make(map[string]*Step, len(desired.Steps))
+	for i := range desired.Steps {
+		desiredSteps[stepLabel(&desired.Steps[i], i)] = &desired.Steps[i]
+	}
+
+	for i := range current.Steps {
+		label := stepLabel(&current.Steps[i], i)
+		if _, ok := desiredSteps[label]; !ok {
+			changes = append(changes, fmt.Sprintf("  - step %s: %s", label, current.Steps[i].Command))
+		}
+	}
+
+	for i := range desired.Steps {
+		step := &desired.Steps[i]
+		label := stepLabel(step, i)
+
+		old, ok := currentSteps[label]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("  + step %s: %s", label, step.Command))
+			continue
+		}
+
+		if fields := diffStep(old, step); len(fields) > 0 {
+			changes = append(changes, fmt.Sprintf("  ~ step %s", label))
+			for _, field := range fields {
+				changes = append(changes, "      "+field)
+			}
+		}
+	}
+
+	// Steps start in order, so a different order is a change as well
+	if order, newOrder := stepOrder(current.Steps), stepOrder(desired.Steps); len(changes) == 0 && order != newOrder {
+		changes = append(changes, fmt.Sprintf("  ~ step order: %s -> %s", order, newOrder))
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return append([]string{fmt.Sprintf("~ group %s", desired.Name)}, changes...)
+}
+
+// diffStep lists the fields that differ between two versions of a step
+func diffStep(old, step *Step) []string {
+	fields := make([]string, 0)
+	if old.Command != step.Command {
+		fields = append(fields, fmt.Sprintf("command: %q -> %q", old.Command, step.Command))
+	}
+	if old.Priority != step.Priority {
+		fields = append(fields, fmt.Sprintf("priority: %s -> %s", old.Priority, step.Priority))
+	}
+	if old.Timeout != step.Timeout {
+		fields = append(fields, fmt.Sprintf("timeout: %s -> %s", old.Timeout, step.Timeout))
+	}
+	if !reflect.DeepEqual(old.Retry, step.Retry) {
+		fields = append(fields, fmt.Sprintf("retry: %s -> %s", describeRetry(old), describeRetry(step)))
+	}
+	if strings.Join(old.DependsOn, ",") != strings.Join(step.DependsOn, ",") || old.Condition != step.Condition {
+		fields = append(fields, fmt.Sprintf("depends on: %s -> %s", describeDeps(old), describeDeps(step)))
+	}
+	fields = append(fields, diffEnv("", old.Env, step.Env)...)
+
+	return fields
+}
+
+// diffEnv lists added, removed and changed environment variables
+func diffEnv(indent string, old, env map[string]string) []string {
+	changes := make([]string, 0)
+	for _, key := range sortedKeys(old) {
+		if _, ok := env[key]; !ok {
+			changes = append(changes, fmt.Sprintf("%s- env %s=%s", indent, key, old[key]))
+		}
+	}
+	for _, key := range sortedKeys(env) {
+		oldValue, ok := old[key]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s+ env %s=%s", indent, key, env[key]))
+		case oldValue != env[key]:
+			changes = append(changes, fmt.Sprintf("%s~ env %s: %s -> %s", indent, key, oldValue, env[key]))
+		}
+	}
+
+	return changes
+}
+
+// sortedKeys returns the keys of an environment in order
+func sortedKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// stepLabel identifies a step by name, or by position for unnamed steps
+func stepLabel(step *Step, index int) string {
+	if step.Name != "" {
+		return step.Name
+	}
+
+	return fmt.Sprintf("#%d", index+1)
+}
+
+// stepOrder lists the step labels in order
+func stepOrder(steps []Step) string {
+	labels := make([]string, len(steps))
+	for i := range steps {
+		labels[i] = stepLabel(&steps[i], i)
+	}
+
+	return strings.Join(labels, ", ")
+}
+
+// describeRetry summarizes the retry policy of a step
+func describeRetry(step *Step) string {
+	if step.Retry == nil {
+		return "none"
+	}
+
+	desc := fmt.Sprintf("%d x %s %s", step.Retry.MaxRetries, step.Retry.Backoff, step.Retry.Delay)
+	if len(step.Retry.OnExitCodes) > 0 {
+		desc += fmt.Sprintf(" on %v", step.Retry.OnExitCodes)
+	}
+
+	return desc
+}
+
+// describeDeps summarizes the dependencies of a step
+func describeDeps(step *Step) string {
+	if len(step.DependsOn) == 0 {
+		return "none"
+	}
+
+	return fmt.Sprintf("%s (%s)", strings.Join(step.DependsOn, ", "), step.Condition)
+}
+
+// Apply creates the desired group or updates the stored group with the same
+// name to match it. Run history and the group ID are kept. It returns the
+// changes that were made, which are empty if the group was already up to date.
+func (gm *GroupManager) Apply(desired *Group) ([]string, error) {
+	if err := ValidateSteps(desired.Steps); err != nil {
+		return nil, fmt.Errorf("group %s: %w", desired.Name, err)
+	}
+
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	l, err := lock.DataDir(gm.dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	current, err := gm.Lookup(desired.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := Diff(current, desired)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	updated := &Group{
+		ID:    uuid.New().String(),
+		Name:  desired.Name,
+		Steps: desired.Steps,
+		Env:   desired.Env,
+	}
+	if current != nil {
+		updated.ID = current.ID
+		updated.RunCount = current.RunCount
+	}
+
+	if err := gm.saveGroup(updated); err != nil {
+		return nil, fmt.Errorf("failed to save group: %w", err)
+	}
+
+	return changes, nil
+}
+
+// Lookup returns the group with the given name, or nil if there is none
+func (gm *GroupManager) Lookup(name string) (*Group, error) {
+	groups, err := gm.ListGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+
+	for _, g := range groups {
+		if g.Name == name {
+			return g, nil
+		}
+	}
+
+	return nil, nil
+}
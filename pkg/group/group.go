@@ -2,36 +2,143 @@ package group
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
-	"github.com/Can/sysrow/pkg/runner"
+	"github.com/Can/sysrow/pkg/dag"
+	"github.com/Can/sysrow/pkg/fsutil"
+	"github.com/Can/sysrow/pkg/lock"
+	"github.com/Can/sysrow/pkg/schema"
 	"github.com/Can/sysrow/pkg/task"
 	"github.com/google/uuid"
 )
 
-// Group represents a collection of related tasks
+// ErrNotFound is returned for a group or group run that does not exist
+var ErrNotFound = errors.New("not found")
+
+// Group is a template of related tasks. Every run of the group creates
+// fresh tasks from its steps, see GroupRun.
 type Group struct {
-	ID      string   `json:"id"`
-	Name    string   `json:"name"`
-	TaskIDs []string `json:"task_ids"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Steps []Step `json:"steps"`
+	// Env is added to the environment of every step
+	Env      map[string]string `json:"env,omitempty"`
+	RunCount int               `json:"run_count"`
+	// SchemaVersion is the schema version the record was written with
+	SchemaVersion int `json:"schema_version"`
+}
+
+// Step is the template of a task that is created on every run of a group.
+// Steps may depend on other steps of the same group by name.
+type Step struct {
+	Name      string            `json:"name,omitempty"`
+	Command   string            `json:"command"`
+	Priority  task.TaskPriority `json:"priority"`
+	Timeout   time.Duration     `json:"timeout,omitempty"`
+	Retry     *task.RetryPolicy `json:"retry,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	DependsOn []string          `json:"depends_on,omitempty"`
+	Condition string            `json:"condition,omitempty"`
 }
 
 // GroupManager manages task groups
 type GroupManager struct {
 	mutex     sync.Mutex
 	dataDir   string
+	store     task.Store
 	groupsDir string
+	runsDir   string
 }
 
-// NewGroupManager creates a new group manager
-func NewGroupManager(dataDir string) *GroupManager {
+// NewGroupManager creates a new group manager. The tasks of group runs are
+// kept in the given store.
+func NewGroupManager(dataDir string, store task.Store) *GroupManager {
 	return &GroupManager{
 		dataDir:   dataDir,
+		store:     store,
 		groupsDir: filepath.Join(dataDir, "groups"),
+		runsDir:   filepath.Join(dataDir, "runs"),
+	}
+}
+
+// StepFromTask creates a step with the settings of a task, named after the task
+func StepFromTask(t *task.Task) Step {
+	return Step{
+		Name:      t.Name,
+		Command:   t.Command,
+		Priority:  t.Priority,
+		Timeout:   t.Timeout,
+		Retry:     t.Retry,
+		Env:       t.Env,
+		Condition: t.Condition,
+	}
+}
+
+// newTask creates a fresh pending task from the step. The step environment
+// is added on top of the group environment.
+func (s *Step) newTask(groupEnv map[string]string) *task.Task {
+	t := task.NewTask(s.Command, s.Priority)
+	t.Name = s.Name
+	t.Timeout = s.Timeout
+	t.Retry = s.Retry
+	t.Condition = s.Condition
+
+	if len(groupEnv) > 0 || len(s.Env) > 0 {
+		t.Env = make(map[string]string, len(groupEnv)+len(s.Env))
+		for key, value := range groupEnv {
+			t.Env[key] = value
+		}
+		for key, value := range s.Env {
+			t.Env[key] = value
+		}
 	}
+
+	return t
+}
+
+// ValidateSteps checks that step names are valid task names and unique, and
+// that dependencies refer to existing steps without forming a cycle
+func ValidateSteps(steps []Step) error {
+	names := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		if step.Name == "" {
+			continue
+		}
+		// Steps name the tasks they start
+		if err := task.ValidateName(step.Name); err != nil {
+			return fmt.Errorf("step %q: %w", step.Name, err)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("duplicate step %q", step.Name)
+		}
+		names[step.Name] = true
+	}
+
+	// Reuse the task dependency checks, with step names standing in for task IDs
+	nodes := make([]*task.Task, 0, len(steps))
+	for _, step := range steps {
+		for _, dep := range step.DependsOn {
+			if !names[dep] {
+				return fmt.Errorf("step %q depends on unknown step %q", step.Name, dep)
+			}
+			if dep == step.Name {
+				return fmt.Errorf("step %q depends on itself", step.Name)
+			}
+		}
+		nodes = append(nodes, &task.Task{ID: step.Name, DependsOn: step.DependsOn})
+	}
+
+	if cycle := dag.FindCycle(nodes); cycle != nil {
+		return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	return nil
 }
 
 // CreateGroup creates a new task group
@@ -39,6 +146,12 @@ func (gm *GroupManager) CreateGroup(name string) (*Group, error) {
 	gm.mutex.Lock()
 	defer gm.mutex.Unlock()
 
+	l, err := lock.DataDir(gm.dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
 	// Check if a group with the same name already exists
 	groups, err := gm.ListGroups()
 	if err != nil {
@@ -53,9 +166,9 @@ func (gm *GroupManager) CreateGroup(name string) (*Group, error) {
 
 	// Create a new group
 	group := &Group{
-		ID:      uuid.New().String(),
-		Name:    name,
-		TaskIDs: make([]string, 0),
+		ID:    uuid.New().String(),
+		Name:  name,
+		Steps: make([]Step, 0),
 	}
 
 	// Save the group
@@ -66,62 +179,32 @@ func (gm *GroupManager) CreateGroup(name string) (*Group, error) {
 	return group, nil
 }
 
-// AddTask adds a task to a group
-func (gm *GroupManager) AddTask(groupName string, command string) (*task.Task, error) {
+// AddStep appends a step to a group
+func (gm *GroupManager) AddStep(groupName string, step Step) error {
 	gm.mutex.Lock()
 	defer gm.mutex.Unlock()
 
-	// Find the group
-	group, err := gm.GetGroupByName(groupName)
+	l, err := lock.DataDir(gm.dataDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get group: %w", err)
-	}
-
-	// Create a new task
-	t := task.NewTask(command, task.PriorityNormal)
-	t.GroupID = &group.ID
-
-	// Save the task
-	if err := t.Save(); err != nil {
-		return nil, fmt.Errorf("failed to save task: %w", err)
+		return err
 	}
+	defer l.Release()
 
-	// Add the task ID to the group
-	group.TaskIDs = append(group.TaskIDs, t.ID)
-
-	// Save the updated group
-	if err := gm.saveGroup(group); err != nil {
-		return nil, fmt.Errorf("failed to save group: %w", err)
-	}
-
-	return t, nil
-}
-
-// RunGroup runs all tasks in a group
-func (gm *GroupManager) RunGroup(groupName string, r *runner.Runner, background bool) error {
 	// Find the group
 	group, err := gm.GetGroupByName(groupName)
 	if err != nil {
 		return fmt.Errorf("failed to get group: %w", err)
 	}
 
-	// Run each task in the group
-	for _, taskID := range group.TaskIDs {
-		// Load the task
-		t, err := task.LoadTask(taskID)
-		if err != nil {
-			return fmt.Errorf("failed to load task %s: %w", taskID, err)
-		}
-
-		// Run the task
-		if err := r.RunTask(t, background); err != nil {
-			return fmt.Errorf("failed to run task %s: %w", taskID, err)
-		}
+	steps := append(append([]Step{}, group.Steps...), step)
+	if err := ValidateSteps(steps); err != nil {
+		return err
+	}
+	group.Steps = steps
 
-		// If not running in background, wait for the task to complete
-		if !background {
-			fmt.Printf("Task %s completed with status: %s\n", t.ID, t.Status)
-		}
+	// Save the updated group
+	if err := gm.saveGroup(group); err != nil {
+		return fmt.Errorf("failed to save group: %w", err)
 	}
 
 	return nil
@@ -132,6 +215,12 @@ func (gm *GroupManager) DeleteGroup(groupName string) error {
 	gm.mutex.Lock()
 	defer gm.mutex.Unlock()
 
+	l, err := lock.DataDir(gm.dataDir)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
 	// Find the group
 	group, err := gm.GetGroupByName(groupName)
 	if err != nil {
@@ -162,7 +251,7 @@ func (gm *GroupManager) GetGroupByName(name string) (*Group, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("group with name %s not found", name)
+	return nil, fmt.Errorf("group with name %s %w", name, ErrNotFound)
 }
 
 // ListGroups returns all groups
@@ -210,6 +299,7 @@ func (gm *GroupManager) saveGroup(g *Group) error {
 	}
 
 	// Marshal the group to JSON
+	g.SchemaVersion = schema.Version
 	groupData, err := json.MarshalIndent(g, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal group: %w", err)
@@ -217,7 +307,7 @@ func (gm *GroupManager) saveGroup(g *Group) error {
 
 	// Write the group data to file
 	groupPath := filepath.Join(gm.groupsDir, g.ID+".json")
-	if err := os.WriteFile(groupPath, groupData, 0644); err != nil {
+	if err := fsutil.WriteFile(groupPath, groupData, 0644); err != nil {
 		return fmt.Errorf("failed to write group file: %w", err)
 	}
 
@@ -239,6 +329,9 @@ func (gm *GroupManager) loadGroup(id string) (*Group, error) {
 	if err := json.Unmarshal(groupData, &group); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal group: %w", err)
 	}
+	if err := schema.CheckRecord("group", id, group.SchemaVersion); err != nil {
+		return nil, err
+	}
 
 	return &group, nil
 }
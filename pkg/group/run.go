@@ -0,0 +1,396 @@
+package group
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Can/sysrow/pkg/dag"
+	"github.com/Can/sysrow/pkg/fsutil"
+	"github.com/Can/sysrow/pkg/lock"
+	"github.com/Can/sysrow/pkg/runner"
+	"github.com/Can/sysrow/pkg/schema"
+	"github.com/Can/sysrow/pkg/task"
+	"github.com/google/uuid"
+)
+
+// Run statuses
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// RunOptions controls how RunGroup executes the tasks of a run
+type RunOptions struct {
+	// Parallel is the number of tasks that may run at the same time
+	Parallel int
+	// ContinueOnError keeps starting tasks after one failed, otherwise the
+	// remaining tasks are skipped
+	ContinueOnError bool
+	// OnFinish is called after each task has finished, it may be nil
+	OnFinish func(t *task.Task, err error)
+}
+
+// RunSummary is the outcome of a single run of a group
+type RunSummary struct {
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	Succeeded  int           `json:"succeeded"`
+	Failed     int           `json:"failed"`
+	Skipped    int           `json:"skipped"`
+}
+
+// GroupRun is one execution of a group. It owns the tasks that were created
+// from the group's steps for this run.
+type GroupRun struct {
+	ID        string   `json:"id"`
+	GroupID   string   `json:"group_id"`
+	GroupName string   `json:"group_name"`
+	Number    int      `json:"number"`
+	Status    string   `json:"status"`
+	TaskIDs   []string `json:"task_ids"`
+	RunSummary
+	// SchemaVersion is the schema version the record was written with
+	SchemaVersion int `json:"schema_version"`
+}
+
+// NewRun creates run number N+1 of a group together with a fresh pending
+// task for every step
+func (gm *GroupManager) NewRun(groupName string) (*GroupRun, error) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	l, err := lock.DataDir(gm.dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	group, err := gm.GetGroupByName(groupName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+
+	if len(group.Steps) == 0 {
+		return nil, fmt.Errorf("group %s has no steps", groupName)
+	}
+
+	group.RunCount++
+	run := &GroupRun{
+		ID:        uuid.New().String(),
+		GroupID:   group.ID,
+		GroupName: group.Name,
+		Number:    group.RunCount,
+		Status:    RunRunning,
+		TaskIDs:   make([]string, 0, len(group.Steps)),
+	}
+
+	// Create the tasks first so step dependencies can be mapped to task IDs
+	tasks := make([]*task.Task, len(group.Steps))
+	byName := make(map[string]string, len(group.Steps))
+	for i := range group.Steps {
+		tasks[i] = group.Steps[i].newTask(group.Env)
+		if name := group.Steps[i].Name; name != "" {
+			byName[name] = tasks[i].ID
+		}
+	}
+
+	for i, t := range tasks {
+		for _, dep := range group.Steps[i].DependsOn {
+			t.DependsOn = append(t.DependsOn, byName[dep])
+		}
+
+		t.GroupID = &group.ID
+		t.RunID = &run.ID
+		if err := gm.store.SaveTask(t); err != nil {
+			return nil, fmt.Errorf("failed to save task: %w", err)
+		}
+		run.TaskIDs = append(run.TaskIDs, t.ID)
+	}
+
+	if err := gm.saveGroup(group); err != nil {
+		return nil, fmt.Errorf("failed to save group: %w", err)
+	}
+
+	if err := gm.saveRun(run); err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// RunGroup executes the tasks of a run in order, up to opts.Parallel at a
+// time, and records the summary on the run. A task whose dependencies have
+// not finished yet waits for them and the tasks behind it may start first.
+func (gm *GroupManager) RunGroup(run *GroupRun, r *runner.Runner, opts RunOptions) error {
+	if opts.Parallel < 1 {
+		opts.Parallel = 1
+	}
+
+	run.StartedAt = time.Now()
+	if err := gm.saveRun(run); err != nil {
+		return err
+	}
+
+	// Statuses of the run's tasks as seen by this loop. Running tasks are
+	// updated by their goroutine, so they are only read through this map.
+	statuses := make(map[string]task.TaskStatus, len(run.TaskIDs))
+	statusOf := func(id string) (task.TaskStatus, bool) {
+		status, ok := statuses[id]
+		return status, ok
+	}
+
+	type result struct {
+		t   *task.Task
+		err error
+	}
+	done := make(chan result)
+
+	waiting := make([]*task.Task, 0, len(run.TaskIDs))
+	for _, taskID := range run.TaskIDs {
+		t, err := gm.store.LoadTask(taskID)
+		if err != nil {
+			gm.recordRunResult(run, &task.Task{ID: taskID}, fmt.Errorf("failed to load task %s: %w", taskID, err), opts)
+			continue
+		}
+		statuses[t.ID] = t.Status
+		waiting = append(waiting, t)
+	}
+
+	stopped := !opts.ContinueOnError && run.Failed > 0
+	running := 0
+
+	for len(waiting) > 0 || running > 0 {
+		// Start every task that can start, in order
+		for i := 0; i < len(waiting); {
+			t := waiting[i]
+
+			// The task may have been cancelled while waiting for its turn
+			if current, err := gm.store.LoadTask(t.ID); err == nil {
+				t = current
+			}
+
+			ready, reason := dag.Check(t, statusOf)
+			switch {
+			case stopped || t.Status != task.StatusPending || reason != "":
+				if err := gm.skipTask(t); err != nil {
+					return err
+				}
+				statuses[t.ID] = t.Status
+				run.Skipped++
+			case !ready || running == opts.Parallel:
+				i++
+				continue
+			default:
+				statuses[t.ID] = task.StatusRunning
+				running++
+				go func(t *task.Task) {
+					// Run the task, waiting out its retries
+					err := r.RunAttempts(t)
+					if err != nil {
+						err = fmt.Errorf("failed to run task %s: %w", t.ID, err)
+					}
+					done <- result{t, err}
+				}(t)
+			}
+
+			waiting = append(waiting[:i], waiting[i+1:]...)
+			// A skipped task may unblock or skip earlier tasks, start over
+			i = 0
+		}
+
+		if running == 0 {
+			// Nothing is running and nothing can start, which only happens
+			// when the remaining tasks wait on tasks outside the run
+			for _, t := range waiting {
+				if err := gm.skipTask(t); err != nil {
+					return err
+				}
+				run.Skipped++
+			}
+			break
+		}
+
+		res := <-done
+		running--
+		statuses[res.t.ID] = res.t.Status
+		if res.err != nil && !res.t.Status.IsTerminal() {
+			statuses[res.t.ID] = task.StatusFailed
+		}
+		if !gm.recordRunResult(run, res.t, res.err, opts) && !opts.ContinueOnError {
+			stopped = true
+		}
+	}
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Duration = finishedAt.Sub(run.StartedAt)
+	run.Status = RunSucceeded
+	if run.Failed > 0 || run.Skipped > 0 {
+		run.Status = RunFailed
+	}
+
+	return gm.saveRun(run)
+}
+
+// recordRunResult counts a finished task on the run and reports whether it succeeded
+func (gm *GroupManager) recordRunResult(run *GroupRun, t *task.Task, err error, opts RunOptions) bool {
+	succeeded := err == nil && t.Status == task.StatusCompleted
+	if succeeded {
+		run.Succeeded++
+	} else {
+		run.Failed++
+	}
+
+	if opts.OnFinish != nil {
+		opts.OnFinish(t, err)
+	}
+
+	return succeeded
+}
+
+// skipTask marks a task that was not run because the group run stopped
+func (gm *GroupManager) skipTask(t *task.Task) error {
+	if t.Status.IsTerminal() {
+		return nil
+	}
+
+	now := time.Now()
+	t.Status = task.StatusSkipped
+	t.FinishedAt = &now
+
+	if err := gm.store.SaveTask(t); err != nil {
+		return fmt.Errorf("failed to save task %s: %w", t.ID, err)
+	}
+
+	return nil
+}
+
+// History returns the runs of a group, oldest first
+func (gm *GroupManager) History(groupName string) ([]*GroupRun, error) {
+	group, err := gm.GetGroupByName(groupName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+
+	runs, err := gm.ListRuns()
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]*GroupRun, 0)
+	for _, run := range runs {
+		if run.GroupID == group.ID {
+			history = append(history, run)
+		}
+	}
+
+	return history, nil
+}
+
+// GetRun returns run number n of a group
+func (gm *GroupManager) GetRun(groupName string, n int) (*GroupRun, error) {
+	history, err := gm.History(groupName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, run := range history {
+		if run.Number == n {
+			return run, nil
+		}
+	}
+
+	return nil, fmt.Errorf("run #%d of group %s %w", n, groupName, ErrNotFound)
+}
+
+// ListRuns returns the runs of all groups ordered by group and run number
+func (gm *GroupManager) ListRuns() ([]*GroupRun, error) {
+	if err := os.MkdirAll(gm.runsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create runs directory: %w", err)
+	}
+
+	files, err := os.ReadDir(gm.runsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runs directory: %w", err)
+	}
+
+	runs := make([]*GroupRun, 0, len(files))
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		runID := file.Name()[:len(file.Name())-5] // Remove .json extension
+
+		run, err := gm.loadRun(runID)
+		if err != nil {
+			// Log the error but continue loading other runs
+			fmt.Fprintf(os.Stderr, "Error loading group run %s: %v\n", runID, err)
+			continue
+		}
+
+		runs = append(runs, run)
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].GroupName != runs[j].GroupName {
+			return runs[i].GroupName < runs[j].GroupName
+		}
+		return runs[i].Number < runs[j].Number
+	})
+
+	return runs, nil
+}
+
+// DeleteRun removes the record of a run, the tasks of the run are left alone.
+// The caller holds the data directory lock.
+func (gm *GroupManager) DeleteRun(id string) error {
+	if err := os.Remove(filepath.Join(gm.runsDir, id+".json")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete group run file: %w", err)
+	}
+
+	return nil
+}
+
+// saveRun writes a run to disk
+func (gm *GroupManager) saveRun(run *GroupRun) error {
+	if err := os.MkdirAll(gm.runsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create runs directory: %w", err)
+	}
+
+	run.SchemaVersion = schema.Version
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal group run: %w", err)
+	}
+
+	if err := fsutil.WriteFile(filepath.Join(gm.runsDir, run.ID+".json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write group run file: %w", err)
+	}
+
+	return nil
+}
+
+// loadRun reads a run from disk by its ID
+func (gm *GroupManager) loadRun(id string) (*GroupRun, error) {
+	data, err := os.ReadFile(filepath.Join(gm.runsDir, id+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read group run file: %w", err)
+	}
+
+	var run GroupRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal group run: %w", err)
+	}
+	if err := schema.CheckRecord("group run", id, run.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
@@ -0,0 +1,227 @@
+package group
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/Can/sysrow/pkg/runner"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// memStore is a Store for tests that is safe for the goroutines of a group
+// run. Tasks are copied in and out, as a store on disk would.
+type memStore struct {
+	mutex sync.Mutex
+	tasks map[string]task.Task
+}
+
+func (s *memStore) SaveTask(t *task.Task) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tasks[t.ID] = *t
+	return nil
+}
+
+func (s *memStore) LoadTask(id string) (*task.Task, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	t, ok := s.tasks[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	return &t, nil
+}
+
+func (s *memStore) ListTasks() ([]*task.Task, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tasks := make([]*task.Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		t := t
+		tasks = append(tasks, &t)
+	}
+	return tasks, nil
+}
+
+func (s *memStore) DeleteTask(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.tasks, id)
+	return nil
+}
+
+// waitFor is a command that succeeds once the file exists, so two steps that
+// wait for each other's files only succeed when they run at the same time
+func waitFor(touch, wait string) string {
+	return fmt.Sprintf("touch %q; for i in $(seq 50); do test -e %q && exit 0; sleep 0.1; done; exit 1", touch, wait)
+}
+
+func TestRunGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("steps are shell commands")
+	}
+
+	tests := []struct {
+		name  string
+		steps func(dir string) []Step
+		opts  RunOptions
+		want  []task.TaskStatus
+		// succeeded, failed and skipped are the counts of the run summary
+		succeeded, failed, skipped int
+		status                     string
+	}{
+		{
+			name: "all succeed",
+			steps: func(string) []Step {
+				return []Step{{Command: "true"}, {Command: "true"}}
+			},
+			want:      []task.TaskStatus{task.StatusCompleted, task.StatusCompleted},
+			succeeded: 2,
+			status:    RunSucceeded,
+		},
+		{
+			name: "fail fast skips the rest",
+			steps: func(string) []Step {
+				return []Step{{Command: "true"}, {Command: "false"}, {Command: "true"}}
+			},
+			want:      []task.TaskStatus{task.StatusCompleted, task.StatusFailed, task.StatusSkipped},
+			succeeded: 1, failed: 1, skipped: 1,
+			status: RunFailed,
+		},
+		{
+			name: "continue on error runs the rest",
+			steps: func(string) []Step {
+				return []Step{{Command: "true"}, {Command: "false"}, {Command: "true"}}
+			},
+			opts:      RunOptions{ContinueOnError: true},
+			want:      []task.TaskStatus{task.StatusCompleted, task.StatusFailed, task.StatusCompleted},
+			succeeded: 2, failed: 1,
+			status: RunFailed,
+		},
+		{
+			name: "continue on error skips dependents of a failed step",
+			steps: func(string) []Step {
+				return []Step{
+					{Name: "build", Command: "false"},
+					{Name: "deploy", Command: "true", DependsOn: []string{"build"}},
+					{Name: "notify", Command: "true"},
+				}
+			},
+			opts:      RunOptions{ContinueOnError: true},
+			want:      []task.TaskStatus{task.StatusFailed, task.StatusSkipped, task.StatusCompleted},
+			succeeded: 1, failed: 1, skipped: 1,
+			status: RunFailed,
+		},
+		{
+			name: "failure condition runs on failure",
+			steps: func(string) []Step {
+				return []Step{
+					{Name: "build", Command: "false"},
+					{Name: "alert", Command: "true", DependsOn: []string{"build"}, Condition: task.ConditionFailure},
+				}
+			},
+			opts:      RunOptions{ContinueOnError: true},
+			want:      []task.TaskStatus{task.StatusFailed, task.StatusCompleted},
+			succeeded: 1, failed: 1,
+			status: RunFailed,
+		},
+		{
+			name: "parallel steps run at the same time",
+			steps: func(dir string) []Step {
+				a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+				return []Step{{Command: waitFor(a, b)}, {Command: waitFor(b, a)}}
+			},
+			opts:      RunOptions{Parallel: 2},
+			want:      []task.TaskStatus{task.StatusCompleted, task.StatusCompleted},
+			succeeded: 2,
+			status:    RunSucceeded,
+		},
+		{
+			name: "parallel fail fast lets running steps finish",
+			steps: func(string) []Step {
+				return []Step{
+					{Command: "sleep 0.3"},
+					{Command: "false"},
+					{Command: "true"},
+				}
+			},
+			opts:      RunOptions{Parallel: 2},
+			want:      []task.TaskStatus{task.StatusCompleted, task.StatusFailed, task.StatusSkipped},
+			succeeded: 1, failed: 1, skipped: 1,
+			status: RunFailed,
+		},
+		{
+			name: "parallel waits for dependencies",
+			steps: func(dir string) []Step {
+				a := filepath.Join(dir, "a")
+				return []Step{
+					{Name: "first", Command: "sleep 0.2; touch " + a},
+					{Name: "second", Command: "test -e " + a, DependsOn: []string{"first"}},
+				}
+			},
+			opts:      RunOptions{Parallel: 2},
+			want:      []task.TaskStatus{task.StatusCompleted, task.StatusCompleted},
+			succeeded: 2,
+			status:    RunSucceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			task.DataDirectory = dataDir
+			store := &memStore{tasks: make(map[string]task.Task)}
+			gm := NewGroupManager(dataDir, store)
+
+			if _, err := gm.CreateGroup("test"); err != nil {
+				t.Fatal(err)
+			}
+			for _, step := range tt.steps(dataDir) {
+				if err := gm.AddStep("test", step); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			run, err := gm.NewRun("test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := gm.RunGroup(run, runner.NewRunner(dataDir, store), tt.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]task.TaskStatus, 0, len(run.TaskIDs))
+			for _, id := range run.TaskIDs {
+				tk, err := store.LoadTask(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, tk.Status)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statuses = %v, want %v", got, tt.want)
+			}
+
+			if run.Succeeded != tt.succeeded || run.Failed != tt.failed || run.Skipped != tt.skipped {
+				t.Errorf("summary = %d succeeded, %d failed, %d skipped, want %d, %d, %d",
+					run.Succeeded, run.Failed, run.Skipped, tt.succeeded, tt.failed, tt.skipped)
+			}
+			if run.Status != tt.status {
+				t.Errorf("status = %s, want %s", run.Status, tt.status)
+			}
+
+			// The summary is saved with the run
+			saved, err := gm.GetRun("test", run.Number)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.Status != tt.status || saved.FinishedAt == nil {
+				t.Errorf("saved run = %s, finished %v, want %s and finished", saved.Status, saved.FinishedAt, tt.status)
+			}
+		})
+	}
+}
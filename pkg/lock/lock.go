@@ -0,0 +1,113 @@
+// Package lock provides advisory file locks that work across processes.
+// A lock is released automatically when the process holding it exits, so a
+// crashed sysrow never leaves a stale lock behind.
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrLocked is returned by TryAcquire when another holder has the lock
+var ErrLocked = errors.New("lock is held by another process")
+
+// Lock is an exclusive lock on a file
+type Lock struct {
+	file *os.File
+}
+
+// Acquire takes the lock on path, waiting until it is free
+func Acquire(path string) (*Lock, error) {
+	return acquire(path, true)
+}
+
+// TryAcquire takes the lock on path, or returns ErrLocked right away if it is taken
+func TryAcquire(path string) (*Lock, error) {
+	return acquire(path, false)
+}
+
+// DataDir takes the lock of the data directory. It is held while records
+// are read, changed and written back, so that concurrent sysrow processes
+// do not overwrite each other's changes.
+func DataDir(dataDir string) (*Lock, error) {
+	l, err := Acquire(filepath.Join(Dir(dataDir), "data.lock"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock data directory: %w", err)
+	}
+
+	return l, nil
+}
+
+// Dir returns the directory that holds the lock files of a data directory
+func Dir(dataDir string) string {
+	return filepath.Join(dataDir, "locks")
+}
+
+// acquire opens the lock file and locks it. The holder of a lock may remove
+// its file before releasing it; a lock taken on a removed file excludes
+// nobody, so it is dropped and the file now at path is locked instead.
+func acquire(path string, block bool) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open lock file: %w", err)
+		}
+
+		if err := lockFile(f, block); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		same, err := isFileAt(f, path)
+		if err != nil {
+			unlockFile(f)
+			f.Close()
+			return nil, err
+		}
+		if same {
+			return &Lock{file: f}, nil
+		}
+
+		unlockFile(f)
+		f.Close()
+	}
+}
+
+// isFileAt reports whether path still refers to the open file f
+func isFileAt(f *os.File, path string) (bool, error) {
+	current, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat lock file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat lock file: %w", err)
+	}
+
+	return os.SameFile(info, current), nil
+}
+
+// Release frees the lock
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	err := unlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+
+	return err
+}
@@ -0,0 +1,49 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestAcquireAfterRemove(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("open files cannot be removed on windows")
+	}
+	path := filepath.Join(t.TempDir(), "task.lock")
+
+	first, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A waiter opens the file that is about to be removed
+	acquired := make(chan *Lock)
+	go func() {
+		l, err := Acquire(path)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- l
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// The holder removes the file before releasing it, as a finished claim does
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	second := <-acquired
+	defer second.Release()
+
+	// The waiter must hold the lock on the file now at path
+	if _, err := TryAcquire(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("TryAcquire() error = %v, want ErrLocked", err)
+	}
+}
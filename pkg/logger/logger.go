@@ -82,3 +82,14 @@ func (l *Logger) GetLogs(taskID string) (string, error) {
 
 	return string(logData), nil
 }
+
+// TaskLogFiles returns every log file of a task: the application log, the
+// output logs and the output logs of earlier attempts
+func TaskLogFiles(dataDir, taskID string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dataDir, "logs", taskID+".*.log"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find log files: %w", err)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,223 @@
+// Package output prints the results of commands in the formats scripts
+// consume: JSON, JSON lines, YAML or a Go text/template.
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// Output formats
+const (
+	// FormatText is the human readable output of each command
+	FormatText = "text"
+	// FormatJSON writes a single indented JSON document
+	FormatJSON = "json"
+	// FormatJSONL writes one compact JSON document per line and item
+	FormatJSONL = "jsonl"
+	// FormatYAML writes a single YAML document
+	FormatYAML = "yaml"
+	// FormatTemplate executes a text/template once per item
+	FormatTemplate = "template"
+)
+
+// Printer prints the result of a command in the chosen format
+type Printer struct {
+	format   string
+	template *template.Template
+}
+
+// New returns a printer for the given format. A template given without a
+// format selects the template format, as in `--format '{{.ID}}'`.
+func New(format, text string) (*Printer, error) {
+	if format == "" {
+		format = FormatText
+		if text != "" {
+			format = FormatTemplate
+		}
+	}
+
+	p := &Printer{format: format}
+	switch format {
+	case FormatText, FormatJSON, FormatJSONL, FormatYAML:
+		if text != "" {
+			return nil, fmt.Errorf("a template can only be used with the %s output", FormatTemplate)
+		}
+	case FormatTemplate:
+		if text == "" {
+			return nil, fmt.Errorf("the %s output needs a template", FormatTemplate)
+		}
+
+		tmpl, err := template.New("format").Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		p.template = tmpl
+	default:
+		return nil, fmt.Errorf("invalid output format %q (expected %s, %s, %s, %s or %s)", format, FormatText, FormatJSON, FormatJSONL, FormatYAML, FormatTemplate)
+	}
+
+	return p, nil
+}
+
+// Machine reports whether the printer writes a machine readable format, in
+// which case the command must not print its human readable output
+func (p *Printer) Machine() bool {
+	return p.format != FormatText
+}
+
+// Print writes a single value
+func (p *Printer) Print(w io.Writer, v interface{}) error {
+	switch p.format {
+	case FormatJSON:
+		return writeJSON(w, v, true)
+	case FormatJSONL:
+		return writeJSON(w, v, false)
+	case FormatYAML:
+		return writeYAML(w, v)
+	case FormatTemplate:
+		return p.execute(w, v)
+	default:
+		return fmt.Errorf("the %s output is printed by the command itself", FormatText)
+	}
+}
+
+// List writes the items of a slice. JSON and YAML write them as one array,
+// JSON lines and templates write one line per item.
+func (p *Printer) List(w io.Writer, items interface{}) error {
+	value := reflect.ValueOf(items)
+	if value.Kind() != reflect.Slice {
+		return fmt.Errorf("cannot list a %s", value.Kind())
+	}
+
+	switch p.format {
+	case FormatJSONL, FormatTemplate:
+		for i := 0; i < value.Len(); i++ {
+			if err := p.Print(w, value.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		// Print an empty array rather than null
+		if value.IsNil() {
+			items = []struct{}{}
+		}
+		return p.Print(w, items)
+	}
+}
+
+// Stream writes the items of a list as they are produced, for lists too
+// long to collect first. JSON and YAML items still form one array, which
+// Close ends.
+type Stream struct {
+	printer *Printer
+	w       io.Writer
+	count   int
+}
+
+// Stream starts a list whose items are written one by one
+func (p *Printer) Stream(w io.Writer) *Stream {
+	return &Stream{printer: p, w: w}
+}
+
+// Add writes the next item of the list
+func (s *Stream) Add(v interface{}) error {
+	var err error
+	switch s.printer.format {
+	case FormatJSON:
+		// Indent the item as an element of an indented array
+		var data []byte
+		if data, err = marshalJSON(v, true); err == nil {
+			prefix := "[\n  "
+			if s.count > 0 {
+				prefix = ",\n  "
+			}
+			item := strings.ReplaceAll(strings.TrimSuffix(string(data), "\n"), "\n", "\n  ")
+			_, err = io.WriteString(s.w, prefix+item)
+		}
+	case FormatYAML:
+		// A list of one item is written as a single sequence entry
+		err = writeYAML(s.w, []interface{}{v})
+	default:
+		err = s.printer.Print(s.w, v)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.count++
+	return nil
+}
+
+// Close ends the list
+func (s *Stream) Close() error {
+	var end string
+	switch {
+	case s.printer.format == FormatJSON && s.count > 0:
+		end = "\n]\n"
+	case (s.printer.format == FormatJSON || s.printer.format == FormatYAML) && s.count == 0:
+		end = "[]\n"
+	}
+
+	_, err := io.WriteString(s.w, end)
+	return err
+}
+
+// execute runs the template for one item and ends its output with a newline
+func (p *Printer) execute(w io.Writer, v interface{}) error {
+	var buf bytes.Buffer
+	if err := p.template.Execute(&buf, v); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// funcs are the functions templates may use besides the built-in ones
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := marshalJSON(v, false)
+		return strings.TrimSuffix(string(data), "\n"), err
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// writeJSON writes a value as JSON followed by a newline
+func writeJSON(w io.Writer, v interface{}, indent bool) error {
+	data, err := marshalJSON(v, indent)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// marshalJSON encodes a value without escaping HTML characters, which
+// commands are full of
+func marshalJSON(v interface{}, indent bool) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if indent {
+		enc.SetIndent("", "  ")
+	}
+
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to marshal output: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,87 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+type item struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func TestList(t *testing.T) {
+	items := []item{{ID: "a", Status: "completed"}, {ID: "b", Status: "failed"}}
+
+	tests := []struct {
+		format, template string
+		items            []item
+		want             string
+	}{
+		{format: FormatJSON, items: items, want: "[\n  {\n    \"id\": \"a\",\n    \"status\": \"completed\"\n  },\n  {\n    \"id\": \"b\",\n    \"status\": \"failed\"\n  }\n]\n"},
+		{format: FormatJSON, items: nil, want: "[]\n"},
+		{format: FormatJSONL, items: items, want: "{\"id\":\"a\",\"status\":\"completed\"}\n{\"id\":\"b\",\"status\":\"failed\"}\n"},
+		{format: FormatJSONL, items: nil, want: ""},
+		{format: FormatYAML, items: items, want: "- id: a\n  status: completed\n- id: b\n  status: failed\n"},
+		{format: FormatYAML, items: nil, want: "[]\n"},
+		{template: "{{.ID}} {{.Status | upper}}", items: items, want: "a COMPLETED\nb FAILED\n"},
+	}
+
+	for _, tt := range tests {
+		p, err := New(tt.format, tt.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A stream writes what List writes for the same items
+		var list, stream bytes.Buffer
+		if err := p.List(&list, tt.items); err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		s := p.Stream(&stream)
+		for _, it := range tt.items {
+			if err := s.Add(it); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+
+		if got := list.String(); got != tt.want {
+			t.Errorf("List(%s%s, %d items) = %q, want %q", tt.format, tt.template, len(tt.items), got, tt.want)
+		}
+		if got := stream.String(); got != tt.want {
+			t.Errorf("Stream(%s%s, %d items) = %q, want %q", tt.format, tt.template, len(tt.items), got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		format, template string
+		wantMachine      bool
+		wantErr          bool
+	}{
+		{format: "", wantMachine: false},
+		{format: FormatText, wantMachine: false},
+		{format: FormatJSON, wantMachine: true},
+		{format: "", template: "{{.ID}}", wantMachine: true},
+		{format: FormatTemplate, template: "{{.ID}}", wantMachine: true},
+		{format: FormatTemplate, wantErr: true},
+		{format: FormatJSON, template: "{{.ID}}", wantErr: true},
+		{format: "", template: "{{.ID", wantErr: true},
+		{format: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		p, err := New(tt.format, tt.template)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("New(%q, %q) error = %v, want error %v", tt.format, tt.template, err, tt.wantErr)
+			continue
+		}
+		if err == nil && p.Machine() != tt.wantMachine {
+			t.Errorf("New(%q, %q).Machine() = %v, want %v", tt.format, tt.template, p.Machine(), tt.wantMachine)
+		}
+	}
+}
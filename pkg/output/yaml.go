@@ -0,0 +1,235 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// node is a decoded JSON value that keeps the order of object keys, so
+// YAML output lists fields in the same order as JSON output
+type node struct {
+	// keys and values are set for objects
+	keys   []string
+	values []*node
+	// items is set for arrays
+	items []*node
+	// scalar is set for strings, numbers, booleans and null
+	scalar interface{}
+	kind   byte
+}
+
+// Kinds of nodes
+const (
+	kindScalar = 's'
+	kindObject = 'o'
+	kindArray  = 'a'
+)
+
+// plainPattern matches strings that can be written without quotes
+var plainPattern = regexp.MustCompile(`^[A-Za-z0-9_/.][A-Za-z0-9_ ./+@=()-]*$`)
+
+// datePattern matches the start of the dates YAML reads as timestamps
+var datePattern = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}`)
+
+// reservedWords are plain strings YAML would read as another type
+var reservedWords = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true, "~": true, ".inf": true, ".nan": true,
+}
+
+// writeYAML writes a value as a YAML document. The value is encoded as
+// JSON first so that its JSON field names and omitempty are respected.
+func writeYAML(w io.Writer, v interface{}) error {
+	data, err := marshalJSON(v, false)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	n, err := decodeNode(dec)
+	if err != nil {
+		return fmt.Errorf("failed to convert output to YAML: %w", err)
+	}
+
+	var buf bytes.Buffer
+	writeNode(&buf, n, "")
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// decodeNode reads the next value from the decoder
+func decodeNode(dec *json.Decoder) (*node, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		n := &node{kind: kindObject}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key.(string))
+			n.values = append(n.values, value)
+		}
+		_, err := dec.Token()
+		return n, err
+	case json.Delim('['):
+		n := &node{kind: kindArray}
+		for dec.More() {
+			item, err := decodeNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		}
+		_, err := dec.Token()
+		return n, err
+	default:
+		return &node{kind: kindScalar, scalar: token}, nil
+	}
+}
+
+// block reports whether a node is written on the lines below its key or dash
+func (n *node) block() bool {
+	return (n.kind == kindObject && len(n.keys) > 0) || (n.kind == kindArray && len(n.items) > 0)
+}
+
+// writeNode writes a node whose first line starts at the current position
+// and whose other lines are indented by indent
+func writeNode(buf *bytes.Buffer, n *node, indent string) {
+	switch {
+	case n.kind == kindObject && len(n.keys) == 0:
+		buf.WriteString("{}\n")
+	case n.kind == kindArray && len(n.items) == 0:
+		buf.WriteString("[]\n")
+	case n.kind == kindObject:
+		for i, key := range n.keys {
+			if i > 0 {
+				buf.WriteString(indent)
+			}
+			buf.WriteString(scalar(key, indent))
+			buf.WriteString(":")
+
+			value := n.values[i]
+			switch {
+			case value.kind == kindObject && value.block():
+				buf.WriteString("\n" + indent + "  ")
+				writeNode(buf, value, indent+"  ")
+			case value.kind == kindArray && value.block():
+				buf.WriteString("\n" + indent)
+				writeNode(buf, value, indent)
+			default:
+				buf.WriteString(" ")
+				writeNode(buf, value, indent+"  ")
+			}
+		}
+	case n.kind == kindArray:
+		for i, item := range n.items {
+			if i > 0 {
+				buf.WriteString(indent)
+			}
+			buf.WriteString("- ")
+			writeNode(buf, item, indent+"  ")
+		}
+	default:
+		buf.WriteString(scalar(n.scalar, indent))
+		buf.WriteString("\n")
+	}
+}
+
+// scalar formats a string, number, boolean or null. Continuation lines of
+// multi-line strings are indented by indent.
+func scalar(v interface{}, indent string) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return v.String()
+	case string:
+		return yamlString(v, indent)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// yamlString writes a string plain when YAML reads it back unchanged, as a
+// literal block when it spans several lines and double-quoted otherwise
+func yamlString(s, indent string) string {
+	if plainPattern.MatchString(s) && !reservedWords[strings.ToLower(s)] && !strings.HasSuffix(s, " ") && !looksLikeNumber(s) {
+		return s
+	}
+
+	if strings.Contains(s, "\n") && literalSafe(s) {
+		body := strings.TrimRight(s, "\n")
+		chomp := "-"
+		switch trailing := len(s) - len(body); {
+		case trailing == 1:
+			chomp = ""
+		case trailing > 1:
+			chomp = "+"
+		}
+
+		var b strings.Builder
+		b.WriteString("|" + chomp)
+		for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+			b.WriteString("\n")
+			if line != "" {
+				b.WriteString(indent + line)
+			}
+		}
+		return b.String()
+	}
+
+	// JSON strings are valid double-quoted YAML strings
+	data, _ := marshalJSON(s, false)
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// literalSafe reports whether a multi-line string can be written as a
+// literal block, which cannot hold control characters or start with a space
+func literalSafe(s string) bool {
+	if strings.HasPrefix(s, " ") || strings.HasPrefix(s, "\n") {
+		return false
+	}
+
+	for _, r := range s {
+		if r == '\r' || (r < ' ' && r != '\n' && r != '\t') || r == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// looksLikeNumber reports whether YAML would read a plain string as a
+// number or a date
+func looksLikeNumber(s string) bool {
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return true
+	}
+
+	return datePattern.MatchString(s)
+}
@@ -0,0 +1,105 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestYAMLString(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "word", value: "completed", want: "completed"},
+		{name: "command", value: "make build", want: "make build"},
+		{name: "path", value: "/usr/bin/env", want: "/usr/bin/env"},
+		{name: "uuid", value: "4f1c9a2e-8b7d-4e3f-9a6b-2c1d0e9f8a7b", want: "4f1c9a2e-8b7d-4e3f-9a6b-2c1d0e9f8a7b"},
+		{name: "empty", value: "", want: `""`},
+		{name: "boolean", value: "true", want: `"true"`},
+		{name: "yes in capitals", value: "YES", want: `"YES"`},
+		{name: "off", value: "off", want: `"off"`},
+		{name: "single letter boolean", value: "n", want: `"n"`},
+		{name: "null", value: "null", want: `"null"`},
+		{name: "tilde", value: "~", want: `"~"`},
+		{name: "infinity", value: ".inf", want: `".inf"`},
+		{name: "not a number", value: ".NaN", want: `".NaN"`},
+		{name: "integer", value: "42", want: `"42"`},
+		{name: "float", value: "1.5", want: `"1.5"`},
+		{name: "exponent", value: "1e3", want: `"1e3"`},
+		{name: "hex", value: "0x1F", want: `"0x1F"`},
+		{name: "octal", value: "0o17", want: `"0o17"`},
+		{name: "date", value: "2026-10-17", want: `"2026-10-17"`},
+		{name: "timestamp", value: "2026-10-17T12:00:00Z", want: `"2026-10-17T12:00:00Z"`},
+		{name: "version", value: "1.2.3", want: "1.2.3"},
+		{name: "leading space", value: " x", want: `" x"`},
+		{name: "trailing space", value: "x ", want: `"x "`},
+		{name: "colon", value: "a: b", want: `"a: b"`},
+		{name: "comment", value: "x #y", want: `"x #y"`},
+		{name: "indicator", value: "-x", want: `"-x"`},
+		{name: "flow", value: "[a]", want: `"[a]"`},
+		{name: "quote", value: `say "hi"`, want: `"say \"hi\""`},
+		{name: "html", value: "a && b > c", want: `"a && b > c"`},
+		{name: "multi-line", value: "one\ntwo", want: "|-\n    one\n    two"},
+		{name: "multi-line with line end", value: "one\ntwo\n", want: "|\n    one\n    two"},
+		{name: "multi-line with blank lines at the end", value: "one\ntwo\n\n", want: "|+\n    one\n    two\n"},
+		{name: "multi-line with a blank line", value: "one\n\ntwo", want: "|-\n    one\n\n    two"},
+		{name: "multi-line starting with a space", value: " one\ntwo", want: `" one\ntwo"`},
+		{name: "multi-line starting with a line end", value: "\none", want: `"\none"`},
+		{name: "carriage return", value: "one\r\ntwo", want: `"one\r\ntwo"`},
+		{name: "control character", value: "one\ntwo\x1b[0m", want: `"one\ntwo\u001b[0m"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := yamlString(tt.value, "    "); got != tt.want {
+				t.Errorf("yamlString(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	type step struct {
+		Name    string            `json:"name"`
+		Command string            `json:"command"`
+		Env     map[string]string `json:"env,omitempty"`
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{name: "scalar", value: 3, want: "3\n"},
+		{name: "empty list", value: []string{}, want: "[]\n"},
+		{name: "empty object", value: struct{}{}, want: "{}\n"},
+		{
+			name:  "fields in JSON order without omitted ones",
+			value: step{Name: "build", Command: "make\nmake install"},
+			want:  "name: build\ncommand: |-\n  make\n  make install\n",
+		},
+		{
+			name:  "nested",
+			value: map[string]interface{}{"steps": []step{{Name: "a", Command: "true", Env: map[string]string{"A": "1"}}}, "tags": []string{}, "count": nil},
+			want:  "count: null\nsteps:\n- name: a\n  command: \"true\"\n  env:\n    A: \"1\"\ntags: []\n",
+		},
+		{
+			name:  "multi-line string in a list",
+			value: []interface{}{[]string{"one\ntwo"}},
+			want:  "- - |-\n    one\n    two\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeYAML(&buf, tt.value); err != nil {
+				t.Fatalf("writeYAML() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writeYAML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
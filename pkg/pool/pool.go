@@ -0,0 +1,162 @@
+package pool
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Can/sysrow/pkg/runner"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// Pool runs tasks through a runner using a fixed number of worker slots.
+// The last slots can be reserved for high priority tasks so that a flood of
+// lower priority work cannot starve urgent jobs.
+type Pool struct {
+	mutex        sync.Mutex
+	wg           sync.WaitGroup
+	runner       *runner.Runner
+	reservedHigh int
+	slots        []*task.Task
+}
+
+// NewPool creates a pool with size slots, reservedHigh of which only accept high priority tasks
+func NewPool(r *runner.Runner, size, reservedHigh int) (*Pool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("pool size must be at least 1, got %d", size)
+	}
+
+	if reservedHigh < 0 || reservedHigh >= size {
+		return nil, fmt.Errorf("reserved slots must be between 0 and %d, got %d", size-1, reservedHigh)
+	}
+
+	return &Pool{
+		runner:       r,
+		reservedHigh: reservedHigh,
+		slots:        make([]*task.Task, size),
+	}, nil
+}
+
+// CanAccept reports whether a task with the given priority would get a slot right now
+func (p *Pool) CanAccept(priority task.TaskPriority) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.freeSlot(priority) >= 0
+}
+
+// Submit starts the task in a free slot. It returns false if no slot is
+// available for the task's priority. onDone, if not nil, is called after the
+// task has finished and its slot has been released.
+func (p *Pool) Submit(t *task.Task, onDone func(*task.Task, error)) bool {
+	p.mutex.Lock()
+	slot := p.freeSlot(t.Priority)
+	if slot < 0 {
+		p.mutex.Unlock()
+		return false
+	}
+	p.slots[slot] = t
+	p.mutex.Unlock()
+
+	// Slots are shown to users starting from 1
+	slotNumber := slot + 1
+	t.Slot = &slotNumber
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		err := p.runner.RunTask(t, false)
+
+		p.mutex.Lock()
+		p.slots[slot] = nil
+		p.mutex.Unlock()
+
+		if onDone != nil {
+			onDone(t, err)
+		}
+	}()
+
+	return true
+}
+
+// Size returns the total number of slots
+func (p *Pool) Size() int {
+	return len(p.slots)
+}
+
+// Running returns the number of occupied slots
+func (p *Pool) Running() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	running := 0
+	for _, t := range p.slots {
+		if t != nil {
+			running++
+		}
+	}
+
+	return running
+}
+
+// Tasks returns the IDs of the tasks in occupied slots
+func (p *Pool) Tasks() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ids := make([]string, 0, len(p.slots))
+	for _, t := range p.slots {
+		if t != nil {
+			ids = append(ids, t.ID)
+		}
+	}
+
+	return ids
+}
+
+// Wait blocks until all submitted tasks have finished
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+// WaitTimeout blocks until all submitted tasks have finished or the timeout
+// has passed, and reports whether they finished
+func (p *Pool) WaitTimeout(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// freeSlot returns the index of a slot the priority may use, or -1.
+// General slots are preferred so reserved slots stay free for urgent work.
+func (p *Pool) freeSlot(priority task.TaskPriority) int {
+	general := len(p.slots) - p.reservedHigh
+
+	for i := 0; i < general; i++ {
+		if p.slots[i] == nil {
+			return i
+		}
+	}
+
+	if priority != task.PriorityHigh {
+		return -1
+	}
+
+	for i := general; i < len(p.slots); i++ {
+		if p.slots[i] == nil {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,72 @@
+package pool
+
+import (
+	"testing"
+
+	"github.com/Can/sysrow/pkg/task"
+)
+
+func TestNewPool(t *testing.T) {
+	tests := []struct {
+		size, reserved int
+		wantErr        bool
+	}{
+		{size: 1, reserved: 0},
+		{size: 4, reserved: 3},
+		{size: 0, reserved: 0, wantErr: true},
+		{size: 4, reserved: 4, wantErr: true},
+		{size: 4, reserved: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := NewPool(nil, tt.size, tt.reserved)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewPool(%d, %d) error = %v, want error %v", tt.size, tt.reserved, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFreeSlot(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		reserved int
+		// occupied are the indexes of the busy slots
+		occupied []int
+		priority task.TaskPriority
+		want     int
+	}{
+		{name: "empty pool", size: 3, reserved: 1, priority: task.PriorityLow, want: 0},
+		{name: "high prefers general slots", size: 3, reserved: 1, priority: task.PriorityHigh, want: 0},
+		{name: "next general slot", size: 3, reserved: 1, occupied: []int{0}, priority: task.PriorityNormal, want: 1},
+		{name: "normal cannot use reserved", size: 3, reserved: 1, occupied: []int{0, 1}, priority: task.PriorityNormal, want: -1},
+		{name: "low cannot use reserved", size: 3, reserved: 2, occupied: []int{0}, priority: task.PriorityLow, want: -1},
+		{name: "high uses reserved", size: 3, reserved: 1, occupied: []int{0, 1}, priority: task.PriorityHigh, want: 2},
+		{name: "high uses second reserved", size: 4, reserved: 2, occupied: []int{0, 1, 2}, priority: task.PriorityHigh, want: 3},
+		{name: "freed general slot is reused", size: 3, reserved: 1, occupied: []int{1, 2}, priority: task.PriorityNormal, want: 0},
+		{name: "full pool", size: 2, reserved: 1, occupied: []int{0, 1}, priority: task.PriorityHigh, want: -1},
+		{name: "no reserved slots", size: 2, reserved: 0, occupied: []int{0}, priority: task.PriorityLow, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPool(nil, tt.size, tt.reserved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, i := range tt.occupied {
+				p.slots[i] = task.NewTask("true", task.PriorityNormal)
+			}
+
+			if got := p.freeSlot(tt.priority); got != tt.want {
+				t.Errorf("freeSlot(%s) = %d, want %d", tt.priority, got, tt.want)
+			}
+			if got := p.CanAccept(tt.priority); got != (tt.want >= 0) {
+				t.Errorf("CanAccept(%s) = %v, want %v", tt.priority, got, tt.want >= 0)
+			}
+			if got := p.Running(); got != len(tt.occupied) {
+				t.Errorf("Running() = %d, want %d", got, len(tt.occupied))
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package proc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalNames maps the signals users can pass on the command line to their values
+var signalNames = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// ParseSignal parses a signal given as a name (TERM, SIGTERM, term) or a number (15)
+func ParseSignal(value string) (syscall.Signal, error) {
+	value = strings.TrimSpace(value)
+
+	if n, err := strconv.Atoi(value); err == nil {
+		if n <= 0 {
+			return 0, fmt.Errorf("invalid signal number %d", n)
+		}
+		return syscall.Signal(n), nil
+	}
+
+	name := strings.ToUpper(value)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	if sig, ok := lookupSignal(name); ok {
+		return sig, nil
+	}
+
+	return 0, fmt.Errorf("unknown signal %q", value)
+}
+
+// SignalName returns the conventional name of a signal, e.g. SIGTERM
+func SignalName(sig syscall.Signal) string {
+	for name, s := range signalNames {
+		if s == sig {
+			return name
+		}
+	}
+
+	for name, s := range platformSignals {
+		if s == sig {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("signal %d", int(sig))
+}
+
+// lookupSignal finds a signal by its SIG-prefixed name
+func lookupSignal(name string) (syscall.Signal, bool) {
+	if sig, ok := signalNames[name]; ok {
+		return sig, true
+	}
+
+	sig, ok := platformSignals[name]
+	return sig, ok
+}
@@ -0,0 +1,200 @@
+//go:build !windows
+
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// platformSignals are signals that only exist on Unix systems
+var platformSignals = map[string]syscall.Signal{
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGSTOP": syscall.SIGSTOP,
+	"SIGCONT": syscall.SIGCONT,
+}
+
+// NewSession makes the command start in its own session and process group,
+// so that it and all of its children can be signalled together
+func NewSession(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+}
+
+// Alive reports whether a process with the given PID exists. Zombies, which
+// have exited but not been reaped by their parent, are not considered alive.
+func Alive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	if err != nil && !errors.Is(err, syscall.EPERM) {
+		return false
+	}
+
+	state, err := processState(pid)
+	return err != nil || state != 'Z'
+}
+
+// Signal sends a signal to a single process
+func Signal(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
+
+// GroupAlive reports whether any process in the process group led by pid exists
+func GroupAlive(pid int) bool {
+	err := syscall.Kill(-pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// TerminateGroup sends sig to the process group led by pid and waits up to
+// grace for it to exit. Processes still alive after the grace period are
+// killed with SIGKILL. It returns the last signal that was sent.
+//
+// Processes started before sessions were used have no group of their own;
+// for those only the process itself is signalled. If start is not nil the
+// process is only signalled while its start time matches, so a process that
+// reused the PID is left alone.
+func TerminateGroup(pid int, start *uint64, sig syscall.Signal, grace time.Duration) (syscall.Signal, error) {
+	target := -pid
+	alive := GroupAlive
+	if err := syscall.Kill(target, sig); err != nil {
+		if !errors.Is(err, syscall.ESRCH) {
+			return sig, err
+		}
+		if start != nil {
+			if startTime, err := StartTime(pid); err != nil || startTime != *start {
+				// Already gone, or the PID belongs to another process now
+				return sig, nil
+			}
+		}
+
+		target = pid
+		alive = Alive
+		if err := syscall.Kill(target, sig); err != nil {
+			if errors.Is(err, syscall.ESRCH) {
+				// Already gone
+				return sig, nil
+			}
+			return sig, err
+		}
+	}
+
+	if sig == syscall.SIGKILL || waitExit(pid, alive, grace) {
+		return sig, nil
+	}
+
+	if err := syscall.Kill(target, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return syscall.SIGKILL, err
+	}
+	waitExit(pid, alive, 5*time.Second)
+
+	return syscall.SIGKILL, nil
+}
+
+// ExitSignal returns the signal that terminated a command, if any, from the error returned by Wait
+func ExitSignal(err error) (syscall.Signal, bool) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, false
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return 0, false
+	}
+
+	return status.Signal(), true
+}
+
+// waitExit polls until alive reports false or the timeout expires
+func waitExit(pid int, alive func(int) bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if !alive(pid) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// processState returns the state letter from /proc/<pid>/stat (R, S, Z, ...)
+func processState(pid int) (byte, error) {
+	fields, err := statFields(pid)
+	if err != nil {
+		return 0, err
+	}
+
+	return fields[0][0], nil
+}
+
+// StartTime returns the start time of a process in clock ticks since boot,
+// as found in field 22 of /proc/<pid>/stat. Together with the PID it
+// identifies a process even if the PID is later reused.
+func StartTime(pid int) (uint64, error) {
+	fields, err := statFields(pid)
+	if err != nil {
+		return 0, err
+	}
+
+	const startTimeField = 22 - 3
+	if len(fields) <= startTimeField {
+		return 0, fmt.Errorf("malformed stat file for process %d", pid)
+	}
+
+	return strconv.ParseUint(fields[startTimeField], 10, 64)
+}
+
+// statFields returns the fields of /proc/<pid>/stat that follow the command
+// name, so the first returned field is field 3 (state)
+func statFields(pid int) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The command name in field 2 may contain spaces and parentheses, so
+	// start parsing after the last closing parenthesis
+	end := strings.LastIndexByte(string(data), ')')
+	if end < 0 {
+		return nil, fmt.Errorf("malformed stat file for process %d", pid)
+	}
+
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("malformed stat file for process %d", pid)
+	}
+
+	return fields, nil
+}
+
+// StartDetached starts a process in its own session so it survives the
+// terminal that started it, and returns its PID
+func StartDetached(executable string, args []string, output *os.File) (int, error) {
+	cmd := exec.Command(executable, args...)
+	cmd.Stdin = nil
+	cmd.Stdout = output
+	cmd.Stderr = output
+	NewSession(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	pid := cmd.Process.Pid
+	if err := cmd.Process.Release(); err != nil {
+		return 0, err
+	}
+
+	return pid, nil
+}
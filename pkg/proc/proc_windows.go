@@ -0,0 +1,63 @@
+//go:build windows
+
+package proc
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// platformSignals is empty on Windows
+var platformSignals = map[string]syscall.Signal{}
+
+// NewSession is a no-op on Windows
+func NewSession(cmd *exec.Cmd) {}
+
+// Alive reports whether a process with the given PID exists
+func Alive(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
+
+// Signal is not supported on Windows
+func Signal(pid int, sig syscall.Signal) error {
+	return errors.New("signals are not supported on windows")
+}
+
+// GroupAlive falls back to checking the process itself on Windows
+func GroupAlive(pid int) bool {
+	return Alive(pid)
+}
+
+// TerminateGroup kills the process on Windows, where process groups and
+// graceful signals are not available
+func TerminateGroup(pid int, start *uint64, sig syscall.Signal, grace time.Duration) (syscall.Signal, error) {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return syscall.SIGKILL, err
+	}
+
+	if err := p.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return syscall.SIGKILL, err
+	}
+
+	return syscall.SIGKILL, nil
+}
+
+// ExitSignal always reports false on Windows
+func ExitSignal(err error) (syscall.Signal, bool) {
+	return 0, false
+}
+
+// StartTime is not supported on Windows
+func StartTime(pid int) (uint64, error) {
+	return 0, errors.New("process start times are not supported on windows")
+}
+
+// StartDetached is not supported on Windows
+func StartDetached(executable string, args []string, output *os.File) (int, error) {
+	return 0, errors.New("detached processes are not supported on windows")
+}
@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"container/heap"
+
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// delayedTasks is a min-heap of tasks ordered by their scheduled time,
+// so the task with the earliest deadline is always at the front
+type delayedTasks []*task.Task
+
+func (d delayedTasks) Len() int { return len(d) }
+
+func (d delayedTasks) Less(i, j int) bool {
+	return d[i].ScheduledAt.Before(*d[j].ScheduledAt)
+}
+
+func (d delayedTasks) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+
+func (d *delayedTasks) Push(x interface{}) {
+	*d = append(*d, x.(*task.Task))
+}
+
+func (d *delayedTasks) Pop() interface{} {
+	old := *d
+	n := len(old)
+	t := old[n-1]
+	*d = old[:n-1]
+	return t
+}
+
+// peek returns the task with the earliest deadline without removing it
+func (d delayedTasks) peek() *task.Task {
+	if len(d) == 0 {
+		return nil
+	}
+
+	return d[0]
+}
+
+// remove deletes the task with the given ID from the heap
+func (d *delayedTasks) remove(id string) bool {
+	for i, t := range *d {
+		if t.ID == id {
+			heap.Remove(d, i)
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,132 @@
+package queue
+
+import (
+	"container/heap"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// delayedTask returns a task scheduled offset after base, named after the offset
+func delayedTask(base time.Time, offset time.Duration) *task.Task {
+	t := task.NewTask("true", task.PriorityNormal)
+	t.ID = offset.String()
+	at := base.Add(offset)
+	t.ScheduledAt = &at
+	return t
+}
+
+func TestDelayedTasksOrder(t *testing.T) {
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		offsets []time.Duration
+		remove  string
+		want    []string
+	}{
+		{
+			name:    "already ordered",
+			offsets: []time.Duration{time.Second, time.Minute, time.Hour},
+			want:    []string{"1s", "1m0s", "1h0m0s"},
+		},
+		{
+			name:    "reversed",
+			offsets: []time.Duration{time.Hour, time.Minute, time.Second},
+			want:    []string{"1s", "1m0s", "1h0m0s"},
+		},
+		{
+			name:    "mixed",
+			offsets: []time.Duration{5 * time.Minute, time.Second, 2 * time.Hour, 30 * time.Second, time.Minute},
+			want:    []string{"1s", "30s", "1m0s", "5m0s", "2h0m0s"},
+		},
+		{
+			name:    "remove the front",
+			offsets: []time.Duration{time.Minute, time.Second, time.Hour},
+			remove:  "1s",
+			want:    []string{"1m0s", "1h0m0s"},
+		},
+		{
+			name:    "remove from the middle",
+			offsets: []time.Duration{5 * time.Minute, time.Second, 2 * time.Hour, 30 * time.Second},
+			remove:  "5m0s",
+			want:    []string{"1s", "30s", "2h0m0s"},
+		},
+		{
+			name:    "remove unknown",
+			offsets: []time.Duration{time.Minute},
+			remove:  "missing",
+			want:    []string{"1m0s"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := make(delayedTasks, 0)
+			for _, offset := range tt.offsets {
+				heap.Push(&d, delayedTask(base, offset))
+			}
+
+			if tt.remove != "" {
+				removed := d.remove(tt.remove)
+				if removed != (tt.remove != "missing") {
+					t.Errorf("remove(%q) = %v", tt.remove, removed)
+				}
+			}
+
+			if front := d.peek(); front == nil || front.ID != tt.want[0] {
+				t.Errorf("peek() = %v, want %s", front, tt.want[0])
+			}
+
+			got := make([]string, 0, len(d))
+			for d.Len() > 0 {
+				got = append(got, heap.Pop(&d).(*task.Task).ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+			if d.peek() != nil {
+				t.Errorf("peek() of an empty heap = %v, want nil", d.peek())
+			}
+		})
+	}
+}
+
+func TestQueuePromote(t *testing.T) {
+	now := time.Now()
+	q := NewQueue("default")
+
+	for _, offset := range []time.Duration{time.Hour, time.Minute, 2 * time.Minute} {
+		if err := q.Add(delayedTask(now, offset)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	due := task.NewTask("true", task.PriorityLow)
+	q.Add(due)
+
+	if got := q.Peek(); got != due {
+		t.Fatalf("Peek() = %v, want the task that is due", got)
+	}
+	if deadline, ok := q.NextDeadline(); !ok || !deadline.Equal(now.Add(time.Minute)) {
+		t.Errorf("NextDeadline() = %v, %v, want %v", deadline, ok, now.Add(time.Minute))
+	}
+
+	if released := q.Promote(now.Add(2 * time.Minute)); released != 2 {
+		t.Errorf("Promote() = %d, want 2", released)
+	}
+	if deadline, ok := q.NextDeadline(); !ok || !deadline.Equal(now.Add(time.Hour)) {
+		t.Errorf("NextDeadline() = %v, %v, want %v", deadline, ok, now.Add(time.Hour))
+	}
+	if got := q.Len(); got != 4 {
+		t.Errorf("Len() = %d, want 4", got)
+	}
+
+	if released := q.Promote(now.Add(2 * time.Hour)); released != 1 {
+		t.Errorf("Promote() = %d, want 1", released)
+	}
+	if _, ok := q.NextDeadline(); ok {
+		t.Error("NextDeadline() reports a deadline with no delayed tasks")
+	}
+}
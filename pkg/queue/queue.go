@@ -1,31 +1,55 @@
 package queue
 
 import (
+	"container/heap"
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/Can/sysrow/pkg/task"
 )
 
-// Queue represents a task queue
+// Queue represents a named task queue. Tasks scheduled for the future are
+// kept aside until they are due and only then become available through GetNext.
 type Queue struct {
-	mutex sync.Mutex
-	tasks []*task.Task
+	mutex   sync.Mutex
+	name    string
+	tasks   []*task.Task
+	delayed delayedTasks
+
+	// limit is the number of tasks of the queue that may run at the same
+	// time, 0 for no limit other than the worker pool
+	limit   int
+	running int
+	paused  bool
 }
 
-// NewQueue creates a new task queue
-func NewQueue() *Queue {
+// NewQueue creates a new empty task queue
+func NewQueue(name string) *Queue {
 	return &Queue{
-		tasks: make([]*task.Task, 0),
+		name:    name,
+		tasks:   make([]*task.Task, 0),
+		delayed: make(delayedTasks, 0),
 	}
 }
 
+// Name returns the name of the queue
+func (q *Queue) Name() string {
+	return q.name
+}
+
 // Add adds a task to the queue
 func (q *Queue) Add(t *task.Task) error {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
+	// Tasks that are not due yet wait in the delayed heap
+	if t.ScheduledAt != nil && t.ScheduledAt.After(time.Now()) {
+		heap.Push(&q.delayed, t)
+		return nil
+	}
+
 	// Add the task to the queue
 	q.tasks = append(q.tasks, t)
 
@@ -35,17 +59,53 @@ func (q *Queue) Add(t *task.Task) error {
 	return nil
 }
 
+// Promote moves delayed tasks that are due at the given time into the queue
+// and returns how many were released
+func (q *Queue) Promote(now time.Time) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	released := 0
+	for next := q.delayed.peek(); next != nil && !next.ScheduledAt.After(now); next = q.delayed.peek() {
+		q.tasks = append(q.tasks, heap.Pop(&q.delayed).(*task.Task))
+		released++
+	}
+
+	if released > 0 {
+		q.sortByPriority()
+	}
+
+	return released
+}
+
+// NextDeadline returns the scheduled time of the earliest delayed task
+func (q *Queue) NextDeadline() (time.Time, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	next := q.delayed.peek()
+	if next == nil {
+		return time.Time{}, false
+	}
+
+	return *next.ScheduledAt, true
+}
+
+// before reports whether task a should run before task b
+func before(a, b *task.Task) bool {
+	pa, pb := a.Priority.Rank(), b.Priority.Rank()
+	if pa != pb {
+		return pa > pb
+	}
+
+	// Tasks with the same priority run in the order they were created
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
 // sortByPriority sorts the queue by task priority
 func (q *Queue) sortByPriority() {
 	sort.Slice(q.tasks, func(i, j int) bool {
-		// Define priority order: high > normal > low
-		priorityOrder := map[task.TaskPriority]int{
-			task.PriorityHigh:   3,
-			task.PriorityNormal: 2,
-			task.PriorityLow:    1,
-		}
-
-		return priorityOrder[q.tasks[i].Priority] > priorityOrder[q.tasks[j].Priority]
+		return before(q.tasks[i], q.tasks[j])
 	})
 }
 
@@ -67,6 +127,18 @@ func (q *Queue) GetNext() *task.Task {
 	return nextTask
 }
 
+// Peek returns the next task in the queue without removing it
+func (q *Queue) Peek() *task.Task {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.tasks) == 0 {
+		return nil
+	}
+
+	return q.tasks[0]
+}
+
 // Remove removes a task from the queue by ID
 func (q *Queue) Remove(id string) error {
 	q.mutex.Lock()
@@ -80,39 +152,81 @@ func (q *Queue) Remove(id string) error {
 		}
 	}
 
+	if q.delayed.remove(id) {
+		return nil
+	}
+
 	return fmt.Errorf("task with ID %s not found in queue", id)
 }
 
-// List returns all tasks in the queue
+// List returns all tasks in the queue, ready tasks first followed by
+// delayed tasks in no particular order
 func (q *Queue) List() []*task.Task {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
 	// Return a copy of the tasks slice to avoid race conditions
-	tasksCopy := make([]*task.Task, len(q.tasks))
-	copy(tasksCopy, q.tasks)
+	tasksCopy := make([]*task.Task, 0, len(q.tasks)+len(q.delayed))
+	tasksCopy = append(tasksCopy, q.tasks...)
+	tasksCopy = append(tasksCopy, q.delayed...)
 
 	return tasksCopy
 }
 
-// LoadQueue loads the queue from disk
-func LoadQueue() (*Queue, error) {
-	queue := NewQueue()
+// Limit returns how many tasks of the queue may run at the same time, 0 for no limit
+func (q *Queue) Limit() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
 
-	// Load all pending tasks
-	tasks, err := task.ListTasks()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list tasks: %w", err)
-	}
+	return q.limit
+}
 
-	// Add pending tasks to the queue
-	for _, t := range tasks {
-		if t.Status == task.StatusPending {
-			queue.Add(t)
-		}
+// Running returns how many tasks of the queue the daemon is running
+func (q *Queue) Running() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.running
+}
+
+// Paused reports whether the queue is paused
+func (q *Queue) Paused() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.paused
+}
+
+// Len returns the number of ready and delayed tasks in the queue
+func (q *Queue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.tasks) + len(q.delayed)
+}
+
+// runnable returns the next task of the queue if the queue may start a task
+// right now
+func (q *Queue) runnable() *task.Task {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.paused || len(q.tasks) == 0 || (q.limit > 0 && q.running >= q.limit) {
+		return nil
 	}
 
-	return queue, nil
+	return q.tasks[0]
+}
+
+// replace replaces the queue contents with the given tasks
+func (q *Queue) replace(pending []*task.Task, delayed delayedTasks) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	heap.Init(&delayed)
+	q.tasks = pending
+	q.delayed = delayed
+	q.sortByPriority()
 }
 
 // SaveQueue saves the queue state to disk
@@ -0,0 +1,229 @@
+package queue
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// DefaultName is the queue of tasks that were queued without a queue name
+const DefaultName = "default"
+
+// namePattern are the queue names users may choose
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidateName checks that a queue name is usable
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid queue name %q (use up to 64 letters, digits, '.', '-' or '_')", name)
+	}
+
+	return nil
+}
+
+// NameOf returns the name of the queue a task belongs to
+func NameOf(t *task.Task) string {
+	if t.Queue == "" {
+		return DefaultName
+	}
+
+	return t.Queue
+}
+
+// Set holds one queue per queue name. Every queue has its own concurrency
+// limit and can be paused on its own, the worker pool is shared by all.
+type Set struct {
+	mutex   sync.Mutex
+	dataDir string
+	store   task.Store
+	queues  map[string]*Queue
+}
+
+// NewSet creates an empty set of queues. limits maps queue names to the
+// number of their tasks that may run at the same time.
+func NewSet(dataDir string, store task.Store, limits map[string]int) *Set {
+	s := &Set{
+		dataDir: dataDir,
+		store:   store,
+		queues:  make(map[string]*Queue),
+	}
+
+	for name, limit := range limits {
+		s.get(name).limit = limit
+	}
+
+	return s
+}
+
+// LoadQueue rebuilds one queue per queue name from the pending tasks in the store
+func LoadQueue(dataDir string, store task.Store, limits map[string]int) (*Set, error) {
+	s := NewSet(dataDir, store, limits)
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// get returns the queue with the given name, creating it if needed. The
+// caller must hold the mutex or own the set exclusively.
+func (s *Set) get(name string) *Queue {
+	q, ok := s.queues[name]
+	if !ok {
+		q = NewQueue(name)
+		s.queues[name] = q
+	}
+
+	return q
+}
+
+// Get returns the queue with the given name, creating it if needed
+func (s *Set) Get(name string) *Queue {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.get(name)
+}
+
+// Queues returns all queues ordered by name
+func (s *Set) Queues() []*Queue {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	queues := make([]*Queue, 0, len(s.queues))
+	for _, q := range s.queues {
+		queues = append(queues, q)
+	}
+	sort.Slice(queues, func(i, j int) bool {
+		return queues[i].name < queues[j].name
+	})
+
+	return queues
+}
+
+// Add adds a task to its queue
+func (s *Set) Add(t *task.Task) error {
+	return s.Get(NameOf(t)).Add(t)
+}
+
+// Promote moves delayed tasks that are due at the given time into their
+// queues and returns how many were released
+func (s *Set) Promote(now time.Time) int {
+	released := 0
+	for _, q := range s.Queues() {
+		released += q.Promote(now)
+	}
+
+	return released
+}
+
+// NextDeadline returns the scheduled time of the earliest delayed task of all queues
+func (s *Set) NextDeadline() (time.Time, bool) {
+	var earliest time.Time
+	found := false
+	for _, q := range s.Queues() {
+		if deadline, ok := q.NextDeadline(); ok && (!found || deadline.Before(earliest)) {
+			earliest = deadline
+			found = true
+		}
+	}
+
+	return earliest, found
+}
+
+// Next returns the most urgent task of all queues that are neither paused
+// nor running as many tasks as they may, without removing it
+func (s *Set) Next() *task.Task {
+	var next *task.Task
+	for _, q := range s.Queues() {
+		if head := q.runnable(); head != nil && (next == nil || before(head, next)) {
+			next = head
+		}
+	}
+
+	return next
+}
+
+// Start removes a task from its queue and counts it as running until Done is called
+func (s *Set) Start(t *task.Task) {
+	q := s.Get(NameOf(t))
+	q.Remove(t.ID)
+
+	q.mutex.Lock()
+	q.running++
+	q.mutex.Unlock()
+}
+
+// Done counts a task passed to Start as no longer running
+func (s *Set) Done(t *task.Task) {
+	q := s.Get(NameOf(t))
+
+	q.mutex.Lock()
+	if q.running > 0 {
+		q.running--
+	}
+	q.mutex.Unlock()
+}
+
+// Reload replaces the contents of every queue with the pending tasks
+// currently in the store and reads which queues are paused
+func (s *Set) Reload() error {
+	state, err := LoadState(s.dataDir)
+	if err != nil {
+		return err
+	}
+
+	// Load the pending tasks
+	tasks, err := task.FindTasks(s.store, task.Filter{Statuses: []task.TaskStatus{task.StatusPending}})
+	if err != nil {
+		return fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	// Collect pending tasks by queue. Group tasks are run through their
+	// group and manual tasks by `sysrow run`, neither is picked up from a queue.
+	now := time.Now()
+	pending := make(map[string][]*task.Task)
+	delayed := make(map[string]delayedTasks)
+	for _, t := range tasks {
+		if t.GroupID != nil || t.Manual {
+			continue
+		}
+
+		name := NameOf(t)
+		if t.ScheduledAt != nil && t.ScheduledAt.After(now) {
+			delayed[name] = append(delayed[name], t)
+		} else {
+			pending[name] = append(pending[name], t)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for name := range pending {
+		s.get(name)
+	}
+	for name := range delayed {
+		s.get(name)
+	}
+	for name := range state.Paused {
+		s.get(name)
+	}
+
+	// Queues that have no tasks left are kept, they may still run some
+	for name, q := range s.queues {
+		q.replace(pending[name], delayed[name])
+
+		_, paused := state.Paused[name]
+		q.mutex.Lock()
+		q.paused = paused
+		q.mutex.Unlock()
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// mapStore is a minimal Store for tests
+type mapStore map[string]*task.Task
+
+func (s mapStore) SaveTask(t *task.Task) error {
+	s[t.ID] = t
+	return nil
+}
+
+func (s mapStore) LoadTask(id string) (*task.Task, error) {
+	if t, ok := s[id]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("%w: %s", task.ErrNotFound, id)
+}
+
+func (s mapStore) ListTasks() ([]*task.Task, error) {
+	tasks := make([]*task.Task, 0, len(s))
+	for _, t := range s {
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func (s mapStore) DeleteTask(id string) error {
+	delete(s, id)
+	return nil
+}
+
+func TestSetNext(t *testing.T) {
+	type queued struct {
+		id       string
+		queue    string
+		priority task.TaskPriority
+	}
+
+	tests := []struct {
+		name   string
+		tasks  []queued
+		limits map[string]int
+		// running is the number of running tasks per queue
+		running map[string]int
+		paused  []string
+		want    string
+	}{
+		{
+			name: "highest priority of all queues",
+			tasks: []queued{
+				{"a1", "a", task.PriorityNormal},
+				{"b1", "b", task.PriorityHigh},
+				{"d1", "", task.PriorityLow},
+			},
+			want: "b1",
+		},
+		{
+			name: "same priority runs in creation order",
+			tasks: []queued{
+				{"a1", "a", task.PriorityNormal},
+				{"b1", "b", task.PriorityNormal},
+			},
+			want: "a1",
+		},
+		{
+			name: "queue at its limit is skipped",
+			tasks: []queued{
+				{"a1", "a", task.PriorityHigh},
+				{"b1", "b", task.PriorityLow},
+			},
+			limits:  map[string]int{"a": 2},
+			running: map[string]int{"a": 2},
+			want:    "b1",
+		},
+		{
+			name: "queue below its limit",
+			tasks: []queued{
+				{"a1", "a", task.PriorityHigh},
+				{"b1", "b", task.PriorityLow},
+			},
+			limits:  map[string]int{"a": 2},
+			running: map[string]int{"a": 1},
+			want:    "a1",
+		},
+		{
+			name: "no limit",
+			tasks: []queued{
+				{"a1", "a", task.PriorityHigh},
+			},
+			running: map[string]int{"a": 10},
+			want:    "a1",
+		},
+		{
+			name: "paused queue is skipped",
+			tasks: []queued{
+				{"a1", "a", task.PriorityHigh},
+				{"d1", "", task.PriorityLow},
+			},
+			paused: []string{"a"},
+			want:   "d1",
+		},
+		{
+			name: "paused default queue",
+			tasks: []queued{
+				{"d1", "", task.PriorityHigh},
+				{"a1", "a", task.PriorityLow},
+			},
+			paused: []string{DefaultName},
+			want:   "a1",
+		},
+		{
+			name: "nothing runnable",
+			tasks: []queued{
+				{"a1", "a", task.PriorityHigh},
+				{"b1", "b", task.PriorityHigh},
+			},
+			limits:  map[string]int{"b": 1},
+			running: map[string]int{"b": 1},
+			paused:  []string{"a"},
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			store := make(mapStore)
+
+			created := time.Now().Add(-time.Hour)
+			for i, q := range tt.tasks {
+				tk := task.NewTask("true", q.priority)
+				tk.ID = q.id
+				tk.Queue = q.queue
+				tk.CreatedAt = created.Add(time.Duration(i) * time.Second)
+				if err := store.SaveTask(tk); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, name := range tt.paused {
+				if _, err := Pause(dataDir, name); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s, err := LoadQueue(dataDir, store, tt.limits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, running := range tt.running {
+				s.Get(name).running = running
+			}
+
+			got := ""
+			if next := s.Next(); next != nil {
+				got = next.ID
+			}
+			if got != tt.want {
+				t.Errorf("Next() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetStartDone(t *testing.T) {
+	s := NewSet(t.TempDir(), make(mapStore), map[string]int{"a": 1})
+
+	first := task.NewTask("true", task.PriorityNormal)
+	first.Queue = "a"
+	second := task.NewTask("true", task.PriorityNormal)
+	second.Queue = "a"
+	s.Add(first)
+	s.Add(second)
+
+	if next := s.Next(); next != first {
+		t.Fatalf("Next() = %v, want the first task", next)
+	}
+
+	s.Start(first)
+	if next := s.Next(); next != nil {
+		t.Errorf("Next() = %v while the queue is at its limit, want nil", next)
+	}
+	if got := s.Get("a").Running(); got != 1 {
+		t.Errorf("Running() = %d, want 1", got)
+	}
+
+	s.Done(first)
+	if next := s.Next(); next != second {
+		t.Errorf("Next() = %v after Done, want the second task", next)
+	}
+
+	// Done never counts below zero
+	s.Done(first)
+	if got := s.Get("a").Running(); got != 0 {
+		t.Errorf("Running() = %d, want 0", got)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	dataDir := t.TempDir()
+
+	steps := []struct {
+		pause bool
+		want  bool
+	}{
+		{pause: true, want: true},
+		{pause: true, want: false},
+		{pause: false, want: true},
+		{pause: false, want: false},
+	}
+
+	for i, step := range steps {
+		change := Resume
+		if step.pause {
+			change = Pause
+		}
+
+		changed, err := change(dataDir, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != step.want {
+			t.Errorf("step %d: changed = %v, want %v", i, changed, step.want)
+		}
+
+		state, err := LoadState(dataDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, paused := state.Paused["a"]; paused != step.pause {
+			t.Errorf("step %d: paused = %v, want %v", i, paused, step.pause)
+		}
+	}
+}
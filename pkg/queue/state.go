@@ -0,0 +1,108 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Can/sysrow/pkg/fsutil"
+	"github.com/Can/sysrow/pkg/lock"
+	"github.com/Can/sysrow/pkg/schema"
+)
+
+// State is the state of the queues that is changed from the command line
+// and read by the daemon, stored in queues.json
+type State struct {
+	// Paused maps the name of every paused queue to when it was paused
+	Paused map[string]time.Time `json:"paused"`
+	// SchemaVersion is the schema version the record was written with
+	SchemaVersion int `json:"schema_version"`
+}
+
+// LoadState reads the state of the queues, no file means no queue is paused
+func LoadState(dataDir string) (*State, error) {
+	state := &State{Paused: make(map[string]time.Time)}
+
+	data, err := os.ReadFile(statePath(dataDir))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal queue state: %w", err)
+	}
+
+	if err := schema.CheckRecord("queue state", filepath.Base(statePath(dataDir)), state.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	if state.Paused == nil {
+		state.Paused = make(map[string]time.Time)
+	}
+
+	return state, nil
+}
+
+// Pause stops the daemon from starting tasks of the queue. Tasks that are
+// running keep running. It returns false if the queue was paused already.
+func Pause(dataDir, name string) (bool, error) {
+	return updateState(dataDir, func(state *State) bool {
+		if _, ok := state.Paused[name]; ok {
+			return false
+		}
+		state.Paused[name] = time.Now()
+		return true
+	})
+}
+
+// Resume lets the daemon start tasks of a paused queue again. It returns
+// false if the queue was not paused.
+func Resume(dataDir, name string) (bool, error) {
+	return updateState(dataDir, func(state *State) bool {
+		if _, ok := state.Paused[name]; !ok {
+			return false
+		}
+		delete(state.Paused, name)
+		return true
+	})
+}
+
+// updateState applies change to the state and saves it if change reports a change
+func updateState(dataDir string, change func(*State) bool) (bool, error) {
+	l, err := lock.DataDir(dataDir)
+	if err != nil {
+		return false, err
+	}
+	defer l.Release()
+
+	state, err := LoadState(dataDir)
+	if err != nil {
+		return false, err
+	}
+
+	if !change(state) {
+		return false, nil
+	}
+
+	state.SchemaVersion = schema.Version
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal queue state: %w", err)
+	}
+
+	if err := fsutil.WriteFile(statePath(dataDir), data, 0644); err != nil {
+		return false, fmt.Errorf("failed to write queue state: %w", err)
+	}
+
+	return true, nil
+}
+
+// statePath returns the path of the queue state file
+func statePath(dataDir string) string {
+	return filepath.Join(dataDir, "queues.json")
+}
@@ -0,0 +1,208 @@
+package recovery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Can/sysrow/pkg/fsutil"
+	"github.com/Can/sysrow/pkg/logger"
+	"github.com/Can/sysrow/pkg/proc"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// startupGrace is how long a running task may go without a recorded PID.
+// The runner saves the task as running just before it starts the process.
+const startupGrace = time.Minute
+
+// Report describes the outcome of a reconciliation run
+type Report struct {
+	// Checked is the number of tasks that were recorded as running
+	Checked int
+	// Lost are the tasks that were marked as lost
+	Lost []*task.Task
+	// Reasons explains, by task ID, why a task was marked as lost
+	Reasons map[string]string
+}
+
+// Corrupt describes a quarantined metadata file
+type Corrupt struct {
+	Path    string
+	MovedTo string
+	Reason  string
+}
+
+// metadataDirs are the directories of the data directory that hold JSON records
+var metadataDirs = []string{"tasks", "groups", "runs", "recurring"}
+
+// Reconcile checks every task recorded as running against the process table
+// and marks tasks whose process no longer exists, or whose PID now belongs to
+// a different process, as lost. It only reads the running tasks and is cheap
+// enough to run before every command.
+func Reconcile(dataDir string) (*Report, error) {
+	report := &Report{
+		Lost:    make([]*task.Task, 0),
+		Reasons: make(map[string]string),
+	}
+
+	tasks, err := task.FindTasks(task.DefaultStore(), task.Filter{Statuses: []task.TaskStatus{task.StatusRunning}})
+	if err != nil {
+		return report, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	log := logger.NewLogger(dataDir)
+
+	for _, t := range tasks {
+		report.Checked++
+
+		// An executor holding the claim is alive and saves the outcome itself
+		if task.Claimed(t.ID) || lostReason(t) == "" {
+			continue
+		}
+
+		lost, reason, err := markLost(t.ID)
+		if err != nil {
+			return report, err
+		}
+		if lost == nil {
+			continue
+		}
+
+		log.LogError(lost.ID, "Task marked as lost: "+reason)
+		report.Lost = append(report.Lost, lost)
+		report.Reasons[lost.ID] = reason
+	}
+
+	return report, nil
+}
+
+// markLost takes the claim of a task so that no executor can finish it in
+// the meantime, checks it again and saves it as lost. A nil task is returned
+// when the task turned out not to be lost.
+func markLost(id string) (*task.Task, string, error) {
+	t, claim, err := task.Hold(task.DefaultStore(), id)
+	if err != nil {
+		if errors.Is(err, task.ErrClaimed) || errors.Is(err, task.ErrNotFound) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("failed to claim task %s: %w", id, err)
+	}
+	defer claim.Release()
+
+	if t.Status != task.StatusRunning {
+		return nil, "", nil
+	}
+	reason := lostReason(t)
+	if reason == "" {
+		return nil, "", nil
+	}
+
+	now := time.Now()
+	t.Status = task.StatusLost
+	t.FinishedAt = &now
+	t.PID = nil
+	t.PIDStart = nil
+	if err := t.Save(); err != nil {
+		return nil, "", fmt.Errorf("failed to save task %s: %w", t.ID, err)
+	}
+
+	return t, reason, nil
+}
+
+// lostReason returns why a running task that no executor has claimed should
+// be considered lost, or an empty string if its process is still alive
+func lostReason(t *task.Task) string {
+	if t.PID == nil {
+		if t.StartedAt != nil && time.Since(*t.StartedAt) < startupGrace {
+			return ""
+		}
+		return "no process ID was recorded"
+	}
+
+	pid := *t.PID
+	if !proc.Alive(pid) {
+		return fmt.Sprintf("process %d no longer exists", pid)
+	}
+
+	if t.PIDStart != nil {
+		startTime, err := proc.StartTime(pid)
+		if err == nil && startTime != *t.PIDStart {
+			return fmt.Sprintf("process ID %d has been reused by another process", pid)
+		}
+	}
+
+	return ""
+}
+
+// Quarantine moves every metadata file that is not a valid JSON object to
+// the corrupt directory, so it is reported once instead of being skipped on
+// every load. Temporary files left behind by an interrupted write are removed.
+// It reads every record and is run by `sysrow doctor` and on daemon startup.
+func Quarantine(dataDir string) ([]Corrupt, error) {
+	corrupt := make([]Corrupt, 0)
+	for _, name := range metadataDirs {
+		dir := filepath.Join(dataDir, name)
+
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return corrupt, fmt.Errorf("failed to read %s directory: %w", name, err)
+		}
+
+		for _, file := range files {
+			path := filepath.Join(dir, file.Name())
+
+			// A temporary file may still be in use by a write in progress
+			if fsutil.IsTemp(file.Name()) {
+				if info, err := file.Info(); err == nil && time.Since(info.ModTime()) > startupGrace {
+					os.Remove(path)
+				}
+				continue
+			}
+
+			if filepath.Ext(file.Name()) != ".json" {
+				continue
+			}
+
+			reason := checkJSON(path)
+			if reason == "" {
+				continue
+			}
+
+			movedTo, err := fsutil.Quarantine(dataDir, path)
+			if err != nil {
+				return corrupt, fmt.Errorf("failed to quarantine %s: %w", path, err)
+			}
+
+			corrupt = append(corrupt, Corrupt{Path: path, MovedTo: movedTo, Reason: reason})
+		}
+	}
+
+	return corrupt, nil
+}
+
+// checkJSON returns why a metadata file is corrupt, or an empty string if
+// it holds a JSON object
+func checkJSON(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// Deleted in the meantime or unreadable, which is not corruption
+		return ""
+	}
+
+	if len(data) == 0 {
+		return "file is empty"
+	}
+
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err.Error()
+	}
+
+	return ""
+}
@@ -0,0 +1,370 @@
+package recurring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Can/sysrow/pkg/cron"
+	"github.com/Can/sysrow/pkg/fsutil"
+	"github.com/Can/sysrow/pkg/lock"
+	"github.com/Can/sysrow/pkg/schema"
+	"github.com/Can/sysrow/pkg/task"
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned for a recurring task that does not exist
+var ErrNotFound = errors.New("not found")
+
+// Recurring is a task definition that spawns a fresh task on every occurrence
+type Recurring struct {
+	ID         string            `json:"id"`
+	Command    string            `json:"command"`
+	Priority   task.TaskPriority `json:"priority"`
+	Cron       string            `json:"cron,omitempty"`
+	Interval   time.Duration     `json:"interval,omitempty"`
+	Timeout    time.Duration     `json:"timeout,omitempty"`
+	Retry      *task.RetryPolicy `json:"retry,omitempty"`
+	Queue      string            `json:"queue,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Paused     bool              `json:"paused"`
+	CreatedAt  time.Time         `json:"created_at"`
+	NextRunAt  *time.Time        `json:"next_run_at,omitempty"`
+	LastRunAt  *time.Time        `json:"last_run_at,omitempty"`
+	LastTaskID *string           `json:"last_task_id,omitempty"`
+	RunCount   int               `json:"run_count"`
+	// SchemaVersion is the schema version the record was written with
+	SchemaVersion int `json:"schema_version"`
+}
+
+// Manager manages recurring task definitions
+type Manager struct {
+	mutex        sync.Mutex
+	dataDir      string
+	recurringDir string
+}
+
+// NewManager creates a new recurring task manager
+func NewManager(dataDir string) *Manager {
+	return &Manager{
+		dataDir:      dataDir,
+		recurringDir: filepath.Join(dataDir, "recurring"),
+	}
+}
+
+// Schedule returns a human readable description of when the definition runs
+func (r *Recurring) Schedule() string {
+	if r.Cron != "" {
+		return r.Cron
+	}
+
+	return "every " + r.Interval.String()
+}
+
+// next returns the first occurrence after the given time
+func (r *Recurring) next(after time.Time) (time.Time, error) {
+	if r.Cron == "" {
+		return after.Add(r.Interval), nil
+	}
+
+	schedule, err := cron.Parse(r.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := schedule.Next(after)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never matches", r.Cron)
+	}
+
+	return next, nil
+}
+
+// Create creates a recurring definition driven either by a cron expression or by a
+// fixed interval. Every spawned task copies the command, priority, timeout,
+// retry policy, queue and name of the template.
+func (m *Manager) Create(template *task.Task, cronExpr string, interval time.Duration) (*Recurring, error) {
+	if (cronExpr == "") == (interval == 0) {
+		return nil, fmt.Errorf("exactly one of a cron expression or an interval is required")
+	}
+
+	if interval < 0 {
+		return nil, fmt.Errorf("interval must be positive")
+	}
+
+	r := &Recurring{
+		ID:        uuid.New().String(),
+		Command:   template.Command,
+		Priority:  template.Priority,
+		Cron:      cronExpr,
+		Interval:  interval,
+		Timeout:   template.Timeout,
+		Retry:     template.Retry,
+		Queue:     template.Queue,
+		Name:      template.Name,
+		CreatedAt: time.Now(),
+	}
+
+	next, err := r.next(r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	r.NextRunAt = &next
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	l, err := lock.DataDir(m.dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	if err := m.save(r); err != nil {
+		return nil, fmt.Errorf("failed to save recurring task: %w", err)
+	}
+
+	return r, nil
+}
+
+// Get returns a recurring definition by its ID
+func (m *Manager) Get(id string) (*Recurring, error) {
+	return m.load(id)
+}
+
+// List returns all recurring definitions ordered by creation time
+func (m *Manager) List() ([]*Recurring, error) {
+	if err := os.MkdirAll(m.recurringDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recurring directory: %w", err)
+	}
+
+	files, err := os.ReadDir(m.recurringDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recurring directory: %w", err)
+	}
+
+	list := make([]*Recurring, 0, len(files))
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		id := file.Name()[:len(file.Name())-5] // Remove .json extension
+
+		r, err := m.load(id)
+		if err != nil {
+			// Log the error but continue loading other definitions
+			fmt.Fprintf(os.Stderr, "Error loading recurring task %s: %v\n", id, err)
+			continue
+		}
+
+		list = append(list, r)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+
+	return list, nil
+}
+
+// Pause stops a definition from spawning new tasks
+func (m *Manager) Pause(id string) (*Recurring, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	l, err := lock.DataDir(m.dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	r, err := m.load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Paused = true
+	if err := m.save(r); err != nil {
+		return nil, fmt.Errorf("failed to save recurring task: %w", err)
+	}
+
+	return r, nil
+}
+
+// Resume re-enables a paused definition. Occurrences missed while paused are
+// skipped and the next run is computed from now.
+func (m *Manager) Resume(id string) (*Recurring, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	l, err := lock.DataDir(m.dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	r, err := m.load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := r.next(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	r.Paused = false
+	r.NextRunAt = &next
+	if err := m.save(r); err != nil {
+		return nil, fmt.Errorf("failed to save recurring task: %w", err)
+	}
+
+	return r, nil
+}
+
+// Delete removes a definition. Tasks it already spawned are kept.
+func (m *Manager) Delete(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	l, err := lock.DataDir(m.dataDir)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	if err := os.Remove(m.path(id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("recurring task %s %w", id, ErrNotFound)
+		}
+		return fmt.Errorf("failed to delete recurring task file: %w", err)
+	}
+
+	return nil
+}
+
+// SpawnDue creates a child task for every active definition that is due at
+// the given time. If several occurrences were missed (e.g. the daemon was
+// stopped) only one task is spawned and the schedule continues from now.
+func (m *Manager) SpawnDue(now time.Time) ([]*task.Task, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	l, err := lock.DataDir(m.dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	list, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	spawned := make([]*task.Task, 0)
+	for _, r := range list {
+		if r.Paused || r.NextRunAt == nil || r.NextRunAt.After(now) {
+			continue
+		}
+
+		t := task.NewTask(r.Command, r.Priority)
+		t.RecurringID = &r.ID
+		t.Timeout = r.Timeout
+		t.Retry = r.Retry
+		t.Queue = r.Queue
+		t.Name = r.Name
+		if err := t.Save(); err != nil {
+			return spawned, fmt.Errorf("failed to save task for recurring task %s: %w", r.ID, err)
+		}
+		spawned = append(spawned, t)
+
+		next, err := r.next(now)
+		if err != nil {
+			return spawned, err
+		}
+
+		runAt := now
+		r.LastRunAt = &runAt
+		r.LastTaskID = &t.ID
+		r.NextRunAt = &next
+		r.RunCount++
+		if err := m.save(r); err != nil {
+			return spawned, fmt.Errorf("failed to save recurring task: %w", err)
+		}
+	}
+
+	return spawned, nil
+}
+
+// NextDeadline returns the earliest next run time of all active definitions
+func (m *Manager) NextDeadline() (time.Time, bool) {
+	list, err := m.List()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var earliest time.Time
+	found := false
+	for _, r := range list {
+		if r.Paused || r.NextRunAt == nil {
+			continue
+		}
+		if !found || r.NextRunAt.Before(earliest) {
+			earliest = *r.NextRunAt
+			found = true
+		}
+	}
+
+	return earliest, found
+}
+
+// path returns the file path of a definition
+func (m *Manager) path(id string) string {
+	return filepath.Join(m.recurringDir, id+".json")
+}
+
+// save writes a definition to disk
+func (m *Manager) save(r *Recurring) error {
+	if err := os.MkdirAll(m.recurringDir, 0755); err != nil {
+		return fmt.Errorf("failed to create recurring directory: %w", err)
+	}
+
+	r.SchemaVersion = schema.Version
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal recurring task: %w", err)
+	}
+
+	if err := fsutil.WriteFile(m.path(r.ID), data, 0644); err != nil {
+		return fmt.Errorf("failed to write recurring task file: %w", err)
+	}
+
+	return nil
+}
+
+// load reads a definition from disk
+func (m *Manager) load(id string) (*Recurring, error) {
+	data, err := os.ReadFile(m.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("recurring task %s %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to read recurring task file: %w", err)
+	}
+
+	var r Recurring
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recurring task: %w", err)
+	}
+	if err := schema.CheckRecord("recurring task", id, r.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
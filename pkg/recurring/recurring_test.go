@@ -0,0 +1,49 @@
+package recurring
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Can/sysrow/pkg/task"
+)
+
+func TestCreateRejectsCronThatNeverMatches(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	_, err := m.Create(task.NewTask("true", task.PriorityNormal), "0 0 30 2 *", 0)
+	if err == nil || !strings.Contains(err.Error(), "never matches") {
+		t.Fatalf("Create() error = %v, want a never matches error", err)
+	}
+
+	list, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Errorf("List() returned %d definitions, want none to be saved", len(list))
+	}
+}
+
+func TestNext(t *testing.T) {
+	after := time.Date(2026, 10, 16, 12, 34, 56, 0, time.UTC)
+
+	tests := []struct {
+		r    Recurring
+		want time.Time
+	}{
+		{Recurring{Interval: 15 * time.Minute}, after.Add(15 * time.Minute)},
+		{Recurring{Cron: "0 * * * *"}, time.Date(2026, 10, 16, 13, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.r.next(after)
+		if err != nil {
+			t.Errorf("next() for %s failed: %v", tt.r.Schedule(), err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("next() for %s = %s, want %s", tt.r.Schedule(), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package retention
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Can/sysrow/pkg/schedule"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// AllStatuses is the max age key that applies to every finished status
+const AllStatuses = "*"
+
+// finishedStatuses are the statuses of tasks that may be deleted
+var finishedStatuses = []task.TaskStatus{
+	task.StatusCompleted,
+	task.StatusFailed,
+	task.StatusCancelled,
+	task.StatusLost,
+	task.StatusTimedOut,
+	task.StatusSkipped,
+}
+
+// Policy decides which finished tasks are deleted. Tasks that may still run
+// are never deleted.
+type Policy struct {
+	// KeepLast keeps the last N runs of every group and the last N tasks of
+	// every recurring task, 0 keeps all of them
+	KeepLast int
+	// MaxAge deletes finished tasks of a status once they finished longer
+	// than the duration ago
+	MaxAge map[task.TaskStatus]time.Duration
+	// MaxLogSize deletes the oldest finished tasks until the logs of all
+	// tasks take at most this many bytes, 0 for no limit
+	MaxLogSize int64
+}
+
+// IsEmpty reports whether the policy deletes nothing
+func (p *Policy) IsEmpty() bool {
+	return p.KeepLast == 0 && len(p.MaxAge) == 0 && p.MaxLogSize == 0
+}
+
+// MaxAges converts status names, or AllStatuses, and durations into max ages.
+// A duration given for a single status wins over the one for all statuses.
+func MaxAges(values map[string]string) (map[task.TaskStatus]time.Duration, error) {
+	ages := make(map[task.TaskStatus]time.Duration)
+
+	if value, ok := values[AllStatuses]; ok {
+		age, err := schedule.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max age: %w", err)
+		}
+		for _, status := range finishedStatuses {
+			ages[status] = age
+		}
+	}
+
+	for name, value := range values {
+		if name == AllStatuses {
+			continue
+		}
+
+		status, err := task.ParseStatus(name)
+		if err != nil {
+			return nil, err
+		}
+		if !status.IsTerminal() {
+			return nil, fmt.Errorf("invalid max age: %s tasks are never deleted", status)
+		}
+
+		age, err := schedule.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max age for %s: %w", status, err)
+		}
+		ages[status] = age
+	}
+
+	return ages, nil
+}
+
+// ParseMaxAge parses a max age option such as 7d, which applies to every
+// finished status, or completed=7d,failed=30d
+func ParseMaxAge(value string) (map[task.TaskStatus]time.Duration, error) {
+	values := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		name, age, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			name, age = AllStatuses, name
+		}
+		values[strings.TrimSpace(name)] = strings.TrimSpace(age)
+	}
+
+	return MaxAges(values)
+}
+
+// sizeUnits are the suffixes understood by ParseSize
+var sizeUnits = map[string]int64{
+	"":  1,
+	"B": 1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+// ParseSize parses a size such as 500M or 2G. The units are powers of 1024.
+func ParseSize(input string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(input))
+	value = strings.TrimSuffix(value, "IB")
+	if len(value) > 1 {
+		value = strings.TrimSuffix(value, "B")
+	}
+
+	i := 0
+	for i < len(value) && value[i] >= '0' && value[i] <= '9' {
+		i++
+	}
+
+	unit, ok := sizeUnits[value[i:]]
+	if i == 0 || !ok {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 500M or 2G)", input)
+	}
+
+	number, err := strconv.ParseInt(value[:i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", input, err)
+	}
+
+	return number * unit, nil
+}
+
+// FormatSize formats a size in bytes for display
+func FormatSize(size int64) string {
+	units := []string{"B", "K", "M", "G", "T"}
+
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d%s", size, units[unit])
+	}
+	return fmt.Sprintf("%.1f%s", value, units[unit])
+}
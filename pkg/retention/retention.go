@@ -0,0 +1,341 @@
+// Package retention deletes finished tasks, their logs and the records of
+// group runs according to a retention policy. It backs `sysrow prune` and
+// the periodic cleanup of the daemon.
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Can/sysrow/pkg/group"
+	"github.com/Can/sysrow/pkg/lock"
+	"github.com/Can/sysrow/pkg/logger"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// Candidate is a task selected for deletion
+type Candidate struct {
+	Task *task.Task
+	// Reason explains which rule of the policy selected the task
+	Reason string
+	// LogSize is the size of all log files of the task in bytes
+	LogSize int64
+}
+
+// Result lists what a prune deleted, or would delete in a dry run
+type Result struct {
+	Tasks []Candidate
+	// Runs are the group runs whose tasks are all deleted
+	Runs []*group.GroupRun
+	// LogSize is the size of the log files of the deleted tasks in bytes
+	LogSize int64
+}
+
+// Pruner applies retention policies to a data directory
+type Pruner struct {
+	dataDir string
+	store   task.Store
+	groups  *group.GroupManager
+}
+
+// NewPruner creates a pruner for the tasks of a data directory
+func NewPruner(dataDir string, store task.Store) *Pruner {
+	return &Pruner{
+		dataDir: dataDir,
+		store:   store,
+		groups:  group.NewGroupManager(dataDir, store),
+	}
+}
+
+// Prune deletes the tasks selected by the policy together with their logs.
+// With dryRun nothing is deleted and the result lists what would be.
+func (p *Pruner) Prune(policy *Policy, now time.Time, dryRun bool) (*Result, error) {
+	// Planning reads every task and log, so the data directory is only
+	// locked while a task is deleted and does not hold up other processes
+	result, err := p.plan(policy, now)
+	if err != nil || dryRun {
+		return result, err
+	}
+
+	planned := result.Tasks
+	result.Tasks = make([]Candidate, 0, len(planned))
+	result.LogSize = 0
+	kept := make(map[string]bool)
+	for _, c := range planned {
+		deleted, err := p.deleteTask(c.Task.ID)
+		if err != nil {
+			return result, err
+		}
+		if !deleted {
+			kept[c.Task.ID] = true
+			continue
+		}
+		result.Tasks = append(result.Tasks, c)
+		result.LogSize += c.LogSize
+	}
+
+	runs := result.Runs
+	result.Runs = make([]*group.GroupRun, 0, len(runs))
+	for _, run := range runs {
+		if containsAny(run.TaskIDs, kept) {
+			continue
+		}
+		if err := p.groups.DeleteRun(run.ID); err != nil {
+			return result, err
+		}
+		result.Runs = append(result.Runs, run)
+	}
+
+	return result, nil
+}
+
+// deleteTask deletes a task and its logs under the data directory lock. It
+// returns false when the task was retried since it was selected and stays.
+func (p *Pruner) deleteTask(id string) (bool, error) {
+	l, err := lock.DataDir(p.dataDir)
+	if err != nil {
+		return false, err
+	}
+	defer l.Release()
+
+	t, err := p.store.LoadTask(id)
+	switch {
+	case errors.Is(err, task.ErrNotFound):
+		// Deleted meanwhile, its logs may still be left
+	case err != nil:
+		return false, err
+	case !t.Status.IsTerminal():
+		return false, nil
+	default:
+		if err := p.store.DeleteTask(id); err != nil {
+			return false, fmt.Errorf("failed to delete task %s: %w", id, err)
+		}
+	}
+
+	files, err := logger.TaskLogFiles(p.dataDir, id)
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to delete log file: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+// containsAny reports whether any of the IDs is in the set
+func containsAny(ids []string, set map[string]bool) bool {
+	for _, id := range ids {
+		if set[id] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// plan selects the tasks and runs to delete
+func (p *Pruner) plan(policy *Policy, now time.Time) (*Result, error) {
+	result := &Result{
+		Tasks: make([]Candidate, 0),
+		Runs:  make([]*group.GroupRun, 0),
+	}
+
+	tasks, err := p.store.ListTasks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	runs, err := p.groups.ListRuns()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*task.Task, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+	}
+
+	// Tasks that unfinished tasks still wait for must stay
+	needed := make(map[string]bool)
+	for _, t := range tasks {
+		if !t.Status.IsTerminal() {
+			for _, id := range t.DependsOn {
+				needed[id] = true
+			}
+		}
+	}
+
+	reasons := make(map[string]string)
+	selectTask := func(t *task.Task, reason string) {
+		if _, ok := reasons[t.ID]; ok || needed[t.ID] || !t.Status.IsTerminal() {
+			return
+		}
+		reasons[t.ID] = reason
+	}
+
+	// Tasks that finished too long ago
+	for _, t := range tasks {
+		if age, ok := policy.MaxAge[t.Status]; ok && now.Sub(finishedAt(t)) > age {
+			selectTask(t, fmt.Sprintf("%s longer than %s ago", t.Status, age))
+		}
+	}
+
+	if policy.KeepLast > 0 {
+		selectOldRuns(runs, byID, policy.KeepLast, selectTask)
+		selectOldRecurring(tasks, policy.KeepLast, selectTask)
+	}
+
+	// Log sizes are needed for the result even without a size limit
+	logSizes, err := p.logSizes()
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, t := range tasks {
+		total += logSizes[t.ID]
+	}
+
+	if policy.MaxLogSize > 0 {
+		for id := range reasons {
+			total -= logSizes[id]
+		}
+
+		// Delete the tasks that finished first until the logs fit
+		oldest := make([]*task.Task, len(tasks))
+		copy(oldest, tasks)
+		sort.Slice(oldest, func(i, j int) bool {
+			return finishedAt(oldest[i]).Before(finishedAt(oldest[j]))
+		})
+		for _, t := range oldest {
+			if total <= policy.MaxLogSize {
+				break
+			}
+			if _, ok := reasons[t.ID]; ok || logSizes[t.ID] == 0 {
+				continue
+			}
+
+			selectTask(t, fmt.Sprintf("logs over %s", FormatSize(policy.MaxLogSize)))
+			if _, ok := reasons[t.ID]; ok {
+				total -= logSizes[t.ID]
+			}
+		}
+	}
+
+	for _, t := range tasks {
+		reason, ok := reasons[t.ID]
+		if !ok {
+			continue
+		}
+		result.Tasks = append(result.Tasks, Candidate{Task: t, Reason: reason, LogSize: logSizes[t.ID]})
+		result.LogSize += logSizes[t.ID]
+	}
+	sort.Slice(result.Tasks, func(i, j int) bool {
+		return finishedAt(result.Tasks[i].Task).Before(finishedAt(result.Tasks[j].Task))
+	})
+
+	// A run goes once none of its tasks are left
+	for _, run := range runs {
+		gone := true
+		for _, id := range run.TaskIDs {
+			if _, ok := byID[id]; ok {
+				if _, deleted := reasons[id]; !deleted {
+					gone = false
+					break
+				}
+			}
+		}
+		if gone {
+			result.Runs = append(result.Runs, run)
+		}
+	}
+
+	return result, nil
+}
+
+// selectOldRuns selects the tasks of all but the last keep finished runs of
+// every group
+func selectOldRuns(runs []*group.GroupRun, tasks map[string]*task.Task, keep int, selectTask func(*task.Task, string)) {
+	// Runs are ordered by group and number, walk them newest first
+	kept := make(map[string]int)
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		if run.Status == group.RunRunning {
+			continue
+		}
+
+		kept[run.GroupID]++
+		if kept[run.GroupID] <= keep {
+			continue
+		}
+
+		for _, id := range run.TaskIDs {
+			if t, ok := tasks[id]; ok {
+				selectTask(t, fmt.Sprintf("run #%d of group %s, keeping the last %d", run.Number, run.GroupName, keep))
+			}
+		}
+	}
+}
+
+// selectOldRecurring selects all but the last keep finished tasks spawned by
+// every recurring task
+func selectOldRecurring(tasks []*task.Task, keep int, selectTask func(*task.Task, string)) {
+	spawned := make(map[string][]*task.Task)
+	for _, t := range tasks {
+		if t.RecurringID != nil && t.Status.IsTerminal() {
+			spawned[*t.RecurringID] = append(spawned[*t.RecurringID], t)
+		}
+	}
+
+	for recurringID, list := range spawned {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		})
+		for _, t := range list[min(keep, len(list)):] {
+			selectTask(t, fmt.Sprintf("spawned by recurring task %s, keeping the last %d", recurringID, keep))
+		}
+	}
+}
+
+// logSizes returns the size of the log files of every task by task ID
+func (p *Pruner) logSizes() (map[string]int64, error) {
+	sizes := make(map[string]int64)
+
+	entries, err := os.ReadDir(filepath.Join(p.dataDir, "logs"))
+	if os.IsNotExist(err) {
+		return sizes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logs directory: %w", err)
+	}
+
+	// Task log files are named <task id>.<stream>.log
+	for _, entry := range entries {
+		id, _, found := strings.Cut(entry.Name(), ".")
+		if !found || entry.IsDir() {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			sizes[id] += info.Size()
+		}
+	}
+
+	return sizes, nil
+}
+
+// finishedAt returns when a task finished, or when it was created for
+// tasks that never ran
+func finishedAt(t *task.Task) time.Time {
+	if t.FinishedAt != nil {
+		return *t.FinishedAt
+	}
+
+	return t.CreatedAt
+}
@@ -0,0 +1,365 @@
+package retention
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Can/sysrow/pkg/group"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// mapStore is a minimal Store for tests
+type mapStore map[string]*task.Task
+
+func (s mapStore) SaveTask(t *task.Task) error {
+	s[t.ID] = t
+	return nil
+}
+
+func (s mapStore) LoadTask(id string) (*task.Task, error) {
+	if t, ok := s[id]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("%w: %s", task.ErrNotFound, id)
+}
+
+func (s mapStore) ListTasks() ([]*task.Task, error) {
+	tasks := make([]*task.Task, 0, len(s))
+	for _, t := range s {
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func (s mapStore) DeleteTask(id string) error {
+	delete(s, id)
+	return nil
+}
+
+// fixture is a task of a test data directory
+type fixture struct {
+	id     string
+	status task.TaskStatus
+	// ago is how long ago the task finished, or was created if it never ran
+	ago       time.Duration
+	dependsOn []string
+	recurring string
+	logSize   int
+}
+
+// run is a group run of a test data directory
+type run struct {
+	number  int
+	status  string
+	taskIDs []string
+}
+
+// setup writes tasks, their logs and the runs of group ci to a data directory
+func setup(t *testing.T, dataDir string, now time.Time, fixtures []fixture, runs []run) mapStore {
+	t.Helper()
+	store := make(mapStore)
+
+	for _, f := range fixtures {
+		tk := task.NewTask("true", task.PriorityNormal)
+		tk.ID = f.id
+		tk.Status = f.status
+		tk.CreatedAt = now.Add(-f.ago)
+		tk.DependsOn = f.dependsOn
+		if f.status.IsTerminal() {
+			finished := now.Add(-f.ago)
+			tk.FinishedAt = &finished
+		}
+		if f.recurring != "" {
+			recurring := f.recurring
+			tk.RecurringID = &recurring
+		}
+		store.SaveTask(tk)
+
+		if f.logSize > 0 {
+			writeFile(t, filepath.Join(dataDir, "logs", f.id+".stdout.log"), strings.Repeat("x", f.logSize))
+		}
+	}
+
+	for _, r := range runs {
+		data, err := json.Marshal(&group.GroupRun{
+			ID:        fmt.Sprintf("run-%d", r.number),
+			GroupID:   "g1",
+			GroupName: "ci",
+			Number:    r.number,
+			Status:    r.status,
+			TaskIDs:   r.taskIDs,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(dataDir, "runs", fmt.Sprintf("run-%d.json", r.number)), string(data))
+	}
+
+	return store
+}
+
+// writeFile writes a file and creates its directory
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		tasks    []fixture
+		runs     []run
+		want     []string
+		wantRuns []int
+	}{
+		{
+			name:   "max age",
+			policy: Policy{MaxAge: map[task.TaskStatus]time.Duration{task.StatusCompleted: day, task.StatusFailed: day}},
+			tasks: []fixture{
+				{id: "old", status: task.StatusCompleted, ago: 3 * day},
+				{id: "older", status: task.StatusFailed, ago: 5 * day},
+				{id: "recent", status: task.StatusCompleted, ago: time.Hour},
+				{id: "pending", status: task.StatusPending, ago: 10 * day},
+				{id: "running", status: task.StatusRunning, ago: 10 * day},
+			},
+			want: []string{"older", "old"},
+		},
+		{
+			name:   "max age of one status",
+			policy: Policy{MaxAge: map[task.TaskStatus]time.Duration{task.StatusCompleted: day}},
+			tasks: []fixture{
+				{id: "completed", status: task.StatusCompleted, ago: 3 * day},
+				{id: "failed", status: task.StatusFailed, ago: 3 * day},
+			},
+			want: []string{"completed"},
+		},
+		{
+			name:   "dependencies of unfinished tasks are kept",
+			policy: Policy{MaxAge: map[task.TaskStatus]time.Duration{task.StatusCompleted: day}},
+			tasks: []fixture{
+				{id: "build", status: task.StatusCompleted, ago: 3 * day},
+				{id: "deploy", status: task.StatusPending, ago: 3 * day, dependsOn: []string{"build"}},
+				{id: "test", status: task.StatusCompleted, ago: 3 * day},
+				{id: "report", status: task.StatusCompleted, ago: 2 * day, dependsOn: []string{"test"}},
+			},
+			want: []string{"test", "report"},
+		},
+		{
+			name:   "keep the last runs",
+			policy: Policy{KeepLast: 1},
+			tasks: []fixture{
+				{id: "r1", status: task.StatusCompleted, ago: 3 * day},
+				{id: "r2", status: task.StatusFailed, ago: 2 * day},
+				{id: "r3", status: task.StatusCompleted, ago: day},
+				{id: "r4", status: task.StatusRunning, ago: time.Hour},
+			},
+			runs: []run{
+				{number: 1, status: group.RunSucceeded, taskIDs: []string{"r1"}},
+				{number: 2, status: group.RunFailed, taskIDs: []string{"r2"}},
+				{number: 3, status: group.RunSucceeded, taskIDs: []string{"r3"}},
+				{number: 4, status: group.RunRunning, taskIDs: []string{"r4"}},
+			},
+			want:     []string{"r1", "r2"},
+			wantRuns: []int{1, 2},
+		},
+		{
+			name:   "run with a kept dependency stays",
+			policy: Policy{KeepLast: 1},
+			tasks: []fixture{
+				{id: "r1a", status: task.StatusCompleted, ago: 3 * day},
+				{id: "r1b", status: task.StatusCompleted, ago: 3 * day},
+				{id: "r2", status: task.StatusCompleted, ago: day},
+				{id: "later", status: task.StatusPending, dependsOn: []string{"r1b"}},
+			},
+			runs: []run{
+				{number: 1, status: group.RunSucceeded, taskIDs: []string{"r1a", "r1b"}},
+				{number: 2, status: group.RunSucceeded, taskIDs: []string{"r2"}},
+			},
+			want: []string{"r1a"},
+		},
+		{
+			name:   "run whose tasks are gone",
+			policy: Policy{KeepLast: 1},
+			tasks: []fixture{
+				{id: "r2", status: task.StatusCompleted, ago: day},
+			},
+			runs: []run{
+				{number: 1, status: group.RunSucceeded, taskIDs: []string{"deleted"}},
+				{number: 2, status: group.RunSucceeded, taskIDs: []string{"r2"}},
+			},
+			want:     []string{},
+			wantRuns: []int{1},
+		},
+		{
+			name:   "keep the last recurring tasks",
+			policy: Policy{KeepLast: 2},
+			tasks: []fixture{
+				{id: "a1", status: task.StatusCompleted, ago: 3 * day, recurring: "a"},
+				{id: "a2", status: task.StatusFailed, ago: 2 * day, recurring: "a"},
+				{id: "a3", status: task.StatusCompleted, ago: day, recurring: "a"},
+				{id: "a4", status: task.StatusPending, recurring: "a"},
+				{id: "b1", status: task.StatusCompleted, ago: 3 * day, recurring: "b"},
+			},
+			want: []string{"a1"},
+		},
+		{
+			name:   "max log size deletes the oldest first",
+			policy: Policy{MaxLogSize: 250},
+			tasks: []fixture{
+				{id: "first", status: task.StatusCompleted, ago: 3 * day, logSize: 100},
+				{id: "second", status: task.StatusCompleted, ago: 2 * day, logSize: 100},
+				{id: "third", status: task.StatusCompleted, ago: day, logSize: 100},
+				{id: "nolog", status: task.StatusCompleted, ago: 4 * day},
+			},
+			want: []string{"first"},
+		},
+		{
+			name:   "max log size skips kept tasks",
+			policy: Policy{MaxLogSize: 150},
+			tasks: []fixture{
+				{id: "needed", status: task.StatusCompleted, ago: 4 * day, logSize: 100},
+				{id: "running", status: task.StatusRunning, ago: 3 * day, logSize: 100, dependsOn: []string{"needed"}},
+				{id: "old", status: task.StatusCompleted, ago: 2 * day, logSize: 100},
+				{id: "new", status: task.StatusCompleted, ago: day, logSize: 100},
+			},
+			want: []string{"old", "new"},
+		},
+		{
+			name:   "max log size counts tasks selected by other rules",
+			policy: Policy{MaxLogSize: 150, MaxAge: map[task.TaskStatus]time.Duration{task.StatusCompleted: 2 * day}},
+			tasks: []fixture{
+				{id: "first", status: task.StatusFailed, ago: 4 * day, logSize: 100},
+				{id: "expired", status: task.StatusCompleted, ago: 3 * day, logSize: 100},
+				{id: "recent", status: task.StatusCompleted, ago: day, logSize: 100},
+			},
+			want: []string{"first", "expired"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			now := time.Now()
+			store := setup(t, dataDir, now, tt.tasks, tt.runs)
+
+			result, err := NewPruner(dataDir, store).Prune(&tt.policy, now, true)
+			if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+
+			got := make([]string, 0, len(result.Tasks))
+			var logSize int64
+			for _, c := range result.Tasks {
+				got = append(got, c.Task.ID)
+				if c.Reason == "" {
+					t.Errorf("task %s selected without a reason", c.Task.ID)
+				}
+				logSize += c.LogSize
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tasks = %v, want %v", got, tt.want)
+			}
+			if result.LogSize != logSize {
+				t.Errorf("LogSize = %d, want %d", result.LogSize, logSize)
+			}
+
+			gotRuns := make([]int, 0, len(result.Runs))
+			for _, r := range result.Runs {
+				gotRuns = append(gotRuns, r.Number)
+			}
+			if tt.wantRuns == nil {
+				tt.wantRuns = []int{}
+			}
+			if !reflect.DeepEqual(gotRuns, tt.wantRuns) {
+				t.Errorf("runs = %v, want %v", gotRuns, tt.wantRuns)
+			}
+
+			// A dry run deletes nothing
+			if len(store) != len(tt.tasks) {
+				t.Errorf("dry run deleted %d task(s)", len(tt.tasks)-len(store))
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dataDir := t.TempDir()
+	now := time.Now()
+	store := setup(t, dataDir, now, []fixture{
+		{id: "old", status: task.StatusCompleted, ago: 48 * time.Hour, logSize: 10},
+		{id: "retried", status: task.StatusFailed, ago: 48 * time.Hour, logSize: 10},
+		{id: "new", status: task.StatusCompleted, ago: time.Hour, logSize: 10},
+	}, []run{
+		{number: 1, status: group.RunSucceeded, taskIDs: []string{"old"}},
+		{number: 2, status: group.RunFailed, taskIDs: []string{"retried"}},
+	})
+	writeFile(t, filepath.Join(dataDir, "logs", "old.app.log"), "app")
+
+	// A task retried after it was selected stays with its run
+	pruner := NewPruner(dataDir, retryingStore{store, "retried"})
+	policy := &Policy{MaxAge: map[task.TaskStatus]time.Duration{task.StatusCompleted: 24 * time.Hour, task.StatusFailed: 24 * time.Hour}}
+	result, err := pruner.Prune(policy, now, false)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+
+	if len(result.Tasks) != 1 || result.Tasks[0].Task.ID != "old" || result.LogSize != 13 {
+		t.Errorf("Prune() = %+v, want only task old with 13 bytes of logs", result)
+	}
+	if len(result.Runs) != 1 || result.Runs[0].Number != 1 {
+		t.Errorf("runs = %+v, want only run #1", result.Runs)
+	}
+
+	ids := make([]string, 0, len(store))
+	for id := range store {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if want := []string{"new", "retried"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("tasks left = %v, want %v", ids, want)
+	}
+
+	logs, _ := filepath.Glob(filepath.Join(dataDir, "logs", "*"))
+	for i := range logs {
+		logs[i] = filepath.Base(logs[i])
+	}
+	sort.Strings(logs)
+	if want := []string{"new.stdout.log", "retried.stdout.log"}; !reflect.DeepEqual(logs, want) {
+		t.Errorf("logs left = %v, want %v", logs, want)
+	}
+
+	runs, _ := filepath.Glob(filepath.Join(dataDir, "runs", "*.json"))
+	if len(runs) != 1 || filepath.Base(runs[0]) != "run-2.json" {
+		t.Errorf("runs left = %v, want run-2.json", runs)
+	}
+}
+
+// retryingStore is a store in which a task is retried once it is loaded to be
+// deleted, as if the daemon retried it between planning and deleting
+type retryingStore struct {
+	mapStore
+	retried string
+}
+
+func (s retryingStore) LoadTask(id string) (*task.Task, error) {
+	t, err := s.mapStore.LoadTask(id)
+	if err == nil && id == s.retried {
+		t.Status = task.StatusPending
+	}
+	return t, err
+}
@@ -6,25 +6,53 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/Can/sysrow/pkg/proc"
 	"github.com/Can/sysrow/pkg/task"
+	"github.com/Can/sysrow/pkg/tasklog"
 )
 
+// DefaultKillGrace is how long a timed out task gets to exit after SIGTERM
+const DefaultKillGrace = 10 * time.Second
+
 // Runner is responsible for executing tasks
 type Runner struct {
 	DataDir string
+	// Store persists the tasks the runner executes
+	Store task.Store
+	// KillGrace is the time between SIGTERM and SIGKILL when a task times out
+	KillGrace time.Duration
 }
 
 // NewRunner creates a new task runner
-func NewRunner(dataDir string) *Runner {
+func NewRunner(dataDir string, store task.Store) *Runner {
 	return &Runner{
-		DataDir: dataDir,
+		DataDir:   dataDir,
+		Store:     store,
+		KillGrace: DefaultKillGrace,
 	}
 }
 
-// RunTask executes a task
+// RunTask executes a task. It fails with task.ErrClaimed if another
+// executor is already running it and with task.ErrNotPending if the task
+// has been cancelled or finished in the meantime.
 func (r *Runner) RunTask(t *task.Task, background bool) error {
+	// Make sure no other executor starts the same task
+	claim, err := t.Claim(r.Store)
+	if err != nil {
+		return err
+	}
+	release := true
+	defer func() {
+		if release {
+			claim.Release()
+		}
+	}()
+
 	// Update task status
 	now := time.Now()
 	t.Status = task.StatusRunning
@@ -37,8 +65,8 @@ func (r *Runner) RunTask(t *task.Task, background bool) error {
 	}
 
 	// Create log files
-	stdoutPath := filepath.Join(logsDir, t.ID+".stdout.log")
-	stderrPath := filepath.Join(logsDir, t.ID+".stderr.log")
+	stdoutPath := tasklog.Path(r.DataDir, t.ID, tasklog.Stdout)
+	stderrPath := tasklog.Path(r.DataDir, t.ID, tasklog.Stderr)
 
 	stdoutFile, err := os.Create(stdoutPath)
 	if err != nil {
@@ -53,7 +81,7 @@ func (r *Runner) RunTask(t *task.Task, background bool) error {
 	defer stderrFile.Close()
 
 	// Save the task state before execution
-	if err := t.Save(); err != nil {
+	if err := r.Store.SaveTask(t); err != nil {
 		return fmt.Errorf("failed to save task state: %w", err)
 	}
 
@@ -69,6 +97,23 @@ func (r *Runner) RunTask(t *task.Task, background bool) error {
 	cmd.Stdout = stdoutFile
 	cmd.Stderr = stderrFile
 
+	// Extra environment variables are added to the environment of sysrow
+	if len(t.Env) > 0 {
+		keys := make([]string, 0, len(t.Env))
+		for key := range t.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		cmd.Env = os.Environ()
+		for _, key := range keys {
+			cmd.Env = append(cmd.Env, key+"="+t.Env[key])
+		}
+	}
+
+	// Run the task in its own process group so it can be cancelled as a whole
+	proc.NewSession(cmd)
+
 	// Start the command
 	if err := cmd.Start(); err != nil {
 		// Update task status on error
@@ -77,102 +122,192 @@ func (r *Runner) RunTask(t *task.Task, background bool) error {
 		t.FinishedAt = &endTime
 		exitCode := 1
 		t.ExitCode = &exitCode
-		t.Save()
+		r.Store.SaveTask(t)
 
 		return fmt.Errorf("failed to start command: %w", err)
 	}
 
-	// Store the process ID
+	// Store the process ID along with its start time, which lets crash
+	// recovery tell our process apart from a later one reusing the PID
 	pid := cmd.Process.Pid
 	t.PID = &pid
+	if startTime, err := proc.StartTime(pid); err == nil {
+		t.PIDStart = &startTime
+	}
 
 	// Save the updated task state
-	if err := t.Save(); err != nil {
+	if err := r.Store.SaveTask(t); err != nil {
 		return fmt.Errorf("failed to save task state: %w", err)
 	}
 
+	// Note how far the logs grow over time, for `sysrow logs --since` and the merged view
+	recorder := tasklog.Record(r.DataDir, t.ID)
+
+	// Enforce the timeout by terminating the whole process group. The timer
+	// may fire while finish clears the task's PID, so it keeps its own copy.
+	var timedOut atomic.Bool
+	var timer *time.Timer
+	if t.Timeout > 0 {
+		pidStart := t.PIDStart
+		timer = time.AfterFunc(t.Timeout, func() {
+			timedOut.Store(true)
+			proc.TerminateGroup(pid, pidStart, syscall.SIGTERM, r.KillGrace)
+		})
+	}
+
+	wait := func() error {
+		err := cmd.Wait()
+		if timer != nil {
+			timer.Stop()
+		}
+		recorder.Stop()
+		return err
+	}
+
 	// If running in background, return immediately
 	if background {
+		// The goroutine keeps the claim until the command has finished
+		release = false
 		go func() {
-			// Wait for the command to complete
-			err := cmd.Wait()
+			defer claim.Release()
 
-			// Update task status
-			endTime := time.Now()
-			t.FinishedAt = &endTime
-			t.PID = nil
-
-			if err != nil {
-				// Command failed
-				t.Status = task.StatusFailed
-				exitCode := 1
-				if exitErr, ok := err.(*exec.ExitError); ok {
-					exitCode = exitErr.ExitCode()
-				}
-				t.ExitCode = &exitCode
-			} else {
-				// Command succeeded
-				t.Status = task.StatusCompleted
-				exitCode := 0
-				t.ExitCode = &exitCode
-			}
-
-			// Save the final task state
-			t.Save()
+			// Wait for the command to complete and save the final task state
+			err := wait()
+			r.finish(t, err, timedOut.Load())
 		}()
 
 		return nil
 	}
 
-	// Wait for the command to complete
-	err = cmd.Wait()
+	// Wait for the command to complete and save the final task state
+	err = wait()
+	if err := r.finish(t, err, timedOut.Load()); err != nil {
+		return fmt.Errorf("failed to save task state: %w", err)
+	}
+
+	return nil
+}
 
+// RunAttempts runs the task in the foreground until it reaches a final
+// status, waiting out the delay between retries
+func (r *Runner) RunAttempts(t *task.Task) error {
+	for {
+		if err := r.RunTask(t, false); err != nil {
+			return err
+		}
+
+		if t.Status != task.StatusPending || t.ScheduledAt == nil {
+			return nil
+		}
+
+		// Wait for the next attempt, the task may be cancelled meanwhile
+		for time.Now().Before(*t.ScheduledAt) {
+			wait := time.Until(*t.ScheduledAt)
+			if wait > time.Second {
+				wait = time.Second
+			}
+			time.Sleep(wait)
+
+			current, err := r.Store.LoadTask(t.ID)
+			if err != nil {
+				return err
+			}
+			if current.Status != task.StatusPending {
+				*t = *current
+				return nil
+			}
+		}
+	}
+}
+
+// finish records the outcome of a finished command and saves the task
+func (r *Runner) finish(t *task.Task, waitErr error, timedOut bool) error {
 	// Update task status
 	endTime := time.Now()
 	t.FinishedAt = &endTime
 	t.PID = nil
+	t.PIDStart = nil
 
-	if err != nil {
-		// Command failed
-		t.Status = task.StatusFailed
-		exitCode := 1
-		if exitErr, ok := err.(*exec.ExitError); ok {
+	exitCode := 0
+	if waitErr != nil {
+		exitCode = 1
+		if exitErr, ok := waitErr.(*exec.ExitError); ok {
 			exitCode = exitErr.ExitCode()
 		}
-		t.ExitCode = &exitCode
-	} else {
+	}
+	t.ExitCode = &exitCode
+
+	if sig, ok := proc.ExitSignal(waitErr); ok {
+		t.Signal = proc.SignalName(sig)
+	}
+
+	switch {
+	case r.cancelled(t.ID):
+		// `sysrow cancel` marks the task before signalling it, keep that status
+		t.Status = task.StatusCancelled
+	case timedOut:
+		t.Status = task.StatusTimedOut
+	case waitErr != nil:
+		// Command failed
+		t.Status = task.StatusFailed
+	default:
 		// Command succeeded
 		t.Status = task.StatusCompleted
-		exitCode := 0
-		t.ExitCode = &exitCode
 	}
 
-	// Save the final task state
-	if err := t.Save(); err != nil {
-		return fmt.Errorf("failed to save task state: %w", err)
+	attempt := task.Attempt{
+		Number:     len(t.Attempts) + 1,
+		Status:     t.Status,
+		StartedAt:  *t.StartedAt,
+		FinishedAt: endTime,
+		ExitCode:   t.ExitCode,
+		Signal:     t.Signal,
+		StdoutLog:  tasklog.FileName(t.ID, 0, tasklog.Stdout),
+		StderrLog:  tasklog.FileName(t.ID, 0, tasklog.Stderr),
 	}
 
-	return nil
+	if t.Retry != nil && t.Retry.ShouldRetry(attempt.Number, t.Status, t.ExitCode) {
+		// Keep the logs of this attempt, the next one writes to the usual files
+		if err := r.archiveLogs(t.ID, attempt.Number); err != nil {
+			return err
+		}
+		attempt.StdoutLog = tasklog.FileName(t.ID, attempt.Number, tasklog.Stdout)
+		attempt.StderrLog = tasklog.FileName(t.ID, attempt.Number, tasklog.Stderr)
+
+		next := endTime.Add(t.Retry.NextDelay(attempt.Number))
+		t.Status = task.StatusPending
+		t.ScheduledAt = &next
+		t.StartedAt = nil
+		t.FinishedAt = nil
+		t.ExitCode = nil
+		t.Signal = ""
+		t.Slot = nil
+	}
+	t.Attempts = append(t.Attempts, attempt)
+
+	// Save the final task state
+	return r.Store.SaveTask(t)
 }
 
-// GetTaskLogs returns the stdout and stderr logs for a task
-func (r *Runner) GetTaskLogs(taskID string) (string, string, error) {
-	// Define log file paths
+// archiveLogs renames the current log files and their times files to the
+// names of the given attempt
+func (r *Runner) archiveLogs(taskID string, attempt int) error {
 	logsDir := filepath.Join(r.DataDir, "logs")
-	stdoutPath := filepath.Join(logsDir, taskID+".stdout.log")
-	stderrPath := filepath.Join(logsDir, taskID+".stderr.log")
-
-	// Read stdout log
-	stdoutData, err := os.ReadFile(stdoutPath)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to read stdout log: %w", err)
+	for _, stream := range tasklog.Streams {
+		for _, name := range []func(string, int, string) string{tasklog.FileName, tasklog.TimesFileName} {
+			from := filepath.Join(logsDir, name(taskID, 0, stream))
+			to := filepath.Join(logsDir, name(taskID, attempt, stream))
+			if err := os.Rename(from, to); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to keep logs of attempt %d: %w", attempt, err)
+			}
+		}
 	}
 
-	// Read stderr log
-	stderrData, err := os.ReadFile(stderrPath)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to read stderr log: %w", err)
-	}
+	return nil
+}
 
-	return string(stdoutData), string(stderrData), nil
+// cancelled reports whether the task has been cancelled by another process
+func (r *Runner) cancelled(taskID string) bool {
+	current, err := r.Store.LoadTask(taskID)
+	return err == nil && current.Status == task.StatusCancelled
 }
@@ -0,0 +1,344 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Can/sysrow/pkg/task"
+	"github.com/Can/sysrow/pkg/tasklog"
+)
+
+// memStore is a Store for tests. Tasks are copied in and out, as a store on
+// disk would, so the runner cannot change a stored task behind its back.
+type memStore struct {
+	mutex sync.Mutex
+	tasks map[string]task.Task
+}
+
+func (s *memStore) SaveTask(t *task.Task) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tasks[t.ID] = *t
+	return nil
+}
+
+func (s *memStore) LoadTask(id string) (*task.Task, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	t, ok := s.tasks[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	return &t, nil
+}
+
+func (s *memStore) ListTasks() ([]*task.Task, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tasks := make([]*task.Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		t := t
+		tasks = append(tasks, &t)
+	}
+	return tasks, nil
+}
+
+func (s *memStore) DeleteTask(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.tasks, id)
+	return nil
+}
+
+// TestMain keeps the claims of all tests in one directory. A task run in the
+// background releases its claim after the test has seen it finish, so the
+// directory must not change between tests.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "sysrow-runner")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	task.DataDirectory = dir
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newRunner returns a runner and store for a fresh data directory
+func newRunner(t *testing.T) (*Runner, *memStore) {
+	dataDir := t.TempDir()
+	store := &memStore{tasks: make(map[string]task.Task)}
+	r := NewRunner(dataDir, store)
+	r.KillGrace = time.Second
+	return r, store
+}
+
+// readLog returns the current log of a stream of a task
+func readLog(t *testing.T, r *Runner, id, stream string) string {
+	t.Helper()
+	data, err := os.ReadFile(tasklog.Path(r.DataDir, id, stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRunTask(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands are shell commands")
+	}
+
+	tests := []struct {
+		name     string
+		command  string
+		env      map[string]string
+		timeout  time.Duration
+		status   task.TaskStatus
+		exitCode int
+		signal   string
+		stdout   string
+		stderr   string
+	}{
+		{
+			name:    "success",
+			command: "echo out; echo err >&2",
+			status:  task.StatusCompleted,
+			stdout:  "out\n",
+			stderr:  "err\n",
+		},
+		{
+			name:     "failure",
+			command:  "echo failing; exit 3",
+			status:   task.StatusFailed,
+			exitCode: 3,
+			stdout:   "failing\n",
+		},
+		{
+			name:    "environment",
+			command: `echo "$SYSROW_A $SYSROW_B"`,
+			env:     map[string]string{"SYSROW_A": "1", "SYSROW_B": "two words"},
+			status:  task.StatusCompleted,
+			stdout:  "1 two words\n",
+		},
+		{
+			name:     "timeout",
+			command:  "echo started; sleep 10",
+			timeout:  200 * time.Millisecond,
+			status:   task.StatusTimedOut,
+			exitCode: -1,
+			signal:   "SIGTERM",
+			stdout:   "started\n",
+		},
+		{
+			name:     "timeout of a task ignoring SIGTERM",
+			command:  "trap '' TERM; echo started; sleep 10",
+			timeout:  200 * time.Millisecond,
+			status:   task.StatusTimedOut,
+			exitCode: -1,
+			signal:   "SIGKILL",
+			stdout:   "started\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, store := newRunner(t)
+			tk := task.NewTask(tt.command, task.PriorityNormal)
+			tk.Env = tt.env
+			tk.Timeout = tt.timeout
+			store.SaveTask(tk)
+
+			start := time.Now()
+			if err := r.RunTask(tk, false); err != nil {
+				t.Fatalf("RunTask() error = %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("RunTask() took %v", elapsed)
+			}
+
+			got, err := store.LoadTask(tk.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.status {
+				t.Errorf("status = %s, want %s", got.Status, tt.status)
+			}
+			if got.ExitCode == nil || *got.ExitCode != tt.exitCode {
+				t.Errorf("exit code = %v, want %d", got.ExitCode, tt.exitCode)
+			}
+			if got.Signal != tt.signal {
+				t.Errorf("signal = %q, want %q", got.Signal, tt.signal)
+			}
+			if got.PID != nil || got.PIDStart != nil || got.StartedAt == nil || got.FinishedAt == nil {
+				t.Errorf("task = PID %v, started %v, finished %v, want no PID and both times", got.PID, got.StartedAt, got.FinishedAt)
+			}
+			if len(got.Attempts) != 1 || got.Attempts[0].Status != tt.status {
+				t.Errorf("attempts = %+v, want one %s attempt", got.Attempts, tt.status)
+			}
+
+			if out := readLog(t, r, tk.ID, tasklog.Stdout); out != tt.stdout {
+				t.Errorf("stdout = %q, want %q", out, tt.stdout)
+			}
+			if out := readLog(t, r, tk.ID, tasklog.Stderr); out != tt.stderr {
+				t.Errorf("stderr = %q, want %q", out, tt.stderr)
+			}
+		})
+	}
+}
+
+func TestRunTaskNotPending(t *testing.T) {
+	r, store := newRunner(t)
+	tk := task.NewTask("true", task.PriorityNormal)
+	tk.Status = task.StatusCancelled
+	store.SaveTask(tk)
+
+	queued := task.NewTask("true", task.PriorityNormal)
+	queued.ID = tk.ID
+	if err := r.RunTask(queued, false); !errors.Is(err, task.ErrNotPending) {
+		t.Fatalf("RunTask() error = %v, want ErrNotPending", err)
+	}
+	if queued.Status != task.StatusCancelled {
+		t.Errorf("status = %s, want the stored %s", queued.Status, task.StatusCancelled)
+	}
+}
+
+func TestRunTaskBackground(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands are shell commands")
+	}
+
+	r, store := newRunner(t)
+	tk := task.NewTask("sleep 0.3", task.PriorityNormal)
+	store.SaveTask(tk)
+
+	if err := r.RunTask(tk, true); err != nil {
+		t.Fatalf("RunTask() error = %v", err)
+	}
+
+	// The claim is held until the command has finished
+	if !task.Claimed(tk.ID) {
+		t.Error("Claimed() = false while the task runs")
+	}
+
+	running, _ := store.LoadTask(tk.ID)
+	if running.Status != task.StatusRunning || running.PID == nil {
+		t.Errorf("task = %s with PID %v, want running with a PID", running.Status, running.PID)
+	}
+
+	// Once the claim is released the task has been saved as completed
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if !task.Claimed(tk.ID) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("claim of the task was not released")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if current, _ := store.LoadTask(tk.ID); current.Status != task.StatusCompleted {
+		t.Errorf("status = %s, want %s", current.Status, task.StatusCompleted)
+	}
+}
+
+func TestRunAttempts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands are shell commands")
+	}
+
+	tests := []struct {
+		name string
+		// command fails until its counter file reaches succeedAt, 0 never succeeds
+		succeedAt int
+		retries   int
+		status    task.TaskStatus
+		attempts  []task.TaskStatus
+	}{
+		{
+			name:     "no retries",
+			retries:  0,
+			status:   task.StatusFailed,
+			attempts: []task.TaskStatus{task.StatusFailed},
+		},
+		{
+			name:     "retries used up",
+			retries:  2,
+			status:   task.StatusFailed,
+			attempts: []task.TaskStatus{task.StatusFailed, task.StatusFailed, task.StatusFailed},
+		},
+		{
+			name:      "succeeds on a retry",
+			succeedAt: 2,
+			retries:   3,
+			status:    task.StatusCompleted,
+			attempts:  []task.TaskStatus{task.StatusFailed, task.StatusCompleted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, store := newRunner(t)
+			counter := filepath.Join(r.DataDir, "counter")
+			command := fmt.Sprintf(`n=$(($(cat %[1]q 2>/dev/null || echo 0) + 1)); echo $n > %[1]q; echo "attempt $n"; test $n -eq %[2]d`, counter, tt.succeedAt)
+
+			tk := task.NewTask(command, task.PriorityNormal)
+			if tt.retries > 0 {
+				tk.Retry = &task.RetryPolicy{MaxRetries: tt.retries, Backoff: task.BackoffFixed, Delay: 50 * time.Millisecond}
+			}
+			store.SaveTask(tk)
+
+			if err := r.RunAttempts(tk); err != nil {
+				t.Fatalf("RunAttempts() error = %v", err)
+			}
+
+			got, err := store.LoadTask(tk.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.status {
+				t.Errorf("status = %s, want %s", got.Status, tt.status)
+			}
+			if len(got.Attempts) != len(tt.attempts) {
+				t.Fatalf("attempts = %+v, want %d", got.Attempts, len(tt.attempts))
+			}
+
+			for i, attempt := range got.Attempts {
+				if attempt.Number != i+1 || attempt.Status != tt.attempts[i] {
+					t.Errorf("attempt %d = #%d %s, want #%d %s", i, attempt.Number, attempt.Status, i+1, tt.attempts[i])
+				}
+
+				// Retried attempts keep their logs, the last one has the usual files
+				number := i + 1
+				if number == len(got.Attempts) {
+					number = 0
+				}
+				if want := tasklog.FileName(tk.ID, number, tasklog.Stdout); attempt.StdoutLog != want {
+					t.Errorf("attempt %d log = %s, want %s", i+1, attempt.StdoutLog, want)
+				}
+				data, err := os.ReadFile(filepath.Join(r.DataDir, "logs", attempt.StdoutLog))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := fmt.Sprintf("attempt %d\n", i+1); string(data) != want {
+					t.Errorf("attempt %d output = %q, want %q", i+1, data, want)
+				}
+			}
+
+			// Attempts are at least the retry delay apart
+			for i := 1; i < len(got.Attempts); i++ {
+				if gap := got.Attempts[i].StartedAt.Sub(got.Attempts[i-1].FinishedAt); gap < 50*time.Millisecond {
+					t.Errorf("attempt %d started %v after the last one, want at least the delay", i+1, gap)
+				}
+			}
+		})
+	}
+}
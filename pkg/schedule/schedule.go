@@ -0,0 +1,141 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockLayouts are the accepted formats for a time of day
+var clockLayouts = []string{"15:04", "15:04:05"}
+
+// dateTimeLayouts are the accepted formats for a full date and time
+var dateTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// durationUnits maps the unit suffixes accepted by ParseDuration to their length
+var durationUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+// ParseDuration parses durations such as 30s, 5m, 2h, 1d or 1d12h.
+// In addition to the units understood by time.ParseDuration it accepts
+// d (days) and w (weeks).
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var total time.Duration
+	rest := value
+	for rest != "" {
+		// Read the number
+		i := 0
+		for i < len(rest) && (rest[i] >= '0' && rest[i] <= '9' || rest[i] == '.') {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		number, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		rest = rest[i:]
+
+		// Read the unit
+		j := 0
+		for j < len(rest) && rest[j] >= 'a' && rest[j] <= 'z' {
+			j++
+		}
+		unit, ok := durationUnits[rest[:j]]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration %q: unknown unit %q", value, rest[:j])
+		}
+		rest = rest[j:]
+
+		total += time.Duration(number * float64(unit))
+	}
+
+	if total <= 0 {
+		return 0, fmt.Errorf("duration must be positive: %q", value)
+	}
+
+	return total, nil
+}
+
+// ParseAfter returns the time that is the given duration after now
+func ParseAfter(value string, now time.Time) (time.Time, error) {
+	d, err := ParseDuration(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return now.Add(d), nil
+}
+
+// ParseAt parses a time of day (HH:MM or HH:MM:SS) or a full date and time
+// (RFC3339 or YYYY-MM-DD HH:MM). A time of day that has already passed today
+// refers to the same time tomorrow. A full date and time must be in the future.
+func ParseAt(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range clockLayouts {
+		clock, err := time.ParseInLocation(layout, value, now.Location())
+		if err != nil {
+			continue
+		}
+
+		at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+
+		return at, nil
+	}
+
+	for _, layout := range dateTimeLayouts {
+		at, err := time.ParseInLocation(layout, value, now.Location())
+		if err != nil {
+			continue
+		}
+
+		if !at.After(now) {
+			return time.Time{}, fmt.Errorf("time %s is in the past", at.Format(time.RFC3339))
+		}
+
+		return at, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q (expected HH:MM or an RFC3339 date and time)", value)
+}
+
+// ParseSince parses the start of a time range: a date (YYYY-MM-DD), a full
+// date and time, or a duration such as 7d that reaches back from now
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range append([]string{"2006-01-02"}, dateTimeLayouts...) {
+		if at, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return at, nil
+		}
+	}
+
+	if d, err := ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q (expected a date such as 2026-01-31, an RFC3339 date and time or a duration such as 7d)", value)
+}
@@ -0,0 +1,145 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"30s", 30 * time.Second},
+		{"5m", 5 * time.Minute},
+		{"2h", 2 * time.Hour},
+		{"1d", 24 * time.Hour},
+		{"1w", 7 * 24 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"1h30m10s", time.Hour + 30*time.Minute + 10*time.Second},
+		{"1.5h", 90 * time.Minute},
+		{"500ms", 500 * time.Millisecond},
+		{" 10m ", 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.value)
+		if err != nil {
+			t.Errorf("ParseDuration(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseDurationErrors(t *testing.T) {
+	tests := []string{"", "  ", "5", "h", "5x", "5M", "0s", "0d0h", "-5m", "1.2.3h", "5m-"}
+
+	for _, value := range tests {
+		if got, err := ParseDuration(value); err == nil {
+			t.Errorf("ParseDuration(%q) = %s, want an error", value, got)
+		}
+	}
+}
+
+func TestParseAfter(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	got, err := ParseAfter("1d2h", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 10, 17, 14, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("ParseAfter() = %s, want %s", got, want)
+	}
+}
+
+func TestParseAt(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, loc)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		// A time of day later today
+		{"14:30", time.Date(2026, 10, 16, 14, 30, 0, 0, loc)},
+		{"12:00:01", time.Date(2026, 10, 16, 12, 0, 1, 0, loc)},
+		// A time of day that has passed, or is now, is tomorrow
+		{"02:00", time.Date(2026, 10, 17, 2, 0, 0, 0, loc)},
+		{"12:00", time.Date(2026, 10, 17, 12, 0, 0, 0, loc)},
+		// Dates without a zone are in the local zone
+		{"2026-10-17 09:30", time.Date(2026, 10, 17, 9, 30, 0, 0, loc)},
+		{"2026-10-17T09:30:15", time.Date(2026, 10, 17, 9, 30, 15, 0, loc)},
+		{"2027-01-15T09:30:00Z", time.Date(2027, 1, 15, 9, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAt(tt.value, now)
+		if err != nil {
+			t.Errorf("ParseAt(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseAt(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseAtErrors(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []string{
+		"",
+		"25:00",
+		"12:60",
+		"noon",
+		"5h",
+		// Full dates must be in the future
+		"2026-10-16 12:00",
+		"2026-01-01T00:00:00Z",
+	}
+
+	for _, value := range tests {
+		if got, err := ParseAt(value, now); err == nil {
+			t.Errorf("ParseAt(%q) = %s, want an error", value, got)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2026-01-31", time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{"2026-01-31 08:15", time.Date(2026, 1, 31, 8, 15, 0, 0, time.UTC)},
+		{"2026-01-31T08:15:00+03:00", time.Date(2026, 1, 31, 5, 15, 0, 0, time.UTC)},
+		// Durations reach back from now
+		{"7d", time.Date(2026, 10, 9, 12, 0, 0, 0, time.UTC)},
+		{"90m", time.Date(2026, 10, 16, 10, 30, 0, 0, time.UTC)},
+		// Unlike ParseAt, dates in the future are accepted
+		{"2027-01-01", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSince(tt.value, now)
+		if err != nil {
+			t.Errorf("ParseSince(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseSince(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "yesterday", "2026-13-01", "-7d"} {
+		if got, err := ParseSince(value, now); err == nil {
+			t.Errorf("ParseSince(%q) = %s, want an error", value, got)
+		}
+	}
+}
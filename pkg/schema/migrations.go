@@ -0,0 +1,337 @@
+package schema
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Can/sysrow/pkg/fsutil"
+)
+
+// migration upgrades the data directory by one schema version. It returns
+// warnings about records it could only partly migrate.
+type migration func(dataDir string) ([]string, error)
+
+// migrations[v] upgrades a data directory from version v to v+1, so there
+// is exactly one migration for every version below Version
+var migrations = []migration{
+	addRecordVersions,
+	groupTasksToSteps,
+}
+
+// record is a JSON object whose fields are kept as they are on disk, so
+// fields a migration does not touch survive it unchanged
+type record map[string]json.RawMessage
+
+// addRecordVersions stamps every record written before schema versions
+// existed with version 1
+func addRecordVersions(dataDir string) ([]string, error) {
+	stamp := func(r record) error {
+		r["schema_version"] = json.RawMessage("1")
+		return nil
+	}
+
+	for _, dir := range recordDirs {
+		if err := rewriteRecords(filepath.Join(dataDir, dir), stamp); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, rewriteTaskLog(filepath.Join(dataDir, "db"), stamp)
+}
+
+// stepFields are the fields a group step shares with a task
+var stepFields = []string{"name", "command", "priority", "timeout", "retry", "env", "condition"}
+
+// groupTasksToSteps turns the task IDs of groups created before groups had
+// steps into steps with the settings of those tasks. A task that no longer
+// exists, e.g. because it was pruned, cannot become a step and is reported.
+func groupTasksToSteps(dataDir string) ([]string, error) {
+	tasks, err := readTaskRecords(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := make([]string, 0)
+	err = readRecords(filepath.Join(dataDir, "groups"), func(path string, r record) error {
+		raw, ok := r["task_ids"]
+		if !ok {
+			return nil
+		}
+
+		var name string
+		var taskIDs []string
+		steps := make([]record, 0)
+		json.Unmarshal(r["name"], &name)
+		if err := json.Unmarshal(raw, &taskIDs); err != nil {
+			return fmt.Errorf("invalid task_ids: %w", err)
+		}
+		if data, ok := r["steps"]; ok {
+			if err := json.Unmarshal(data, &steps); err != nil {
+				return fmt.Errorf("invalid steps: %w", err)
+			}
+		}
+
+		for _, id := range taskIDs {
+			t, ok := tasks[id]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("group %s: task %s no longer exists, its step was left out", name, id))
+				continue
+			}
+
+			step := make(record)
+			for _, field := range stepFields {
+				if value, ok := t[field]; ok {
+					step[field] = value
+				}
+			}
+			steps = append(steps, step)
+		}
+
+		data, err := json.Marshal(steps)
+		if err != nil {
+			return err
+		}
+		r["steps"] = data
+		delete(r, "task_ids")
+		r["schema_version"] = json.RawMessage("2")
+
+		return writeRecord(path, r)
+	})
+
+	return warnings, err
+}
+
+// readTaskRecords returns the tasks of both storage backends by ID
+func readTaskRecords(dataDir string) (map[string]record, error) {
+	tasks := make(map[string]record)
+	add := func(r record) {
+		var id string
+		if json.Unmarshal(r["id"], &id) == nil && id != "" {
+			tasks[id] = r
+		}
+	}
+
+	// The JSON backend keeps a file per task
+	err := readRecords(filepath.Join(dataDir, "tasks"), func(path string, r record) error {
+		add(r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The log backend keeps a snapshot and the changes made since
+	dir := filepath.Join(dataDir, "db")
+	var snapshot int
+	err = readLines(filepath.Join(dir, "tasks.snapshot"), func(generation int, line []byte) {
+		snapshot = generation
+		var r record
+		if json.Unmarshal(line, &r) == nil {
+			add(r)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, readLines(filepath.Join(dir, "tasks.log"), func(generation int, line []byte) {
+		// The log of an interrupted compaction is already part of the snapshot
+		if generation < snapshot {
+			return
+		}
+
+		var rec struct {
+			Op   string `json:"op"`
+			ID   string `json:"id"`
+			Task record `json:"task"`
+		}
+		if json.Unmarshal(line, &rec) != nil {
+			return
+		}
+		if rec.Op == "delete" {
+			delete(tasks, rec.ID)
+		} else if rec.Task != nil {
+			add(rec.Task)
+		}
+	})
+}
+
+// readLines calls fn with the generation of the header line and every
+// complete line after it of a log or snapshot file
+func readLines(path string, fn func(generation int, line []byte)) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var header struct {
+		Generation int `json:"generation"`
+	}
+	line, err := reader.ReadBytes('\n')
+	if err != nil || json.Unmarshal(line, &header) != nil {
+		return nil
+	}
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A last line without a newline was never written completely
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		fn(header.Generation, bytes.TrimSpace(line))
+	}
+}
+
+// rewriteRecords applies fn to every JSON record in dir and writes it back
+func rewriteRecords(dir string, fn func(record) error) error {
+	return readRecords(dir, func(path string, r record) error {
+		if err := fn(r); err != nil {
+			return err
+		}
+		return writeRecord(path, r)
+	})
+}
+
+// readRecords calls fn for every JSON record in dir
+func readRecords(dir string, fn func(path string, r record) error) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" || fsutil.IsTemp(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		// Corrupt files are left to the recovery, which quarantines them
+		var r record
+		if err := json.Unmarshal(data, &r); err != nil {
+			continue
+		}
+		if err := fn(path, r); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// writeRecord writes a record back to its file
+func writeRecord(path string, r record) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", path, err)
+	}
+	if err := fsutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// rewriteTaskLog applies fn to every task of the append-only log backend:
+// the lines of the snapshot and the tasks of the put records in the log.
+// The header line of both files is kept.
+func rewriteTaskLog(dir string, fn func(record) error) error {
+	// Snapshot lines are tasks
+	err := rewriteLines(filepath.Join(dir, "tasks.snapshot"), func(line []byte) ([]byte, error) {
+		return rewriteRecord(line, fn)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Log lines are records holding a task
+	return rewriteLines(filepath.Join(dir, "tasks.log"), func(line []byte) ([]byte, error) {
+		// Corrupt records are skipped by the log backend as well
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return line, nil
+		}
+		if _, ok := rec["task"]; !ok {
+			return line, nil
+		}
+
+		t, err := rewriteRecord(rec["task"], fn)
+		if err != nil {
+			return nil, err
+		}
+		rec["task"] = t
+
+		return json.Marshal(rec)
+	})
+}
+
+// rewriteRecord applies fn to a single encoded record
+func rewriteRecord(data []byte, fn func(record) error) ([]byte, error) {
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if err := fn(r); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(r)
+}
+
+// rewriteLines applies fn to every complete line of a file after the header
+// line. A last line without a newline was never written completely and is
+// dropped.
+func rewriteLines(path string, fn func([]byte) ([]byte, error)) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	var buf bytes.Buffer
+	for i, line := range lines {
+		if !bytes.HasSuffix(line, []byte("\n")) {
+			break
+		}
+		if i == 0 {
+			buf.Write(line)
+			continue
+		}
+
+		rewritten, err := fn(bytes.TrimSpace(line))
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", path, i+1, err)
+		}
+		buf.Write(rewritten)
+		buf.WriteByte('\n')
+	}
+
+	if err := fsutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+// Package schema versions the metadata sysrow keeps in its data directory.
+// Every record carries the schema version it was written with, and the data
+// directory records the version of all its files in schema.json. A newer
+// sysrow upgrades older files in place after taking a backup; an older
+// sysrow refuses to touch files written by a newer one.
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Can/sysrow/pkg/fsutil"
+	"github.com/Can/sysrow/pkg/lock"
+)
+
+// Version is the schema version written by this build. Increase it and add
+// a migration whenever a persisted record changes in a way older files or
+// older builds cannot cope with.
+const Version = 2
+
+// ErrTooNew is returned for data written by a newer sysrow
+var ErrTooNew = errors.New("data was written by a newer version of sysrow, please upgrade sysrow")
+
+// recordDirs are the directories of the data directory that hold one JSON
+// record per file
+var recordDirs = []string{"tasks", "groups", "runs", "recurring"}
+
+// backupPaths are copied before the data directory is upgraded. Task logs
+// are left out, no migration touches them.
+var backupPaths = []string{"config.json", "schema.json", "queues.json", "tasks", "groups", "runs", "recurring", "db"}
+
+// Upgraded describes an upgrade of the data directory
+type Upgraded struct {
+	From   int
+	To     int
+	Backup string
+	// Warnings describe records that could only partly be migrated
+	Warnings []string
+}
+
+// dataVersion is the contents of schema.json
+type dataVersion struct {
+	Version int `json:"version"`
+}
+
+// CheckRecord returns an error wrapping ErrTooNew if a record was written by
+// a newer sysrow
+func CheckRecord(kind, id string, version int) error {
+	if version > Version {
+		return fmt.Errorf("%w: %s %s has schema version %d, this sysrow supports up to %d", ErrTooNew, kind, id, version, Version)
+	}
+
+	return nil
+}
+
+// DataVersion returns the schema version of the data directory. A data
+// directory without schema.json was written before versions were recorded
+// and has version 0.
+func DataVersion(dataDir string) (int, error) {
+	data, err := os.ReadFile(versionPath(dataDir))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	var v dataVersion
+	if err := json.Unmarshal(data, &v); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal schema version: %w", err)
+	}
+
+	return v.Version, nil
+}
+
+// Upgrade brings the data directory to the current schema version. It
+// returns nil if nothing had to be migrated and an error wrapping ErrTooNew
+// if the data directory is newer than this build.
+func Upgrade(dataDir string) (*Upgraded, error) {
+	// Most of the time the data directory is up to date, check without the lock
+	if version, err := DataVersion(dataDir); err != nil || version == Version {
+		return nil, err
+	}
+
+	l, err := lock.DataDir(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	// Another process may have upgraded it while we waited for the lock
+	version, err := DataVersion(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	if version > Version {
+		return nil, fmt.Errorf("%w: data directory %s has schema version %d, this sysrow supports up to %d", ErrTooNew, dataDir, version, Version)
+	}
+	if version == Version {
+		return nil, nil
+	}
+
+	// A new data directory has nothing to migrate
+	empty, err := isEmpty(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	if empty {
+		return nil, writeVersion(dataDir, Version)
+	}
+
+	backup, err := backupDataDir(dataDir, version)
+	if err != nil {
+		return nil, err
+	}
+
+	upgraded := &Upgraded{From: version, To: Version, Backup: backup}
+	for v := version; v < Version; v++ {
+		warnings, err := migrations[v](dataDir)
+		upgraded.Warnings = append(upgraded.Warnings, warnings...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate data directory from schema version %d to %d (backup in %s): %w", v, v+1, backup, err)
+		}
+
+		// Record every step so an interrupted upgrade resumes where it stopped
+		if err := writeVersion(dataDir, v+1); err != nil {
+			return nil, err
+		}
+	}
+
+	return upgraded, nil
+}
+
+// versionPath returns the path of the schema version file
+func versionPath(dataDir string) string {
+	return filepath.Join(dataDir, "schema.json")
+}
+
+// writeVersion records the schema version of the data directory
+func writeVersion(dataDir string, version int) error {
+	data, err := json.MarshalIndent(dataVersion{Version: version}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema version: %w", err)
+	}
+
+	if err := fsutil.WriteFile(versionPath(dataDir), data, 0644); err != nil {
+		return fmt.Errorf("failed to write schema version: %w", err)
+	}
+
+	return nil
+}
+
+// isEmpty reports whether the data directory holds no records yet
+func isEmpty(dataDir string) (bool, error) {
+	for _, dir := range append(recordDirs, "db") {
+		entries, err := os.ReadDir(filepath.Join(dataDir, dir))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s directory: %w", dir, err)
+		}
+		if len(entries) > 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// backupDataDir copies the metadata of the data directory to
+// backups/schema-v<version>-<time> and returns the backup directory
+func backupDataDir(dataDir string, version int) (string, error) {
+	backup := filepath.Join(dataDir, "backups", fmt.Sprintf("schema-v%d-%s", version, time.Now().Format("20060102-150405")))
+
+	for _, name := range backupPaths {
+		src := filepath.Join(dataDir, name)
+		err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if fsutil.IsTemp(d.Name()) {
+				return nil
+			}
+
+			rel, err := filepath.Rel(dataDir, path)
+			if err != nil {
+				return err
+			}
+			dst := filepath.Join(backup, rel)
+
+			if d.IsDir() {
+				return os.MkdirAll(dst, 0755)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return err
+			}
+			return fsutil.WriteFile(dst, data, 0644)
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to back up %s: %w", name, err)
+		}
+	}
+
+	return backup, nil
+}
@@ -0,0 +1,228 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	taskID    = "11111111-1111-1111-1111-111111111111"
+	missingID = "22222222-2222-2222-2222-222222222222"
+)
+
+// writeFiles creates files relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readRecord reads a JSON record relative to dir
+func readRecord(t *testing.T, dir, name string) map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r map[string]interface{}
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestUpgrade(t *testing.T) {
+	task := `{"id":"` + taskID + `","name":"build","command":"make","priority":"high","env":{"A":"1"},"condition":"failure","status":"completed"}`
+	group := `{"id":"g1","name":"ci","task_ids":["` + taskID + `","` + missingID + `"],"run_count":3}`
+	buildStep := map[string]interface{}{
+		"name": "build", "command": "make", "priority": "high",
+		"env": map[string]interface{}{"A": "1"}, "condition": "failure",
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		// from is the version upgraded from, -1 if nothing is upgraded
+		from     int
+		wantErr  error
+		warnings int
+		check    func(t *testing.T, dataDir string)
+	}{
+		{
+			name: "new data directory",
+			from: -1,
+		},
+		{
+			name:  "up to date",
+			files: map[string]string{"schema.json": `{"version":2}`, "groups/g1.json": group},
+			from:  -1,
+			check: func(t *testing.T, dataDir string) {
+				// Nothing is migrated twice
+				if _, ok := readRecord(t, dataDir, "groups/g1.json")["task_ids"]; !ok {
+					t.Error("group of an up to date data directory was migrated")
+				}
+			},
+		},
+		{
+			name:    "too new",
+			files:   map[string]string{"schema.json": `{"version":99}`, "tasks/" + taskID + ".json": task},
+			from:    -1,
+			wantErr: ErrTooNew,
+		},
+		{
+			name: "version 0 with json tasks",
+			files: map[string]string{
+				"tasks/" + taskID + ".json": task,
+				"groups/g1.json":            group,
+				"tasks/broken.json":         `{"id":`,
+			},
+			from:     0,
+			warnings: 1,
+			check: func(t *testing.T, dataDir string) {
+				r := readRecord(t, dataDir, "tasks/"+taskID+".json")
+				if r["schema_version"] != 1.0 || r["command"] != "make" {
+					t.Errorf("task = %v, want schema_version 1 and its fields kept", r)
+				}
+
+				g := readRecord(t, dataDir, "groups/g1.json")
+				if _, ok := g["task_ids"]; ok {
+					t.Error("group still has task_ids")
+				}
+				if g["schema_version"] != 2.0 || g["run_count"] != 3.0 {
+					t.Errorf("group = %v, want schema_version 2 and its fields kept", g)
+				}
+				steps, _ := g["steps"].([]interface{})
+				if len(steps) != 1 || !reflect.DeepEqual(steps[0], buildStep) {
+					t.Errorf("steps = %v, want only %v", steps, buildStep)
+				}
+
+				// Corrupt files are left to the recovery
+				if data, _ := os.ReadFile(filepath.Join(dataDir, "tasks/broken.json")); string(data) != `{"id":` {
+					t.Errorf("corrupt file was changed to %q", data)
+				}
+			},
+		},
+		{
+			name: "version 1 with the log backend",
+			files: map[string]string{
+				"schema.json": `{"version":1}`,
+				"db/tasks.snapshot": "{\"generation\":2}\n" +
+					`{"id":"` + missingID + `","command":"gone"}` + "\n",
+				"db/tasks.log": "{\"generation\":2}\n" +
+					`{"op":"put","id":"` + taskID + `","task":` + task + "}\n" +
+					`{"op":"delete","id":"` + missingID + `"}` + "\n",
+				"groups/g1.json": group,
+			},
+			from:     1,
+			warnings: 1,
+			check: func(t *testing.T, dataDir string) {
+				steps, _ := readRecord(t, dataDir, "groups/g1.json")["steps"].([]interface{})
+				if len(steps) != 1 || !reflect.DeepEqual(steps[0], buildStep) {
+					t.Errorf("steps = %v, want only %v", steps, buildStep)
+				}
+			},
+		},
+		{
+			name: "stale log of an interrupted compaction",
+			files: map[string]string{
+				"schema.json": `{"version":1}`,
+				"db/tasks.snapshot": "{\"generation\":3}\n" +
+					task + "\n",
+				"db/tasks.log": "{\"generation\":2}\n" +
+					`{"op":"delete","id":"` + taskID + `"}` + "\n",
+				"groups/g1.json": `{"id":"g1","name":"ci","task_ids":["` + taskID + `"]}`,
+			},
+			from: 1,
+			check: func(t *testing.T, dataDir string) {
+				steps, _ := readRecord(t, dataDir, "groups/g1.json")["steps"].([]interface{})
+				if len(steps) != 1 {
+					t.Errorf("steps = %v, want the task of the snapshot", steps)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			writeFiles(t, dataDir, tt.files)
+
+			upgraded, err := Upgrade(dataDir)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Upgrade() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Upgrade() error = %v", err)
+			}
+
+			if version, err := DataVersion(dataDir); err != nil || version != Version {
+				t.Errorf("DataVersion() = %d, %v, want %d", version, err, Version)
+			}
+
+			if tt.from < 0 {
+				if upgraded != nil {
+					t.Errorf("Upgrade() = %+v, want nil", upgraded)
+				}
+				if _, err := os.Stat(filepath.Join(dataDir, "backups")); !os.IsNotExist(err) {
+					t.Errorf("backup taken without an upgrade")
+				}
+			} else {
+				if upgraded == nil || upgraded.From != tt.from || upgraded.To != Version {
+					t.Fatalf("Upgrade() = %+v, want from %d to %d", upgraded, tt.from, Version)
+				}
+				if len(upgraded.Warnings) != tt.warnings {
+					t.Errorf("warnings = %q, want %d", upgraded.Warnings, tt.warnings)
+				}
+				for _, warning := range upgraded.Warnings {
+					if !strings.Contains(warning, missingID) {
+						t.Errorf("warning %q does not name the missing task", warning)
+					}
+				}
+
+				// The backup holds the files as they were
+				for name, content := range tt.files {
+					data, err := os.ReadFile(filepath.Join(upgraded.Backup, name))
+					if err != nil || string(data) != content {
+						t.Errorf("backup of %s = %q, %v, want %q", name, data, err, content)
+					}
+				}
+			}
+
+			if tt.check != nil {
+				tt.check(t, dataDir)
+			}
+
+			// A second upgrade has nothing to do
+			if again, err := Upgrade(dataDir); again != nil || err != nil {
+				t.Errorf("second Upgrade() = %+v, %v, want nil", again, err)
+			}
+		})
+	}
+}
+
+func TestCheckRecord(t *testing.T) {
+	for _, version := range []int{0, 1, Version} {
+		if err := CheckRecord("task", "x", version); err != nil {
+			t.Errorf("CheckRecord(%d) error = %v", version, err)
+		}
+	}
+
+	if err := CheckRecord("task", "x", Version+1); !errors.Is(err, ErrTooNew) {
+		t.Errorf("CheckRecord(%d) error = %v, want ErrTooNew", Version+1, err)
+	}
+}
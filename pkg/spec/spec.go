@@ -0,0 +1,365 @@
+// Package spec parses declarative group files. A file describes one or more
+// groups and their steps in a small INI-like syntax:
+//
+//	# deploy.sysrow
+//	[group deploy]
+//	env = STAGE=production
+//
+//	[step build]
+//	command = make build
+//	timeout = 10m
+//
+//	[step test]
+//	command = make test
+//	needs = build
+//	retries = 2
+//	retry-delay = 30s
+//
+//	[step rollback]
+//	command = ./rollback.sh
+//	after = test
+//	when = failure
+//
+// A [step] section belongs to the [group] section above it. Lines starting
+// with # or ; are comments, and a line ending with \ continues on the next one.
+package spec
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Can/sysrow/pkg/group"
+	"github.com/Can/sysrow/pkg/schedule"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// ParseFile parses the groups described in a file
+func ParseFile(path string) ([]*group.Group, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	return Parse(f, path)
+}
+
+// parser holds the state while reading a file
+type parser struct {
+	name   string
+	line   int
+	groups []*group.Group
+
+	group *group.Group
+	step  *stepSpec
+}
+
+// stepSpec collects the keys of a [step] section until the section ends
+type stepSpec struct {
+	step        group.Step
+	line        int
+	needs       []string
+	after       []string
+	when        string
+	retries     int
+	backoff     string
+	retryDelay  string
+	retryOnExit string
+}
+
+// Parse parses the groups described in r. name is used in error messages.
+func Parse(r io.Reader, name string) ([]*group.Group, error) {
+	p := &parser{name: name}
+
+	scanner := bufio.NewScanner(r)
+	var pending string
+	for scanner.Scan() {
+		p.line++
+		text := strings.TrimSpace(scanner.Text())
+
+		// Join continued lines
+		if strings.HasSuffix(text, "\\") {
+			pending += strings.TrimSuffix(text, "\\") + " "
+			continue
+		}
+		text = pending + text
+		pending = ""
+
+		if err := p.parseLine(text); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if pending != "" {
+		return nil, p.errorf("line continuation at end of file")
+	}
+
+	if err := p.endStep(); err != nil {
+		return nil, err
+	}
+
+	if len(p.groups) == 0 {
+		return nil, fmt.Errorf("%s: no groups defined", name)
+	}
+
+	for _, g := range p.groups {
+		if err := group.ValidateSteps(g.Steps); err != nil {
+			return nil, fmt.Errorf("%s: group %s: %w", name, g.Name, err)
+		}
+	}
+
+	return p.groups, nil
+}
+
+// errorf returns an error pointing at the current line
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.name, p.line, fmt.Sprintf(format, args...))
+}
+
+// parseLine handles a single (joined) line
+func (p *parser) parseLine(text string) error {
+	if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";") {
+		return nil
+	}
+
+	if strings.HasPrefix(text, "[") {
+		if !strings.HasSuffix(text, "]") {
+			return p.errorf("unterminated section header")
+		}
+		return p.parseSection(strings.TrimSpace(text[1 : len(text)-1]))
+	}
+
+	key, value, ok := strings.Cut(text, "=")
+	if !ok {
+		return p.errorf("expected key = value")
+	}
+	key = strings.ToLower(strings.TrimSpace(key))
+	value = unquote(strings.TrimSpace(value))
+
+	switch {
+	case p.step != nil:
+		return p.parseStepKey(key, value)
+	case p.group != nil:
+		return p.parseGroupKey(key, value)
+	default:
+		return p.errorf("%q outside of a [group] or [step] section", key)
+	}
+}
+
+// parseSection starts a [group name] or [step name] section
+func (p *parser) parseSection(header string) error {
+	kind, name, _ := strings.Cut(header, " ")
+	name = unquote(strings.TrimSpace(name))
+	if name == "" {
+		return p.errorf("section [%s] needs a name", kind)
+	}
+
+	if err := p.endStep(); err != nil {
+		return err
+	}
+
+	switch kind {
+	case "group":
+		for _, g := range p.groups {
+			if g.Name == name {
+				return p.errorf("group %q defined twice", name)
+			}
+		}
+		p.group = &group.Group{Name: name, Steps: make([]group.Step, 0)}
+		p.groups = append(p.groups, p.group)
+	case "step":
+		if p.group == nil {
+			return p.errorf("step %q is not inside a group", name)
+		}
+		if err := task.ValidateName(name); err != nil {
+			return p.errorf("invalid step name: %v", err)
+		}
+		p.step = &stepSpec{step: group.Step{Name: name, Priority: task.PriorityNormal}, line: p.line}
+	default:
+		return p.errorf("unknown section [%s], expected [group <name>] or [step <name>]", kind)
+	}
+
+	return nil
+}
+
+// parseGroupKey handles a key of a [group] section
+func (p *parser) parseGroupKey(key, value string) error {
+	switch key {
+	case "env":
+		return p.addEnv(&p.group.Env, value)
+	default:
+		return p.errorf("unknown group key %q", key)
+	}
+}
+
+// parseStepKey handles a key of a [step] section
+func (p *parser) parseStepKey(key, value string) error {
+	s := p.step
+
+	var err error
+	switch key {
+	case "command":
+		s.step.Command = value
+	case "priority":
+		if s.step.Priority, err = task.ParsePriority(value); err != nil {
+			return p.errorf("%v", err)
+		}
+	case "timeout":
+		if s.step.Timeout, err = schedule.ParseDuration(value); err != nil {
+			return p.errorf("invalid timeout: %v", err)
+		}
+	case "env":
+		return p.addEnv(&s.step.Env, value)
+	case "needs":
+		s.needs = append(s.needs, splitList(value)...)
+	case "after":
+		s.after = append(s.after, splitList(value)...)
+	case "when":
+		if s.when, err = task.ParseCondition(value); err != nil {
+			return p.errorf("%v", err)
+		}
+	case "retries":
+		if s.retries, err = strconv.Atoi(value); err != nil || s.retries < 0 {
+			return p.errorf("invalid retries %q", value)
+		}
+	case "backoff":
+		s.backoff = value
+	case "retry-delay":
+		s.retryDelay = value
+	case "retry-on-exit":
+		s.retryOnExit = value
+	default:
+		return p.errorf("unknown step key %q", key)
+	}
+
+	return nil
+}
+
+// endStep validates the current step and adds it to its group
+func (p *parser) endStep() error {
+	s := p.step
+	if s == nil {
+		return nil
+	}
+	p.step = nil
+
+	// Report problems at the section header
+	line := p.line
+	p.line = s.line
+	defer func() { p.line = line }()
+
+	if s.step.Command == "" {
+		return p.errorf("step %q has no command", s.step.Name)
+	}
+
+	// needs means success, a bare after waits for any outcome, like the
+	// --needs and --after-task flags
+	if len(s.needs) > 0 || len(s.after) > 0 {
+		s.step.DependsOn = append(append([]string{}, s.after...), s.needs...)
+		s.step.Condition = task.ConditionCompletion
+		if len(s.needs) > 0 {
+			s.step.Condition = task.ConditionSuccess
+		}
+		if s.when != "" {
+			if len(s.needs) > 0 && s.when != task.ConditionSuccess {
+				return p.errorf("step %q: needs cannot be combined with when = %s, use after", s.step.Name, s.when)
+			}
+			s.step.Condition = s.when
+		}
+	} else if s.when != "" {
+		return p.errorf("step %q: when requires needs or after", s.step.Name)
+	}
+
+	retry, err := s.retryPolicy()
+	if err != nil {
+		return p.errorf("step %q: %v", s.step.Name, err)
+	}
+	s.step.Retry = retry
+
+	p.group.Steps = append(p.group.Steps, s.step)
+	return nil
+}
+
+// retryPolicy builds the retry policy of a step, nil when retries is not set
+func (s *stepSpec) retryPolicy() (*task.RetryPolicy, error) {
+	if s.retries == 0 {
+		if s.backoff != "" || s.retryDelay != "" || s.retryOnExit != "" {
+			return nil, fmt.Errorf("retry options require retries")
+		}
+		return nil, nil
+	}
+
+	policy := &task.RetryPolicy{
+		MaxRetries: s.retries,
+		Backoff:    task.BackoffFixed,
+		Delay:      task.DefaultRetryDelay,
+	}
+
+	var err error
+	if s.backoff != "" {
+		if policy.Backoff, err = task.ParseBackoff(s.backoff); err != nil {
+			return nil, err
+		}
+	}
+	if s.retryDelay != "" {
+		if policy.Delay, err = schedule.ParseDuration(s.retryDelay); err != nil {
+			return nil, fmt.Errorf("invalid retry delay: %w", err)
+		}
+	}
+	if s.retryOnExit != "" {
+		if policy.OnExitCodes, err = task.ParseExitCodes(s.retryOnExit); err != nil {
+			return nil, err
+		}
+		if len(policy.OnExitCodes) == 0 {
+			policy.OnExitCodes = nil
+		}
+	}
+
+	return policy, nil
+}
+
+// addEnv parses a KEY=VALUE pair into an environment
+func (p *parser) addEnv(env *map[string]string, value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return p.errorf("env must be KEY=VALUE, got %q", value)
+	}
+
+	if *env == nil {
+		*env = make(map[string]string)
+	}
+	(*env)[key] = unquote(strings.TrimSpace(val))
+
+	return nil
+}
+
+// splitList splits a comma separated list
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// unquote removes matching double or single quotes around a value
+func unquote(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if (first == '"' || first == '\'') && first == last {
+			return value[1 : len(value)-1]
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,174 @@
+package spec
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Can/sysrow/pkg/group"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+func TestParse(t *testing.T) {
+	input := `# deploy.sysrow
+[group deploy]
+env = STAGE=production
+env = GREETING = "hello world"
+
+[step build]
+command = make build
+timeout = 10m
+
+; comments start with # or ;
+[step test]
+command = make test
+needs = build
+priority = high
+retries = 2
+backoff = exponential
+retry-delay = 30s
+retry-on-exit = 1,75
+
+[step migrate]
+command = ./migrate.sh \
+    --verbose
+needs = test
+env = DB_TIMEOUT=60
+
+[step rollback]
+command = './rollback.sh'
+after = migrate, test
+when = failure
+
+[step notify]
+command = notify-send done
+after = migrate
+
+[group "cleanup"]
+[step tmp]
+COMMAND = rm -rf /tmp/cache
+`
+
+	groups, err := Parse(strings.NewReader(input), "deploy.sysrow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*group.Group{
+		{
+			Name: "deploy",
+			Env:  map[string]string{"STAGE": "production", "GREETING": "hello world"},
+			Steps: []group.Step{
+				{Name: "build", Command: "make build", Priority: task.PriorityNormal, Timeout: 10 * time.Minute},
+				{
+					Name:      "test",
+					Command:   "make test",
+					Priority:  task.PriorityHigh,
+					Retry:     &task.RetryPolicy{MaxRetries: 2, Backoff: task.BackoffExponential, Delay: 30 * time.Second, OnExitCodes: []int{1, 75}},
+					DependsOn: []string{"build"},
+					Condition: task.ConditionSuccess,
+				},
+				{
+					Name:      "migrate",
+					Command:   "./migrate.sh  --verbose",
+					Priority:  task.PriorityNormal,
+					Env:       map[string]string{"DB_TIMEOUT": "60"},
+					DependsOn: []string{"test"},
+					Condition: task.ConditionSuccess,
+				},
+				{
+					Name:      "rollback",
+					Command:   "./rollback.sh",
+					Priority:  task.PriorityNormal,
+					DependsOn: []string{"migrate", "test"},
+					Condition: task.ConditionFailure,
+				},
+				{
+					Name:      "notify",
+					Command:   "notify-send done",
+					Priority:  task.PriorityNormal,
+					DependsOn: []string{"migrate"},
+					Condition: task.ConditionCompletion,
+				},
+			},
+		},
+		{
+			Name:  "cleanup",
+			Steps: []group.Step{{Name: "tmp", Command: "rm -rf /tmp/cache", Priority: task.PriorityNormal}},
+		},
+	}
+
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("Parse() =\n%s\nwant\n%s", dump(groups), dump(want))
+	}
+}
+
+func TestParseDefaultRetryPolicy(t *testing.T) {
+	groups, err := Parse(strings.NewReader("[group g]\n[step s]\ncommand = true\nretries = 3\n"), "g.sysrow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &task.RetryPolicy{MaxRetries: 3, Backoff: task.BackoffFixed, Delay: task.DefaultRetryDelay}
+	if got := groups[0].Steps[0].Retry; !reflect.DeepEqual(got, want) {
+		t.Errorf("retry policy = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "# nothing here\n", "f: no groups defined"},
+		{"key outside section", "command = true\n", `f:1: "command" outside of a [group] or [step] section`},
+		{"no equals", "[group g]\njust text\n", "f:2: expected key = value"},
+		{"unterminated header", "[group g\n", "f:1: unterminated section header"},
+		{"unnamed section", "[group]\n", "f:1: section [group] needs a name"},
+		{"unknown section", "[task t]\n", "f:1: unknown section [task]"},
+		{"step outside group", "[step s]\ncommand = true\n", `f:1: step "s" is not inside a group`},
+		{"duplicate group", "[group g]\n[step s]\ncommand = a\n[group g]\n", `f:4: group "g" defined twice`},
+		{"unknown group key", "[group g]\ncommand = true\n", `f:2: unknown group key "command"`},
+		{"unknown step key", "[group g]\n[step s]\ncmd = true\n", `f:3: unknown step key "cmd"`},
+		{"no command", "[group g]\n[step s]\npriority = high\n[step t]\ncommand = true\n", `f:2: step "s" has no command`},
+		{"bad priority", "[group g]\n[step s]\npriority = urgent\n", "f:3:"},
+		{"bad timeout", "[group g]\n[step s]\ntimeout = soon\n", "f:3: invalid timeout"},
+		{"bad retries", "[group g]\n[step s]\nretries = -1\n", `f:3: invalid retries "-1"`},
+		{"bad env", "[group g]\nenv = NOVALUE\n", `f:2: env must be KEY=VALUE, got "NOVALUE"`},
+		{"retry option without retries", "[group g]\n[step s]\ncommand = true\nbackoff = fixed\n", `f:2: step "s": retry options require retries`},
+		{"bad backoff", "[group g]\n[step s]\ncommand = true\nretries = 1\nbackoff = linear\n", `f:2: step "s":`},
+		{"when without dependency", "[group g]\n[step s]\ncommand = true\nwhen = failure\n", `f:2: step "s": when requires needs or after`},
+		{"needs with when", "[group g]\n[step a]\ncommand = a\n[step b]\ncommand = b\nneeds = a\nwhen = failure\n", `f:4: step "b": needs cannot be combined with when = failure`},
+		{"continuation at end", "[group g]\n[step s]\ncommand = true \\\n", "f:3: line continuation at end of file"},
+		{"duplicate step", "[group g]\n[step s]\ncommand = a\n[step s]\ncommand = b\n", `f: group g: duplicate step "s"`},
+		{"unknown dependency", "[group g]\n[step s]\ncommand = a\nneeds = t\n", `f: group g: step "s" depends on unknown step "t"`},
+		{"step name with a space", "[group g]\n[step build all]\ncommand = make\n", `f:2: invalid step name: invalid task name "build all"`},
+		{"step name like an ID prefix", "[group g]\n[step cafe]\ncommand = make\n", `f:2: invalid step name: task name "cafe" could be mistaken for an ID prefix`},
+		{"cycle", "[group g]\n[step a]\ncommand = a\nneeds = b\n[step b]\ncommand = b\nneeds = a\n", "f: group g: dependency cycle: a -> b -> a"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.input), "f")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Parse() error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// dump formats groups with their steps for failure messages
+func dump(groups []*group.Group) string {
+	var b strings.Builder
+	for _, g := range groups {
+		fmt.Fprintf(&b, "%s env=%v\n", g.Name, g.Env)
+		for _, s := range g.Steps {
+			retry := s.Retry
+			s.Retry = nil
+			fmt.Fprintf(&b, "  %+v retry=%+v\n", s, retry)
+		}
+	}
+
+	return b.String()
+}
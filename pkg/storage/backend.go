@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/Can/sysrow/pkg/config"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// Importer is implemented by stores that can replace all of their tasks at once
+type Importer interface {
+	Import(tasks []*task.Task) error
+}
+
+// Open returns the store for a backend named in the configuration
+func Open(dataDir, backend string) (task.Store, error) {
+	switch backend {
+	case config.StorageJSON:
+		return NewStorage(dataDir), nil
+	case config.StorageLog:
+		return NewLog(dataDir), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// Migrate copies every task from one store to another, replacing what the
+// target held before, and returns the number of tasks copied
+func Migrate(from task.Store, to Importer) (int, error) {
+	tasks, err := from.ListTasks()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	if err := to.Import(tasks); err != nil {
+		return 0, fmt.Errorf("failed to import tasks: %w", err)
+	}
+
+	// Read the tasks back so a migration never loses tasks silently
+	if store, ok := to.(task.Store); ok {
+		copied, err := store.ListTasks()
+		if err != nil {
+			return 0, fmt.Errorf("failed to verify migrated tasks: %w", err)
+		}
+		if len(copied) != len(tasks) {
+			return 0, fmt.Errorf("migrated %d tasks but the target holds %d", len(tasks), len(copied))
+		}
+	}
+
+	return len(tasks), nil
+}
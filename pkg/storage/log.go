@@ -0,0 +1,636 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Can/sysrow/pkg/fsutil"
+	"github.com/Can/sysrow/pkg/lock"
+	"github.com/Can/sysrow/pkg/schema"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// compactAfter is the number of log records after which the log is folded
+// into a new snapshot, as long as the log is also larger than the snapshot
+const compactAfter = 1000
+
+// Log is a task store backed by an append-only log. Every change appends a
+// record to db/tasks.log; once the log grows large it is compacted into
+// db/tasks.snapshot. All tasks are indexed in memory by status, group and
+// creation time, so filtered queries only touch the matching tasks.
+//
+// Several processes may use the same log. Each operation takes a file lock
+// and first reads the records other processes have appended since.
+type Log struct {
+	mutex   sync.Mutex
+	dataDir string
+	dir     string
+
+	// loaded is set once the snapshot and log have been read
+	loaded bool
+	// generation is increased by every compaction, the log and snapshot
+	// files record the generation they belong to
+	generation int
+	// offset is the end of the last complete record read from the log
+	offset int64
+	// records is the number of records in the log since the last snapshot
+	records int
+
+	tasks     map[string]*logEntry
+	byStatus  idSets
+	byGroup   idSets
+	byCreated []*logEntry
+}
+
+// Log implements task.Store, task.Querier and Importer
+var (
+	_ task.Store   = (*Log)(nil)
+	_ task.Querier = (*Log)(nil)
+	_ Importer     = (*Log)(nil)
+)
+
+// logEntry is a task in memory. The task is kept encoded so that callers
+// always get their own copy.
+type logEntry struct {
+	id      string
+	status  task.TaskStatus
+	groupID string
+	created time.Time
+	data    json.RawMessage
+}
+
+// idSets maps an index key to the IDs of the tasks with that key
+type idSets map[string]map[string]struct{}
+
+// logHeader is the first line of the log and snapshot files
+type logHeader struct {
+	Generation int `json:"generation"`
+}
+
+// logRecord is a single change in the log
+type logRecord struct {
+	Op   string          `json:"op"`
+	ID   string          `json:"id"`
+	Task json.RawMessage `json:"task,omitempty"`
+}
+
+// Log record operations
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+// NewLog creates a log store in the db directory of the data directory.
+// Nothing is read until the store is first used.
+func NewLog(dataDir string) *Log {
+	return &Log{
+		dataDir: dataDir,
+		dir:     filepath.Join(dataDir, "db"),
+	}
+}
+
+// logPath returns the path of the log file
+func (l *Log) logPath() string {
+	return filepath.Join(l.dir, "tasks.log")
+}
+
+// snapshotPath returns the path of the snapshot file
+func (l *Log) snapshotPath() string {
+	return filepath.Join(l.dir, "tasks.snapshot")
+}
+
+// SaveTask appends the task to the log
+func (l *Log) SaveTask(t *task.Task) error {
+	t.SchemaVersion = schema.Version
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	return l.append(logRecord{Op: opPut, ID: t.ID, Task: data})
+}
+
+// DeleteTask appends a deletion to the log, like the other stores it fails
+// with task.ErrNotFound for a task that does not exist
+func (l *Log) DeleteTask(id string) error {
+	return l.append(logRecord{Op: opDelete, ID: id})
+}
+
+// LoadTask returns a task by its ID
+func (l *Log) LoadTask(id string) (*task.Task, error) {
+	var t *task.Task
+	err := l.read(func() error {
+		entry, ok := l.tasks[id]
+		if !ok {
+			return fmt.Errorf("%w: %s", task.ErrNotFound, id)
+		}
+
+		var err error
+		t, err = entry.decode()
+		return err
+	})
+
+	return t, err
+}
+
+// ListTasks returns all tasks ordered by creation time
+func (l *Log) ListTasks() ([]*task.Task, error) {
+	return l.FindTasks(task.Filter{})
+}
+
+// FindTasks returns the tasks selected by the filter ordered by creation
+// time. Only the tasks in the smallest matching index are looked at.
+func (l *Log) FindTasks(f task.Filter) ([]*task.Task, error) {
+	var tasks []*task.Task
+	err := l.read(func() error {
+		candidates := l.candidates(f)
+
+		tasks = make([]*task.Task, 0, len(candidates))
+		for _, entry := range candidates {
+			if !entry.matches(f) {
+				continue
+			}
+
+			t, err := entry.decode()
+			if err != nil {
+				return err
+			}
+			tasks = append(tasks, t)
+		}
+
+		return nil
+	})
+
+	return tasks, err
+}
+
+// candidates returns the entries of the smallest index the filter can use,
+// ordered by creation time
+func (l *Log) candidates(f task.Filter) []*logEntry {
+	// Tasks created since the given time, found by binary search
+	first := 0
+	if !f.Since.IsZero() {
+		first = sort.Search(len(l.byCreated), func(i int) bool {
+			return !l.byCreated[i].created.Before(f.Since)
+		})
+	}
+	best := l.byCreated[first:]
+
+	// The group or status index may hold fewer tasks
+	if f.GroupID != "" {
+		if set := l.byGroup[f.GroupID]; len(set) < len(best) {
+			best = l.entries(set)
+		}
+	}
+	if len(f.Statuses) > 0 {
+		merged := make(map[string]struct{})
+		for _, status := range f.Statuses {
+			for id := range l.byStatus[string(status)] {
+				merged[id] = struct{}{}
+			}
+		}
+		if len(merged) < len(best) {
+			best = l.entries(merged)
+		}
+	}
+
+	return best
+}
+
+// entries returns the entries of a set of IDs ordered by creation time
+func (l *Log) entries(ids map[string]struct{}) []*logEntry {
+	entries := make([]*logEntry, 0, len(ids))
+	for id := range ids {
+		entries = append(entries, l.tasks[id])
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].before(entries[j])
+	})
+
+	return entries
+}
+
+// read runs fn with the in-memory state brought up to date
+func (l *Log) read(fn func() error) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	fileLock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer fileLock.Release()
+
+	if err := l.refresh(); err != nil {
+		return err
+	}
+
+	return fn()
+}
+
+// append writes a record to the log and applies it
+func (l *Log) append(rec logRecord) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	fileLock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer fileLock.Release()
+
+	if err := l.refresh(); err != nil {
+		return err
+	}
+
+	if _, ok := l.tasks[rec.ID]; !ok && rec.Op == opDelete {
+		return fmt.Errorf("%w: %s", task.ErrNotFound, rec.ID)
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal log record: %w", err)
+	}
+	line = append(line, '\n')
+
+	f, err := os.OpenFile(l.logPath(), os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open task log: %w", err)
+	}
+	defer f.Close()
+
+	// Drop the partial record of a write that was interrupted by a crash
+	if err := f.Truncate(l.offset); err != nil {
+		return fmt.Errorf("failed to truncate task log: %w", err)
+	}
+	if _, err := f.WriteAt(line, l.offset); err != nil {
+		return fmt.Errorf("failed to write task log: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync task log: %w", err)
+	}
+
+	l.offset += int64(len(line))
+	l.records++
+	if err := l.apply(rec); err != nil {
+		return err
+	}
+
+	if l.records >= compactAfter && l.records > len(l.tasks) {
+		return l.compact()
+	}
+
+	return nil
+}
+
+// lock takes the file lock shared by every process using the log
+func (l *Log) lock() (*lock.Lock, error) {
+	fileLock, err := lock.Acquire(filepath.Join(lock.Dir(l.dataDir), "db.lock"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock task log: %w", err)
+	}
+
+	return fileLock, nil
+}
+
+// refresh reads what other processes have written since the last call.
+// After a compaction by another process everything is read again.
+func (l *Log) refresh() error {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create db directory: %w", err)
+	}
+
+	f, err := os.Open(l.logPath())
+	if os.IsNotExist(err) {
+		// A new store, or one that was migrated with a snapshot only
+		if err := l.reset(); err != nil {
+			return err
+		}
+		return l.writeLogHeader()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open task log: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	header, headerSize, err := readHeader(reader)
+	if err != nil {
+		return fmt.Errorf("task log %s: %w", l.logPath(), err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat task log: %w", err)
+	}
+
+	if !l.loaded || header.Generation != l.generation || info.Size() < l.offset {
+		if err := l.reset(); err != nil {
+			return err
+		}
+
+		// A compaction stopped after the snapshot was written, the records
+		// of the old log are already part of the snapshot
+		if header.Generation < l.generation {
+			return l.writeLogHeader()
+		}
+
+		l.offset = headerSize
+		return l.replay(reader)
+	}
+
+	if _, err := f.Seek(l.offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek task log: %w", err)
+	}
+	reader.Reset(f)
+
+	return l.replay(reader)
+}
+
+// replay applies the complete records of the log from the current offset
+func (l *Log) replay(reader *bufio.Reader) error {
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A record without a newline was not written completely
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read task log: %w", err)
+		}
+
+		l.offset += int64(len(line))
+		l.records++
+
+		var rec logRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading task log record at offset %d: %v\n", l.offset-int64(len(line)), err)
+			continue
+		}
+		if err := l.apply(rec); err != nil {
+			fmt.Fprintf(os.Stderr, "Error applying task log record for %s: %v\n", rec.ID, err)
+		}
+	}
+}
+
+// clear empties the in-memory state
+func (l *Log) clear() {
+	l.tasks = make(map[string]*logEntry)
+	l.byStatus = make(idSets)
+	l.byGroup = make(idSets)
+	l.byCreated = make([]*logEntry, 0)
+}
+
+// reset clears the in-memory state and loads the snapshot
+func (l *Log) reset() error {
+	l.clear()
+	l.generation = 0
+	l.offset = 0
+	l.records = 0
+	l.loaded = true
+
+	f, err := os.Open(l.snapshotPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open task snapshot: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	header, _, err := readHeader(reader)
+	if err != nil {
+		return fmt.Errorf("task snapshot %s: %w", l.snapshotPath(), err)
+	}
+	l.generation = header.Generation
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read task snapshot: %w", err)
+		}
+
+		var t struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(line, &t); err != nil {
+			return fmt.Errorf("task snapshot %s is corrupt: %w", l.snapshotPath(), err)
+		}
+		if err := l.apply(logRecord{Op: opPut, ID: t.ID, Task: bytes.TrimSpace(line)}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply changes the in-memory state and indexes for a record
+func (l *Log) apply(rec logRecord) error {
+	if old, ok := l.tasks[rec.ID]; ok {
+		l.unindex(old)
+	}
+
+	if rec.Op == opDelete {
+		return nil
+	}
+
+	var t task.Task
+	if err := json.Unmarshal(rec.Task, &t); err != nil {
+		return fmt.Errorf("failed to unmarshal task: %w", err)
+	}
+
+	entry := &logEntry{
+		id:      rec.ID,
+		status:  t.Status,
+		created: t.CreatedAt,
+		data:    rec.Task,
+	}
+	if t.GroupID != nil {
+		entry.groupID = *t.GroupID
+	}
+	l.index(entry)
+
+	return nil
+}
+
+// index adds an entry to the store and its indexes
+func (l *Log) index(entry *logEntry) {
+	l.tasks[entry.id] = entry
+	l.byStatus.add(string(entry.status), entry.id)
+	if entry.groupID != "" {
+		l.byGroup.add(entry.groupID, entry.id)
+	}
+
+	i := sort.Search(len(l.byCreated), func(i int) bool {
+		return entry.before(l.byCreated[i])
+	})
+	l.byCreated = append(l.byCreated, nil)
+	copy(l.byCreated[i+1:], l.byCreated[i:])
+	l.byCreated[i] = entry
+}
+
+// unindex removes an entry from the store and its indexes
+func (l *Log) unindex(entry *logEntry) {
+	delete(l.tasks, entry.id)
+	l.byStatus.remove(string(entry.status), entry.id)
+	if entry.groupID != "" {
+		l.byGroup.remove(entry.groupID, entry.id)
+	}
+
+	i := sort.Search(len(l.byCreated), func(i int) bool {
+		return !l.byCreated[i].before(entry)
+	})
+	if i < len(l.byCreated) && l.byCreated[i].id == entry.id {
+		l.byCreated = append(l.byCreated[:i], l.byCreated[i+1:]...)
+	}
+}
+
+// compact writes the current state as a new snapshot and starts an empty log
+func (l *Log) compact() error {
+	var buf bytes.Buffer
+	header, err := json.Marshal(logHeader{Generation: l.generation + 1})
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot header: %w", err)
+	}
+	buf.Write(header)
+	buf.WriteByte('\n')
+	for _, entry := range l.byCreated {
+		buf.Write(entry.data)
+		buf.WriteByte('\n')
+	}
+
+	// Once the snapshot is in place the old log is redundant
+	if err := fsutil.WriteFile(l.snapshotPath(), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write task snapshot: %w", err)
+	}
+	l.generation++
+
+	return l.writeLogHeader()
+}
+
+// writeLogHeader replaces the log with an empty one for the current generation
+func (l *Log) writeLogHeader() error {
+	header, err := json.Marshal(logHeader{Generation: l.generation})
+	if err != nil {
+		return fmt.Errorf("failed to marshal log header: %w", err)
+	}
+	header = append(header, '\n')
+
+	if err := fsutil.WriteFile(l.logPath(), header, 0644); err != nil {
+		return fmt.Errorf("failed to write task log: %w", err)
+	}
+	l.offset = int64(len(header))
+	l.records = 0
+
+	return nil
+}
+
+// Import replaces the contents of the store with the given tasks. They are
+// written as a single snapshot, which is much faster than one record each.
+func (l *Log) Import(tasks []*task.Task) (err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	fileLock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer fileLock.Release()
+
+	// Learn the current generation so the snapshot supersedes the log
+	if err := l.refresh(); err != nil {
+		return err
+	}
+
+	// Read everything again on the next call if the import fails halfway
+	defer func() {
+		if err != nil {
+			l.loaded = false
+		}
+	}()
+
+	l.clear()
+	for _, t := range tasks {
+		t.SchemaVersion = schema.Version
+		data, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Errorf("failed to marshal task: %w", err)
+		}
+		if err := l.apply(logRecord{Op: opPut, ID: t.ID, Task: data}); err != nil {
+			return err
+		}
+	}
+
+	return l.compact()
+}
+
+// decode returns a copy of the task
+func (e *logEntry) decode() (*task.Task, error) {
+	return decode(e.data)
+}
+
+// matches checks the filter against the indexed fields of the entry
+func (e *logEntry) matches(f task.Filter) bool {
+	t := task.Task{Status: e.status, CreatedAt: e.created}
+	if e.groupID != "" {
+		t.GroupID = &e.groupID
+	}
+
+	return f.Match(&t)
+}
+
+// before orders entries by creation time, then by ID
+func (e *logEntry) before(other *logEntry) bool {
+	if !e.created.Equal(other.created) {
+		return e.created.Before(other.created)
+	}
+
+	return e.id < other.id
+}
+
+// readHeader reads the header line of a log or snapshot file and returns it
+// together with its size in bytes
+func readHeader(reader *bufio.Reader) (logHeader, int64, error) {
+	var header logHeader
+
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return header, 0, fmt.Errorf("missing header: %w", err)
+	}
+	if err := json.Unmarshal(line, &header); err != nil {
+		return header, 0, fmt.Errorf("invalid header: %w", err)
+	}
+
+	return header, int64(len(line)), nil
+}
+
+// add adds an ID to the set stored under key
+func (s idSets) add(key, id string) {
+	set, ok := s[key]
+	if !ok {
+		set = make(map[string]struct{})
+		s[key] = set
+	}
+	set[id] = struct{}{}
+}
+
+// remove removes an ID from the set stored under key
+func (s idSets) remove(key, id string) {
+	set := s[key]
+	delete(set, id)
+	if len(set) == 0 {
+		delete(s, key)
+	}
+}
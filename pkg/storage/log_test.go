@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Can/sysrow/pkg/task"
+)
+
+func TestLogSharedBetweenProcesses(t *testing.T) {
+	dataDir := t.TempDir()
+	first, second := NewLog(dataDir), NewLog(dataDir)
+
+	if err := first.SaveTask(fullTask("a")); err != nil {
+		t.Fatal(err)
+	}
+	if got := listIDs(t, second); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("second store sees %v, want [a]", got)
+	}
+
+	// Changes of the second store reach the first one, which has read before
+	changed := fullTask("a")
+	changed.Status = task.StatusCompleted
+	if err := second.SaveTask(changed); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.SaveTask(fullTask("b")); err != nil {
+		t.Fatal(err)
+	}
+	found, err := task.FindTasks(first, task.Filter{Statuses: []task.TaskStatus{task.StatusCompleted}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(found); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("first store finds completed tasks %v, want [a]", got)
+	}
+
+	if err := first.DeleteTask("b"); err != nil {
+		t.Fatal(err)
+	}
+	if got := listIDs(t, second); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("second store sees %v after a delete, want [a]", got)
+	}
+}
+
+func TestLogCompaction(t *testing.T) {
+	dataDir := t.TempDir()
+	store, other := NewLog(dataDir), NewLog(dataDir)
+
+	// The other store has read the log before it is compacted
+	if err := store.SaveTask(fullTask("a")); err != nil {
+		t.Fatal(err)
+	}
+	if got := listIDs(t, other); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Fatalf("other store sees %v, want [a]", got)
+	}
+
+	// Saving the same tasks over and over makes the log larger than the state
+	for i := 0; i < compactAfter; i++ {
+		exitCode := i
+		changed := fullTask([]string{"a", "b"}[i%2])
+		changed.ExitCode = &exitCode
+		if err := store.SaveTask(changed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if store.generation != 1 {
+		t.Errorf("generation = %d after %d records, want 1", store.generation, compactAfter+1)
+	}
+	if store.records >= compactAfter {
+		t.Errorf("log still holds %d records", store.records)
+	}
+	if got := snapshotIDs(t, store.snapshotPath()); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("snapshot holds %v, want [a b]", got)
+	}
+
+	for _, s := range []*Log{store, other, NewLog(dataDir)} {
+		loaded, err := s.LoadTask("b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *loaded.ExitCode != compactAfter-1 {
+			t.Errorf("exit code of b = %d, want the last saved %d", *loaded.ExitCode, compactAfter-1)
+		}
+		if got := listIDs(t, s); !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Errorf("store sees %v after compaction, want [a b]", got)
+		}
+	}
+}
+
+func TestLogInterruptedCompaction(t *testing.T) {
+	dataDir := t.TempDir()
+	store := NewLog(dataDir)
+
+	if err := store.SaveTask(fullTask("old")); err != nil {
+		t.Fatal(err)
+	}
+	oldLog, err := os.ReadFile(store.logPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Import([]*task.Task{fullTask("new")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash after the snapshot was written leaves the log of the previous
+	// generation behind, whose records the snapshot already contains
+	if err := os.WriteFile(store.logPath(), oldLog, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewLog(dataDir)
+	if got := listIDs(t, reopened); !reflect.DeepEqual(got, []string{"new"}) {
+		t.Errorf("store sees %v, want only the snapshot [new]", got)
+	}
+	if err := reopened.SaveTask(fullTask("next")); err != nil {
+		t.Fatal(err)
+	}
+	if got := listIDs(t, NewLog(dataDir)); !reflect.DeepEqual(got, []string{"new", "next"}) {
+		t.Errorf("store sees %v, want [new next]", got)
+	}
+}
+
+func TestLogTornTailRecord(t *testing.T) {
+	dataDir := t.TempDir()
+	store := NewLog(dataDir)
+
+	if err := store.SaveTask(fullTask("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A process crashed in the middle of appending a record
+	f, err := os.OpenFile(store.logPath(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"op":"put","id":"torn","task":{"id":"to`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	reopened := NewLog(dataDir)
+	if got := listIDs(t, reopened); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("store sees %v, want the torn record to be ignored", got)
+	}
+
+	// The next write replaces the torn record
+	if err := reopened.SaveTask(fullTask("b")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(store.logPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("torn")) {
+		t.Errorf("log still holds the torn record:\n%s", data)
+	}
+	for i, line := range bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) {
+		if !json.Valid(line) {
+			t.Errorf("line %d of the log is not valid JSON: %s", i+1, line)
+		}
+	}
+
+	for _, s := range []*Log{store, NewLog(dataDir)} {
+		if got := listIDs(t, s); !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Errorf("store sees %v, want [a b]", got)
+		}
+	}
+}
+
+func TestLogImportReplacesTasks(t *testing.T) {
+	dataDir := t.TempDir()
+	store, other := NewLog(dataDir), NewLog(dataDir)
+
+	for _, id := range []string{"a", "b"} {
+		if err := store.SaveTask(fullTask(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := listIDs(t, other); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("other store sees %v, want [a b]", got)
+	}
+
+	if err := store.Import([]*task.Task{fullTask("b"), fullTask("c")}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []*Log{store, other, NewLog(dataDir)} {
+		if got := listIDs(t, s); !reflect.DeepEqual(got, []string{"b", "c"}) {
+			t.Errorf("store sees %v after an import, want [b c]", got)
+		}
+	}
+}
+
+// snapshotIDs returns the sorted IDs of the tasks in a snapshot file
+func snapshotIDs(t *testing.T, path string) []string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	if _, _, err := readHeader(reader); err != nil {
+		t.Fatal(err)
+	}
+
+	var tasks []*task.Task
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var st task.Task
+		if err := json.Unmarshal(scanner.Bytes(), &st); err != nil {
+			t.Fatal(err)
+		}
+		tasks = append(tasks, &st)
+	}
+
+	return ids(tasks)
+}
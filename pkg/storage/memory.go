@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/Can/sysrow/pkg/schema"
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// Memory is a task store that keeps tasks in memory only. Tasks are stored
+// as JSON, like on disk, so callers never share a task with the store and
+// fields that would not survive the file store do not survive here either.
+type Memory struct {
+	mutex sync.RWMutex
+	tasks map[string][]byte
+}
+
+// Memory implements task.Store
+var _ task.Store = (*Memory)(nil)
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		tasks: make(map[string][]byte),
+	}
+}
+
+// SaveTask stores a copy of the task
+func (m *Memory) SaveTask(t *task.Task) error {
+	t.SchemaVersion = schema.Version
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.tasks[t.ID] = data
+	return nil
+}
+
+// LoadTask returns a copy of a stored task
+func (m *Memory) LoadTask(id string) (*task.Task, error) {
+	m.mutex.RLock()
+	data, ok := m.tasks[id]
+	m.mutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+
+	return decode(data)
+}
+
+// ListTasks returns copies of all stored tasks
+func (m *Memory) ListTasks() ([]*task.Task, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	tasks := make([]*task.Task, 0, len(m.tasks))
+	for _, data := range m.tasks {
+		t, err := decode(data)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+
+	return tasks, nil
+}
+
+// DeleteTask removes a task
+func (m *Memory) DeleteTask(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.tasks[id]; !ok {
+		return fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	delete(m.tasks, id)
+
+	return nil
+}
+
+// decode turns a stored task back into a task
+func decode(data []byte) (*task.Task, error) {
+	var t task.Task
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+	}
+	if err := schema.CheckRecord("task", t.ID, t.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
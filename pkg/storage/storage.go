@@ -6,30 +6,24 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
-	"time"
 
+	"github.com/Can/sysrow/pkg/fsutil"
+	"github.com/Can/sysrow/pkg/schema"
 	"github.com/Can/sysrow/pkg/task"
 )
 
-// Storage handles task persistence
+// Storage is the default task store. Every task is kept as a JSON file
+// named after its ID in the tasks directory of the data directory.
 type Storage struct {
 	mutex   sync.Mutex
 	dataDir string
 }
 
-// TaskMetadata contains metadata about a task
-type TaskMetadata struct {
-	ID          string         `json:"id"`
-	Command     string         `json:"command"`
-	Status      task.TaskStatus `json:"status"`
-	CreatedAt   time.Time      `json:"created_at"`
-	ScheduledAt *time.Time     `json:"scheduled_at,omitempty"`
-	StartedAt   *time.Time     `json:"started_at,omitempty"`
-	FinishedAt  *time.Time     `json:"finished_at,omitempty"`
-	ExitCode    *int           `json:"exit_code,omitempty"`
-	PID         *int           `json:"pid,omitempty"`
-	GroupID     *string        `json:"group_id,omitempty"`
-}
+// Storage implements task.Store and Importer
+var (
+	_ task.Store = (*Storage)(nil)
+	_ Importer   = (*Storage)(nil)
+)
 
 // NewStorage creates a new storage manager
 func NewStorage(dataDir string) *Storage {
@@ -38,82 +32,66 @@ func NewStorage(dataDir string) *Storage {
 	}
 }
 
-// SaveTask saves a task's metadata to disk
+// tasksDir returns the directory that holds the task files
+func (s *Storage) tasksDir() string {
+	return filepath.Join(s.dataDir, "tasks")
+}
+
+// SaveTask writes a task to disk
 func (s *Storage) SaveTask(t *task.Task) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	// Create the tasks directory if it doesn't exist
-	tasksDir := filepath.Join(s.dataDir, "tasks")
-	if err := os.MkdirAll(tasksDir, 0755); err != nil {
+	if err := os.MkdirAll(s.tasksDir(), 0755); err != nil {
 		return fmt.Errorf("failed to create tasks directory: %w", err)
 	}
 
-	// Create task metadata
-	metadata := TaskMetadata{
-		ID:          t.ID,
-		Command:     t.Command,
-		Status:      t.Status,
-		CreatedAt:   t.CreatedAt,
-		ScheduledAt: t.ScheduledAt,
-		StartedAt:   t.StartedAt,
-		FinishedAt:  t.FinishedAt,
-		ExitCode:    t.ExitCode,
-		PID:         t.PID,
-		GroupID:     t.GroupID,
-	}
-
-	// Marshal the metadata to JSON
-	metadataData, err := json.MarshalIndent(metadata, "", "  ")
+	// Marshal the whole task so every field round-trips
+	t.SchemaVersion = schema.Version
+	taskData, err := json.MarshalIndent(t, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal task metadata: %w", err)
+		return fmt.Errorf("failed to marshal task: %w", err)
 	}
 
-	// Write the metadata to file
-	metadataPath := filepath.Join(tasksDir, t.ID+".json")
-	if err := os.WriteFile(metadataPath, metadataData, 0644); err != nil {
-		return fmt.Errorf("failed to write task metadata file: %w", err)
+	// Write the task data to file
+	taskPath := filepath.Join(s.tasksDir(), t.ID+".json")
+	if err := fsutil.WriteFile(taskPath, taskData, 0644); err != nil {
+		return fmt.Errorf("failed to write task file: %w", err)
 	}
 
 	return nil
 }
 
-// LoadTask loads a task's metadata from disk
+// LoadTask loads a task from disk by its ID
 func (s *Storage) LoadTask(id string) (*task.Task, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// Define the metadata file path
-	metadataPath := filepath.Join(s.dataDir, "tasks", id+".json")
+	return s.load(id)
+}
 
-	// Read the metadata file
-	metadataData, err := os.ReadFile(metadataPath)
+// load reads a task file, the caller holds the mutex
+func (s *Storage) load(id string) (*task.Task, error) {
+	// Read the task file
+	taskData, err := os.ReadFile(filepath.Join(s.tasksDir(), id+".json"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read task metadata file: %w", err)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", task.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to read task file: %w", err)
 	}
 
-	// Unmarshal the metadata
-	var metadata TaskMetadata
-	if err := json.Unmarshal(metadataData, &metadata); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal task metadata: %w", err)
+	// Unmarshal the task data
+	var t task.Task
+	if err := json.Unmarshal(taskData, &t); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task: %w", err)
 	}
-
-	// Create a task from the metadata
-	t := &task.Task{
-		ID:          metadata.ID,
-		Command:     metadata.Command,
-		Status:      metadata.Status,
-		Priority:    task.PriorityNormal, // Default priority
-		CreatedAt:   metadata.CreatedAt,
-		ScheduledAt: metadata.ScheduledAt,
-		StartedAt:   metadata.StartedAt,
-		FinishedAt:  metadata.FinishedAt,
-		ExitCode:    metadata.ExitCode,
-		PID:         metadata.PID,
-		GroupID:     metadata.GroupID,
+	if err := schema.CheckRecord("task", id, t.SchemaVersion); err != nil {
+		return nil, err
 	}
 
-	return t, nil
+	return &t, nil
 }
 
 // ListTasks returns all tasks
@@ -121,16 +99,13 @@ func (s *Storage) ListTasks() ([]*task.Task, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// Define the tasks directory path
-	tasksDir := filepath.Join(s.dataDir, "tasks")
-
 	// Create the tasks directory if it doesn't exist
-	if err := os.MkdirAll(tasksDir, 0755); err != nil {
+	if err := os.MkdirAll(s.tasksDir(), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create tasks directory: %w", err)
 	}
 
 	// Read the tasks directory
-	files, err := os.ReadDir(tasksDir)
+	files, err := os.ReadDir(s.tasksDir())
 	if err != nil {
 		return nil, fmt.Errorf("failed to read tasks directory: %w", err)
 	}
@@ -146,84 +121,62 @@ func (s *Storage) ListTasks() ([]*task.Task, error) {
 		taskID := file.Name()[:len(file.Name())-5] // Remove .json extension
 
 		// Load the task
-		task, err := s.LoadTask(taskID)
+		t, err := s.load(taskID)
 		if err != nil {
 			// Log the error but continue loading other tasks
-			fmt.Fprintf(os.Stderr, "Error loading task %s: %v\n", taskID, err)
+			fmt.Fprintf(os.Stderr, "Error loading task %s: %v (sysrow doctor moves corrupt files aside)\n", taskID, err)
 			continue
 		}
 
-		tasks = append(tasks, task)
+		tasks = append(tasks, t)
 	}
 
 	return tasks, nil
 }
 
-// DeleteTask deletes a task's metadata from disk
+// DeleteTask deletes a task file from disk
 func (s *Storage) DeleteTask(id string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// Define the metadata file path
-	metadataPath := filepath.Join(s.dataDir, "tasks", id+".json")
-
-	// Delete the metadata file
-	if err := os.Remove(metadataPath); err != nil {
-		return fmt.Errorf("failed to delete task metadata file: %w", err)
+	// Delete the task file
+	if err := os.Remove(filepath.Join(s.tasksDir(), id+".json")); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", task.ErrNotFound, id)
+		}
+		return fmt.Errorf("failed to delete task file: %w", err)
 	}
 
 	return nil
 }
 
-// CleanupOldTasks removes tasks that are older than the specified duration
-func (s *Storage) CleanupOldTasks(age time.Duration) error {
+// Import replaces all task files with the given tasks
+func (s *Storage) Import(tasks []*task.Task) error {
+	keep := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		if err := s.SaveTask(t); err != nil {
+			return err
+		}
+		keep[t.ID+".json"] = true
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// List all tasks
-	tasks, err := s.ListTasks()
+	// Remove the tasks that are not part of the import
+	files, err := os.ReadDir(s.tasksDir())
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to list tasks: %w", err)
+		return fmt.Errorf("failed to read tasks directory: %w", err)
 	}
-
-	// Get the current time
-	now := time.Now()
-
-	// Delete old tasks
-	for _, t := range tasks {
-		// Skip tasks that are still running
-		if t.Status == task.StatusRunning {
-			continue
-		}
-
-		// Skip tasks that don't have a finish time
-		if t.FinishedAt == nil {
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".json" || keep[file.Name()] {
 			continue
 		}
-
-		// Check if the task is older than the specified age
-		if now.Sub(*t.FinishedAt) > age {
-			// Delete the task
-			if err := s.DeleteTask(t.ID); err != nil {
-				fmt.Fprintf(os.Stderr, "Error deleting task %s: %v\n", t.ID, err)
-				continue
-			}
-
-			// Delete the task logs
-			logsDir := filepath.Join(s.dataDir, "logs")
-			logFiles := []string{
-				filepath.Join(logsDir, t.ID+".stdout.log"),
-				filepath.Join(logsDir, t.ID+".stderr.log"),
-				filepath.Join(logsDir, t.ID+".app.log"),
-			}
-
-			for _, logFile := range logFiles {
-				if _, err := os.Stat(logFile); err == nil {
-					if err := os.Remove(logFile); err != nil {
-						fmt.Fprintf(os.Stderr, "Error deleting log file %s: %v\n", logFile, err)
-					}
-				}
-			}
+		if err := os.Remove(filepath.Join(s.tasksDir(), file.Name())); err != nil {
+			return fmt.Errorf("failed to delete task file: %w", err)
 		}
 	}
 
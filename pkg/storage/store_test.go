@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Can/sysrow/pkg/task"
+)
+
+// backend creates a store and, for stores that persist, a second instance
+// on the same data, as another sysrow process would
+type backend struct {
+	name   string
+	open   func(dataDir string) task.Store
+	shared bool
+}
+
+var backends = []backend{
+	{"memory", func(string) task.Store { return NewMemory() }, false},
+	{"json", func(dataDir string) task.Store { return NewStorage(dataDir) }, true},
+	{"log", func(dataDir string) task.Store { return NewLog(dataDir) }, true},
+}
+
+// forEachBackend runs the test against every store
+func forEachBackend(t *testing.T, test func(t *testing.T, b backend, dataDir string, store task.Store)) {
+	for _, b := range backends {
+		b := b
+		t.Run(b.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			test(t, b, dataDir, b.open(dataDir))
+		})
+	}
+}
+
+// fullTask returns a task with every field set
+func fullTask(id string) *task.Task {
+	at := func(minute int) *time.Time {
+		t := time.Date(2026, 10, 16, 12, minute, 0, 0, time.UTC)
+		return &t
+	}
+	exitCode, pid, slot := 3, 4242, 2
+	pidStart := uint64(987654)
+	groupID, runID, recurringID := "group-1", "run-1", "recurring-1"
+
+	return &task.Task{
+		ID:          id,
+		Command:     "echo 'hello world'",
+		Status:      task.StatusFailed,
+		Priority:    task.PriorityHigh,
+		CreatedAt:   *at(0),
+		ScheduledAt: at(1),
+		StartedAt:   at(2),
+		FinishedAt:  at(3),
+		ExitCode:    &exitCode,
+		PID:         &pid,
+		PIDStart:    &pidStart,
+		GroupID:     &groupID,
+		RunID:       &runID,
+		Timeout:     90 * time.Second,
+		Env:         map[string]string{"STAGE": "production"},
+		Retry: &task.RetryPolicy{
+			MaxRetries:  2,
+			Backoff:     task.BackoffExponential,
+			Delay:       30 * time.Second,
+			OnExitCodes: []int{1, 75},
+		},
+		Attempts: []task.Attempt{{
+			Number:     1,
+			Status:     task.StatusFailed,
+			StartedAt:  *at(2),
+			FinishedAt: *at(3),
+			ExitCode:   &exitCode,
+			Signal:     "SIGTERM",
+			StdoutLog:  id + ".attempt-1.stdout.log",
+			StderrLog:  id + ".attempt-1.stderr.log",
+		}},
+		DependsOn:     []string{"parent-1", "parent-2"},
+		Condition:     task.ConditionFailure,
+		Slot:          &slot,
+		RecurringID:   &recurringID,
+		Signal:        "SIGTERM",
+		Manual:        true,
+		Queue:         "backups",
+		Name:          "nightly-backup",
+		SchemaVersion: 1,
+	}
+}
+
+func TestFullTaskSetsEveryField(t *testing.T) {
+	// New fields of task.Task must be added to fullTask so they are round-tripped
+	v := reflect.ValueOf(*fullTask("a"))
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).IsZero() {
+			t.Errorf("fullTask does not set %s", v.Type().Field(i).Name)
+		}
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend, dataDir string, store task.Store) {
+		saved := fullTask("a")
+		if err := store.SaveTask(saved); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := store.LoadTask("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded, saved) {
+			t.Errorf("LoadTask() = %+v, want %+v", loaded, saved)
+		}
+
+		// Callers get their own copy
+		loaded.Status = task.StatusCompleted
+		loaded.Env["STAGE"] = "changed"
+		again, err := store.LoadTask("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(again, saved) {
+			t.Errorf("changing a loaded task changed the store: %+v", again)
+		}
+
+		if !b.shared {
+			return
+		}
+		reopened, err := b.open(dataDir).LoadTask("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(reopened, saved) {
+			t.Errorf("LoadTask() from a new store = %+v, want %+v", reopened, saved)
+		}
+	})
+}
+
+func TestStoreReplaceAndDelete(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend, dataDir string, store task.Store) {
+		for _, id := range []string{"a", "b"} {
+			if err := store.SaveTask(fullTask(id)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		replaced := fullTask("a")
+		replaced.Status = task.StatusCompleted
+		replaced.ExitCode = nil
+		if err := store.SaveTask(replaced); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := listIDs(t, store); !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Errorf("ListTasks() = %v, want [a b]", got)
+		}
+		loaded, err := store.LoadTask("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.Status != task.StatusCompleted || loaded.ExitCode != nil {
+			t.Errorf("replaced task has status %s and exit code %v", loaded.Status, loaded.ExitCode)
+		}
+
+		if err := store.DeleteTask("a"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.LoadTask("a"); !errors.Is(err, task.ErrNotFound) {
+			t.Errorf("LoadTask() of a deleted task error = %v, want ErrNotFound", err)
+		}
+		if err := store.DeleteTask("a"); !errors.Is(err, task.ErrNotFound) {
+			t.Errorf("DeleteTask() of a deleted task error = %v, want ErrNotFound", err)
+		}
+		if got := listIDs(t, store); !reflect.DeepEqual(got, []string{"b"}) {
+			t.Errorf("ListTasks() = %v, want [b]", got)
+		}
+
+		if b.shared {
+			if got := listIDs(t, b.open(dataDir)); !reflect.DeepEqual(got, []string{"b"}) {
+				t.Errorf("ListTasks() of a new store = %v, want [b]", got)
+			}
+		}
+	})
+}
+
+func TestStoreNotFound(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend, dataDir string, store task.Store) {
+		if _, err := store.LoadTask("missing"); !errors.Is(err, task.ErrNotFound) {
+			t.Errorf("LoadTask() error = %v, want ErrNotFound", err)
+		}
+		if err := store.DeleteTask("missing"); !errors.Is(err, task.ErrNotFound) {
+			t.Errorf("DeleteTask() error = %v, want ErrNotFound", err)
+		}
+		if got := listIDs(t, store); len(got) != 0 {
+			t.Errorf("ListTasks() of an empty store = %v", got)
+		}
+	})
+}
+
+func TestFindTasks(t *testing.T) {
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	group1, group2 := "g1", "g2"
+
+	tasks := []struct {
+		id      string
+		status  task.TaskStatus
+		groupID *string
+		minute  int
+	}{
+		{"a", task.StatusCompleted, nil, 0},
+		{"b", task.StatusFailed, &group1, 1},
+		{"c", task.StatusPending, &group1, 2},
+		{"d", task.StatusFailed, &group2, 3},
+		{"e", task.StatusRunning, nil, 4},
+	}
+
+	tests := []struct {
+		name   string
+		filter task.Filter
+		want   []string
+	}{
+		{"all", task.Filter{}, []string{"a", "b", "c", "d", "e"}},
+		{"status", task.Filter{Statuses: []task.TaskStatus{task.StatusFailed}}, []string{"b", "d"}},
+		{"statuses", task.Filter{Statuses: []task.TaskStatus{task.StatusPending, task.StatusRunning}}, []string{"c", "e"}},
+		{"unused status", task.Filter{Statuses: []task.TaskStatus{task.StatusLost}}, []string{}},
+		{"group", task.Filter{GroupID: "g1"}, []string{"b", "c"}},
+		{"unknown group", task.Filter{GroupID: "g3"}, []string{}},
+		{"since", task.Filter{Since: base.Add(3 * time.Minute)}, []string{"d", "e"}},
+		{"since all", task.Filter{Since: base.Add(-time.Hour)}, []string{"a", "b", "c", "d", "e"}},
+		{"since none", task.Filter{Since: base.Add(time.Hour)}, []string{}},
+		{"group and status", task.Filter{GroupID: "g1", Statuses: []task.TaskStatus{task.StatusFailed}}, []string{"b"}},
+		{"status and since", task.Filter{Statuses: []task.TaskStatus{task.StatusFailed}, Since: base.Add(2 * time.Minute)}, []string{"d"}},
+	}
+
+	forEachBackend(t, func(t *testing.T, b backend, dataDir string, store task.Store) {
+		for _, tt := range tasks {
+			created := fullTask(tt.id)
+			created.Status = tt.status
+			created.GroupID = tt.groupID
+			created.CreatedAt = base.Add(time.Duration(tt.minute) * time.Minute)
+			if err := store.SaveTask(created); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// A task moved to another status and group must leave the old indexes
+		moved := fullTask("c")
+		moved.Status = task.StatusCompleted
+		moved.GroupID = &group2
+		moved.CreatedAt = base.Add(2 * time.Minute)
+		if err := store.SaveTask(moved); err != nil {
+			t.Fatal(err)
+		}
+		moved.Status = task.StatusPending
+		moved.GroupID = &group1
+		if err := store.SaveTask(moved); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range tests {
+			found, err := task.FindTasks(store, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(found); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: FindTasks() = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	})
+}
+
+// listIDs returns the sorted IDs of all tasks of a store
+func listIDs(t *testing.T, store task.Store) []string {
+	t.Helper()
+
+	tasks, err := store.ListTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ids(tasks)
+}
+
+// ids returns the sorted IDs of tasks
+func ids(tasks []*task.Task) []string {
+	result := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		result = append(result, t.ID)
+	}
+	sort.Strings(result)
+
+	return result
+}
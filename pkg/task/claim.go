@@ -0,0 +1,127 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Can/sysrow/pkg/lock"
+)
+
+var (
+	// ErrClaimed is returned by Claim when another executor owns the task
+	ErrClaimed = errors.New("task has been claimed by another executor")
+	// ErrNotPending is returned by Claim when the task cannot be started anymore
+	ErrNotPending = errors.New("task is no longer pending")
+)
+
+// Claim is the right to execute a task. While it is held no other executor,
+// in this or another process, can claim the same task.
+type Claim struct {
+	task *Task
+	lock *lock.Lock
+}
+
+// claimPath returns the path of the claim lock of a task
+func claimPath(id string) string {
+	return filepath.Join(lock.Dir(DataDirectory), "tasks", id+".lock")
+}
+
+// Claim takes the claim lock of the task and checks in the store that it may
+// still be started: it must be pending, or running without a process when
+// `run --bg` handed it over to a detached executor. If the task has moved
+// on, t is updated from the store and ErrNotPending is returned.
+func (t *Task) Claim(store Store) (*Claim, error) {
+	l, err := lock.TryAcquire(claimPath(t.ID))
+	if err != nil {
+		if errors.Is(err, lock.ErrLocked) {
+			return nil, ErrClaimed
+		}
+		return nil, fmt.Errorf("failed to claim task: %w", err)
+	}
+
+	current, err := store.LoadTask(t.ID)
+	if err != nil {
+		l.Release()
+		return nil, err
+	}
+
+	startable := current.Status == StatusPending || (current.Status == StatusRunning && current.PID == nil)
+	if !startable {
+		l.Release()
+		*t = *current
+		return nil, fmt.Errorf("%w: status is %s", ErrNotPending, current.Status)
+	}
+
+	return &Claim{task: t, lock: l}, nil
+}
+
+// Hold takes the claim lock of a task to change it while no executor runs it,
+// and returns the task as reloaded from the store. ErrClaimed is returned
+// when an executor holds the claim.
+func Hold(store Store, id string) (*Task, *Claim, error) {
+	l, err := lock.TryAcquire(claimPath(id))
+	if err != nil {
+		if errors.Is(err, lock.ErrLocked) {
+			return nil, nil, ErrClaimed
+		}
+		return nil, nil, fmt.Errorf("failed to claim task: %w", err)
+	}
+
+	current, err := store.LoadTask(id)
+	if err != nil {
+		l.Release()
+		return nil, nil, err
+	}
+
+	return current, &Claim{task: current, lock: l}, nil
+}
+
+// Release gives up the claim
+func (c *Claim) Release() error {
+	return releaseClaim(c.task, c.lock)
+}
+
+// releaseClaim releases a claim lock. Once the task has finished for good
+// its lock file is removed as well; a later Claim sees the final status and
+// fails. Removing the file before unlocking it is safe, whoever was waiting
+// for the lock notices and locks a new file.
+func releaseClaim(t *Task, l *lock.Lock) error {
+	if t.Status.IsTerminal() {
+		os.Remove(claimPath(t.ID))
+	}
+
+	return l.Release()
+}
+
+// Claimed reports whether an executor currently holds the claim of a task
+func Claimed(id string) bool {
+	// Only an executor creates the lock file, checking must not leave one behind
+	if _, err := os.Stat(claimPath(id)); err != nil {
+		return false
+	}
+
+	l, err := lock.TryAcquire(claimPath(id))
+	if err != nil {
+		return errors.Is(err, lock.ErrLocked)
+	}
+	l.Release()
+
+	return false
+}
+
+// waitForProcess waits until the executor holding the claim of the task has
+// recorded the PID of the process or finished, and updates t from disk
+func (t *Task) waitForProcess() {
+	for i := 0; i < 50; i++ {
+		if current, err := LoadTask(t.ID); err == nil {
+			*t = *current
+			if t.PID != nil || t.Status.IsTerminal() {
+				return
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
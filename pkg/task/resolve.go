@@ -0,0 +1,137 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrAmbiguous is returned by Resolve for an ID prefix shared by several tasks
+var ErrAmbiguous = errors.New("ambiguous task ID prefix")
+
+// Pseudo-names that Resolve accepts in place of a task ID
+const (
+	// RefLatest is the task created last
+	RefLatest = "latest"
+	// RefLastFailed is the newest task that failed, timed out or was lost
+	RefLastFailed = "last-failed"
+)
+
+// MinPrefixLength is the shortest ID prefix Resolve looks up, as in git
+const MinPrefixLength = 4
+
+// maxCandidates is the number of matching tasks an ambiguity error lists
+const maxCandidates = 10
+
+// namePattern are the task names users may choose. Names start with a
+// letter so that they read differently from IDs.
+var namePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]{0,63}$`)
+
+// prefixPattern matches names that could also be read as an ID prefix
+var prefixPattern = regexp.MustCompile(`^[0-9A-Fa-f-]+$`)
+
+// ValidateName checks that a task name is usable
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid task name %q (use up to 64 letters, digits, '.', '-' or '_', starting with a letter)", name)
+	}
+
+	if name == RefLatest || name == RefLastFailed {
+		return fmt.Errorf("task name %q is reserved", name)
+	}
+
+	// Names are looked up before ID prefixes and would hide the tasks they match
+	if len(name) >= MinPrefixLength && prefixPattern.MatchString(name) {
+		return fmt.Errorf("task name %q could be mistaken for an ID prefix, use a letter other than a-f", name)
+	}
+
+	return nil
+}
+
+// Resolve returns the task a reference given on the command line stands
+// for. In this order a reference is a full task ID, one of the pseudo-names
+// latest and last-failed, a task name, of which the newest task wins, or
+// a unique prefix of an ID at least MinPrefixLength long.
+func Resolve(store Store, ref string) (*Task, error) {
+	t, err := store.LoadTask(ref)
+	if err == nil {
+		return t, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	tasks, err := store.ListTasks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	var match *Task
+	switch ref {
+	case RefLatest:
+		match = newest(tasks, func(t *Task) bool { return true })
+	case RefLastFailed:
+		match = newest(tasks, func(t *Task) bool {
+			return t.Status == StatusFailed || t.Status == StatusTimedOut || t.Status == StatusLost
+		})
+	default:
+		match = newest(tasks, func(t *Task) bool { return t.Name == ref })
+	}
+	if match != nil {
+		return match, nil
+	}
+
+	if len(ref) < MinPrefixLength {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+	}
+
+	prefix := strings.ToLower(ref)
+	candidates := make([]*Task, 0)
+	for _, t := range tasks {
+		if strings.HasPrefix(t.ID, prefix) {
+			candidates = append(candidates, t)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+	case 1:
+		return candidates[0], nil
+	default:
+		return nil, ambiguous(ref, candidates)
+	}
+}
+
+// newest returns the task created last among those selected, or nil
+func newest(tasks []*Task, selected func(*Task) bool) *Task {
+	var found *Task
+	for _, t := range tasks {
+		if selected(t) && (found == nil || t.CreatedAt.After(found.CreatedAt)) {
+			found = t
+		}
+	}
+
+	return found
+}
+
+// ambiguous builds the error for a prefix matching several tasks, listing
+// the newest of them
+func ambiguous(prefix string, candidates []*Task) error {
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].CreatedAt.After(candidates[j].CreatedAt)
+	})
+
+	var b strings.Builder
+	for i, t := range candidates {
+		if i == maxCandidates {
+			fmt.Fprintf(&b, "\n  ... and %d more", len(candidates)-maxCandidates)
+			break
+		}
+		fmt.Fprintf(&b, "\n  %s  %s  %s", t.ID, t.Status, t.Command)
+	}
+
+	return fmt.Errorf("%w %q matches %d tasks:%s", ErrAmbiguous, prefix, len(candidates), b.String())
+}
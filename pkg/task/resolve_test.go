@@ -0,0 +1,164 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mapStore is a minimal Store for tests
+type mapStore map[string]*Task
+
+func (s mapStore) SaveTask(t *Task) error {
+	s[t.ID] = t
+	return nil
+}
+
+func (s mapStore) LoadTask(id string) (*Task, error) {
+	if t, ok := s[id]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+func (s mapStore) ListTasks() ([]*Task, error) {
+	tasks := make([]*Task, 0, len(s))
+	for _, t := range s {
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func (s mapStore) DeleteTask(id string) error {
+	delete(s, id)
+	return nil
+}
+
+func TestResolve(t *testing.T) {
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	store := mapStore{}
+	for i, spec := range []struct {
+		id     string
+		status TaskStatus
+		name   string
+	}{
+		{"3f9a1111-0000-0000-0000-000000000000", StatusFailed, "backup"},
+		{"3f9a2222-0000-0000-0000-000000000000", StatusCompleted, "backup"},
+		{"7c00aaaa-0000-0000-0000-000000000000", StatusTimedOut, ""},
+		{"beef0000-0000-0000-0000-000000000000", StatusCancelled, ""},
+		{"d00d0000-0000-0000-0000-000000000000", StatusRunning, ""},
+		{"e5e50000-0000-0000-0000-000000000000", StatusPending, "latest-report"},
+	} {
+		store[spec.id] = &Task{
+			ID:        spec.id,
+			Status:    spec.status,
+			Name:      spec.name,
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}
+	}
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"7c00aaaa-0000-0000-0000-000000000000", "7c00aaaa-0000-0000-0000-000000000000"},
+		{RefLatest, "e5e50000-0000-0000-0000-000000000000"},
+		// Cancelled tasks did not fail
+		{RefLastFailed, "7c00aaaa-0000-0000-0000-000000000000"},
+		// The newest task with the name wins
+		{"backup", "3f9a2222-0000-0000-0000-000000000000"},
+		{"latest-report", "e5e50000-0000-0000-0000-000000000000"},
+		{"7c00", "7c00aaaa-0000-0000-0000-000000000000"},
+		{"3F9A1", "3f9a1111-0000-0000-0000-000000000000"},
+		{"beef0000", "beef0000-0000-0000-0000-000000000000"},
+	}
+
+	for _, tt := range tests {
+		got, err := Resolve(store, tt.ref)
+		if err != nil {
+			t.Errorf("Resolve(%q) failed: %v", tt.ref, err)
+			continue
+		}
+		if got.ID != tt.want {
+			t.Errorf("Resolve(%q) = %s, want %s", tt.ref, got.ID, tt.want)
+		}
+	}
+}
+
+func TestResolveNameShadowsPrefix(t *testing.T) {
+	// ValidateName keeps such names from being given, but names are looked up first
+	store := mapStore{
+		"beef0000-0000-0000-0000-000000000000": {ID: "beef0000-0000-0000-0000-000000000000"},
+		"1234abcd-0000-0000-0000-000000000000": {ID: "1234abcd-0000-0000-0000-000000000000", Name: "beef"},
+	}
+
+	got, err := Resolve(store, "beef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "beef" {
+		t.Errorf("Resolve(%q) = %s, want the task named beef", "beef", got.ID)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	store := mapStore{}
+	for i := 0; i < maxCandidates+2; i++ {
+		id := fmt.Sprintf("abcd%04d-0000-0000-0000-000000000000", i)
+		store[id] = &Task{ID: id, Status: StatusCompleted, Command: "true", CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+	}
+
+	tests := []struct {
+		ref     string
+		wantErr error
+	}{
+		{"abcd", ErrAmbiguous},
+		{"abcd00", ErrAmbiguous},
+		// Too short to be looked up as a prefix
+		{"abc", ErrNotFound},
+		{"ffff", ErrNotFound},
+		{"no-such-name", ErrNotFound},
+		// No task failed
+		{RefLastFailed, ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		if got, err := Resolve(store, tt.ref); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Resolve(%q) = %v, %v, want %v", tt.ref, got, err, tt.wantErr)
+		}
+	}
+
+	// Ambiguity errors list the newest candidates and count the rest
+	_, err := Resolve(store, "abcd")
+	msg := err.Error()
+	newest := fmt.Sprintf("abcd%04d", maxCandidates+1)
+	if !strings.Contains(msg, `"abcd" matches 12 tasks`) || !strings.Contains(msg, newest) || !strings.Contains(msg, "... and 2 more") {
+		t.Errorf("ambiguity error does not list the candidates:\n%s", msg)
+	}
+	if strings.Contains(msg, "abcd0000-") {
+		t.Errorf("ambiguity error lists the oldest task:\n%s", msg)
+	}
+
+	if _, err := Resolve(mapStore{}, RefLatest); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve(latest) of an empty store error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	valid := []string{"backup", "nightly-backup", "build.v2", "a", "Deploy_Prod", "bee", "beefy", strings.Repeat("x", 64)}
+	for _, name := range valid {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q) failed: %v", name, err)
+		}
+	}
+
+	invalid := []string{"", "1backup", "-x", "build all", "x/y", strings.Repeat("x", 65), RefLatest, RefLastFailed, "beef", "cafe", "Dead-Beef", "abcd1234"}
+	for _, name := range invalid {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("ValidateName(%q) succeeded, want an error", name)
+		}
+	}
+}
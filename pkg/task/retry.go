@@ -0,0 +1,130 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backoff policies for the delay between retries
+const (
+	BackoffFixed       = "fixed"
+	BackoffExponential = "exponential"
+)
+
+// DefaultRetryDelay is the delay before a retry when none is given
+const DefaultRetryDelay = 10 * time.Second
+
+// maxRetryDelay bounds exponential backoff so the delay cannot overflow
+const maxRetryDelay = 24 * time.Hour
+
+// RetryPolicy describes when and how often a failed task is run again
+type RetryPolicy struct {
+	MaxRetries int           `json:"max_retries"`
+	Backoff    string        `json:"backoff"`
+	Delay      time.Duration `json:"delay"`
+	// OnExitCodes limits retries to these exit codes, empty means any failure
+	OnExitCodes []int `json:"on_exit_codes,omitempty"`
+}
+
+// Attempt records the outcome of a single run of a task
+type Attempt struct {
+	Number     int        `json:"number"`
+	Status     TaskStatus `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt time.Time  `json:"finished_at"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Signal     string     `json:"signal,omitempty"`
+	StdoutLog  string     `json:"stdout_log"`
+	StderrLog  string     `json:"stderr_log"`
+}
+
+// ParseBackoff validates a backoff policy name
+func ParseBackoff(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case BackoffFixed:
+		return BackoffFixed, nil
+	case BackoffExponential:
+		return BackoffExponential, nil
+	default:
+		return "", fmt.Errorf("invalid backoff: %s (must be fixed or exponential)", value)
+	}
+}
+
+// ParseExitCodes parses a comma separated list of exit codes such as "1,75"
+func ParseExitCodes(value string) ([]int, error) {
+	codes := make([]int, 0)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		code, err := strconv.Atoi(part)
+		if err != nil || code < 0 || code > 255 {
+			return nil, fmt.Errorf("invalid exit code %q", part)
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// ShouldRetry reports whether a task that ended with the given status and
+// exit code after the given attempt is run again
+func (p *RetryPolicy) ShouldRetry(attempt int, status TaskStatus, exitCode *int) bool {
+	if attempt > p.MaxRetries {
+		return false
+	}
+
+	switch status {
+	case StatusFailed:
+	case StatusTimedOut:
+		// A timeout has no meaningful exit code to match against
+		return len(p.OnExitCodes) == 0
+	default:
+		return false
+	}
+
+	if len(p.OnExitCodes) == 0 {
+		return true
+	}
+
+	if exitCode == nil {
+		return false
+	}
+	for _, code := range p.OnExitCodes {
+		if code == *exitCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextDelay returns how long to wait before the attempt following the given one
+func (p *RetryPolicy) NextDelay(attempt int) time.Duration {
+	if p.Backoff != BackoffExponential {
+		return p.Delay
+	}
+
+	delay := p.Delay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+
+	return delay
+}
+
+// Attempt returns the number of the current or most recent attempt
+func (t *Task) Attempt() int {
+	if t.Status == StatusPending || t.Status == StatusRunning {
+		return len(t.Attempts) + 1
+	}
+
+	return len(t.Attempts)
+}
@@ -0,0 +1,99 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShouldRetry(t *testing.T) {
+	code := func(c int) *int { return &c }
+
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		attempt  int
+		status   TaskStatus
+		exitCode *int
+		want     bool
+	}{
+		{name: "failure", policy: RetryPolicy{MaxRetries: 2}, attempt: 1, status: StatusFailed, exitCode: code(1), want: true},
+		{name: "last retry", policy: RetryPolicy{MaxRetries: 2}, attempt: 2, status: StatusFailed, exitCode: code(1), want: true},
+		{name: "retries used up", policy: RetryPolicy{MaxRetries: 2}, attempt: 3, status: StatusFailed, exitCode: code(1), want: false},
+		{name: "no retries", policy: RetryPolicy{}, attempt: 1, status: StatusFailed, exitCode: code(1), want: false},
+		{name: "success", policy: RetryPolicy{MaxRetries: 2}, attempt: 1, status: StatusCompleted, exitCode: code(0), want: false},
+		{name: "cancelled", policy: RetryPolicy{MaxRetries: 2}, attempt: 1, status: StatusCancelled, want: false},
+		{name: "lost", policy: RetryPolicy{MaxRetries: 2}, attempt: 1, status: StatusLost, want: false},
+		{name: "timeout", policy: RetryPolicy{MaxRetries: 2}, attempt: 1, status: StatusTimedOut, want: true},
+		{name: "timeout with exit codes", policy: RetryPolicy{MaxRetries: 2, OnExitCodes: []int{75}}, attempt: 1, status: StatusTimedOut, want: false},
+		{name: "matching exit code", policy: RetryPolicy{MaxRetries: 2, OnExitCodes: []int{1, 75}}, attempt: 1, status: StatusFailed, exitCode: code(75), want: true},
+		{name: "other exit code", policy: RetryPolicy{MaxRetries: 2, OnExitCodes: []int{1, 75}}, attempt: 1, status: StatusFailed, exitCode: code(2), want: false},
+		{name: "no exit code", policy: RetryPolicy{MaxRetries: 2, OnExitCodes: []int{1}}, attempt: 1, status: StatusFailed, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.ShouldRetry(tt.attempt, tt.status, tt.exitCode); got != tt.want {
+				t.Errorf("ShouldRetry(%d, %s) = %v, want %v", tt.attempt, tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{name: "fixed first", policy: RetryPolicy{Backoff: BackoffFixed, Delay: 5 * time.Second}, attempt: 1, want: 5 * time.Second},
+		{name: "fixed later", policy: RetryPolicy{Backoff: BackoffFixed, Delay: 5 * time.Second}, attempt: 4, want: 5 * time.Second},
+		{name: "no backoff is fixed", policy: RetryPolicy{Delay: time.Second}, attempt: 3, want: time.Second},
+		{name: "exponential first", policy: RetryPolicy{Backoff: BackoffExponential, Delay: 10 * time.Second}, attempt: 1, want: 10 * time.Second},
+		{name: "exponential second", policy: RetryPolicy{Backoff: BackoffExponential, Delay: 10 * time.Second}, attempt: 2, want: 20 * time.Second},
+		{name: "exponential fourth", policy: RetryPolicy{Backoff: BackoffExponential, Delay: 10 * time.Second}, attempt: 4, want: 80 * time.Second},
+		{name: "exponential capped", policy: RetryPolicy{Backoff: BackoffExponential, Delay: time.Hour}, attempt: 10, want: maxRetryDelay},
+		{name: "no overflow", policy: RetryPolicy{Backoff: BackoffExponential, Delay: time.Second}, attempt: 1000, want: maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.NextDelay(tt.attempt); got != tt.want {
+				t.Errorf("NextDelay(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExitCodes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []int
+		wantErr bool
+	}{
+		{value: "1", want: []int{1}},
+		{value: "1, 75,", want: []int{1, 75}},
+		{value: "", want: []int{}},
+		{value: "256", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseExitCodes(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseExitCodes(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && len(got) != len(tt.want) {
+			t.Errorf("ParseExitCodes(%q) = %v, want %v", tt.value, got, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("ParseExitCodes(%q) = %v, want %v", tt.value, got, tt.want)
+				break
+			}
+		}
+	}
+}
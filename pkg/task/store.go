@@ -0,0 +1,104 @@
+package task
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by a Store for a task that does not exist
+var ErrNotFound = errors.New("task not found")
+
+// Store persists tasks. Every field of a task must survive a save and a
+// load. The JSON files in the data directory are the default backend, see
+// package storage.
+type Store interface {
+	// SaveTask creates or replaces a task
+	SaveTask(t *Task) error
+	// LoadTask returns the task with the given ID, or an error wrapping ErrNotFound
+	LoadTask(id string) (*Task, error)
+	// ListTasks returns all tasks in no particular order
+	ListTasks() ([]*Task, error)
+	// DeleteTask removes a task
+	DeleteTask(id string) error
+}
+
+// defaultStore backs Save, LoadTask and ListTasks
+var defaultStore Store
+
+// SetStore sets the store used by Save, LoadTask and ListTasks
+func SetStore(s Store) {
+	defaultStore = s
+}
+
+// DefaultStore returns the store set with SetStore
+func DefaultStore() Store {
+	if defaultStore == nil {
+		panic("task: no store configured, call task.SetStore first")
+	}
+
+	return defaultStore
+}
+
+// Filter selects tasks. Empty fields match every task.
+type Filter struct {
+	// Statuses matches tasks with any of the statuses
+	Statuses []TaskStatus
+	// GroupID matches the tasks of a group
+	GroupID string
+	// Since matches tasks created at or after the time
+	Since time.Time
+}
+
+// Match reports whether a task is selected by the filter
+func (f Filter) Match(t *Task) bool {
+	if len(f.Statuses) > 0 {
+		found := false
+		for _, status := range f.Statuses {
+			if t.Status == status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.GroupID != "" && (t.GroupID == nil || *t.GroupID != f.GroupID) {
+		return false
+	}
+
+	if !f.Since.IsZero() && t.CreatedAt.Before(f.Since) {
+		return false
+	}
+
+	return true
+}
+
+// Querier is implemented by stores that can select tasks through an index
+// instead of loading every task
+type Querier interface {
+	FindTasks(f Filter) ([]*Task, error)
+}
+
+// FindTasks returns the tasks of the store selected by the filter, in no
+// particular order. Stores without indexes are scanned.
+func FindTasks(store Store, f Filter) ([]*Task, error) {
+	if q, ok := store.(Querier); ok {
+		return q.FindTasks(f)
+	}
+
+	tasks, err := store.ListTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]*Task, 0)
+	for _, t := range tasks {
+		if f.Match(t) {
+			selected = append(selected, t)
+		}
+	}
+
+	return selected, nil
+}
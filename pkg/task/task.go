@@ -1,12 +1,15 @@
 package task
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"syscall"
 	"time"
 
+	"github.com/Can/sysrow/pkg/lock"
+	"github.com/Can/sysrow/pkg/proc"
 	"github.com/google/uuid"
 )
 
@@ -19,8 +22,35 @@ const (
 	StatusCompleted TaskStatus = "completed"
 	StatusFailed    TaskStatus = "failed"
 	StatusCancelled TaskStatus = "cancelled"
+	// StatusLost marks a task that was running when its process vanished
+	// without its result being recorded, e.g. because sysrow itself crashed
+	StatusLost TaskStatus = "lost"
+	// StatusTimedOut marks a task that was terminated because it exceeded its timeout
+	StatusTimedOut TaskStatus = "timed_out"
+	// StatusBlocked marks a task that waits for the tasks it depends on
+	StatusBlocked TaskStatus = "blocked"
+	// StatusSkipped marks a task that was not run, e.g. because a task it
+	// depends on did not end the way it required
+	StatusSkipped TaskStatus = "skipped"
 )
 
+// Dependency conditions decide how the tasks a task depends on must end
+const (
+	ConditionSuccess    = "success"
+	ConditionFailure    = "failure"
+	ConditionCompletion = "completion"
+)
+
+// IsTerminal reports whether a task with this status will not run again
+func (s TaskStatus) IsTerminal() bool {
+	switch s {
+	case StatusCompleted, StatusFailed, StatusCancelled, StatusLost, StatusTimedOut, StatusSkipped:
+		return true
+	}
+
+	return false
+}
+
 // TaskPriority represents the priority level of a task
 type TaskPriority string
 
@@ -32,17 +62,36 @@ const (
 
 // Task represents a command to be executed
 type Task struct {
-	ID          string       `json:"id"`
-	Command     string       `json:"command"`
-	Status      TaskStatus   `json:"status"`
-	Priority    TaskPriority `json:"priority"`
-	CreatedAt   time.Time    `json:"created_at"`
-	ScheduledAt *time.Time   `json:"scheduled_at,omitempty"`
-	StartedAt   *time.Time   `json:"started_at,omitempty"`
-	FinishedAt  *time.Time   `json:"finished_at,omitempty"`
-	ExitCode    *int         `json:"exit_code,omitempty"`
-	PID         *int         `json:"pid,omitempty"`
-	GroupID     *string      `json:"group_id,omitempty"`
+	ID          string            `json:"id"`
+	Command     string            `json:"command"`
+	Status      TaskStatus        `json:"status"`
+	Priority    TaskPriority      `json:"priority"`
+	CreatedAt   time.Time         `json:"created_at"`
+	ScheduledAt *time.Time        `json:"scheduled_at,omitempty"`
+	StartedAt   *time.Time        `json:"started_at,omitempty"`
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
+	ExitCode    *int              `json:"exit_code,omitempty"`
+	PID         *int              `json:"pid,omitempty"`
+	PIDStart    *uint64           `json:"pid_start_time,omitempty"`
+	GroupID     *string           `json:"group_id,omitempty"`
+	RunID       *string           `json:"run_id,omitempty"`
+	Timeout     time.Duration     `json:"timeout,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	Retry       *RetryPolicy      `json:"retry,omitempty"`
+	Attempts    []Attempt         `json:"attempts,omitempty"`
+	DependsOn   []string          `json:"depends_on,omitempty"`
+	Condition   string            `json:"condition,omitempty"`
+	Slot        *int              `json:"slot,omitempty"`
+	RecurringID *string           `json:"recurring_id,omitempty"`
+	Signal      string            `json:"signal,omitempty"`
+	// Manual tasks are run by `sysrow run` itself and never dispatched by the daemon
+	Manual bool `json:"manual,omitempty"`
+	// Queue is the name of the queue the daemon takes the task from, empty for the default queue
+	Queue string `json:"queue,omitempty"`
+	// Name is an optional name given by the user that can be used in place of the ID
+	Name string `json:"name,omitempty"`
+	// SchemaVersion is the schema version the record was written with
+	SchemaVersion int `json:"schema_version"`
 }
 
 // DataDirectory is the path where all task data is stored
@@ -66,7 +115,9 @@ func InitializeDataDirectory() error {
 	dirs := []string{
 		filepath.Join(DataDirectory, "tasks"),
 		filepath.Join(DataDirectory, "groups"),
+		filepath.Join(DataDirectory, "runs"),
 		filepath.Join(DataDirectory, "logs"),
+		filepath.Join(DataDirectory, "recurring"),
 	}
 
 	for _, dir := range dirs {
@@ -91,94 +142,160 @@ func NewTask(command string, priority TaskPriority) *Task {
 	}
 }
 
-// Save persists the task to disk
+// Save persists the task in the default store
 func (t *Task) Save() error {
-	taskPath := filepath.Join(DataDirectory, "tasks", t.ID+".json")
-
-	// Marshal the task to JSON
-	taskData, err := json.MarshalIndent(t, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal task: %w", err)
-	}
-
-	// Write the task data to file
-	if err := os.WriteFile(taskPath, taskData, 0644); err != nil {
-		return fmt.Errorf("failed to write task file: %w", err)
-	}
-
-	return nil
+	return DefaultStore().SaveTask(t)
 }
 
-// LoadTask loads a task from disk by its ID
+// LoadTask loads a task from the default store by its ID
 func LoadTask(id string) (*Task, error) {
-	taskPath := filepath.Join(DataDirectory, "tasks", id+".json")
+	return DefaultStore().LoadTask(id)
+}
 
-	// Read the task file
-	taskData, err := os.ReadFile(taskPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read task file: %w", err)
+// ListTasks returns all tasks of the default store
+func ListTasks() ([]*Task, error) {
+	return DefaultStore().ListTasks()
+}
+
+// Cancel cancels a pending, blocked or running task. A running task's whole
+// process group receives sig; if it is still alive after grace it is killed
+// with SIGKILL. The signal that ended the task is recorded on the task.
+func (t *Task) Cancel(sig syscall.Signal, grace time.Duration) error {
+	if t.Status != StatusRunning || t.PID == nil {
+		l, err := lock.TryAcquire(claimPath(t.ID))
+		switch {
+		case err == nil:
+			// No executor can start the task while its claim lock is held,
+			// and the daemon does not unblock it while the data lock is held
+			defer func() { releaseClaim(t, l) }()
+			dataLock, err := lock.DataDir(DataDirectory)
+			if err != nil {
+				return err
+			}
+			defer dataLock.Release()
+
+			if current, err := LoadTask(t.ID); err == nil {
+				*t = *current
+			}
+		case errors.Is(err, lock.ErrLocked):
+			// An executor is starting the task, cancel its process once it is known
+			t.waitForProcess()
+		default:
+			return fmt.Errorf("failed to lock task: %w", err)
+		}
+	} else {
+		// The task may have finished since it was read
+		current, err := LoadTask(t.ID)
+		if err != nil {
+			return err
+		}
+		*t = *current
 	}
 
-	// Unmarshal the task data
-	var task Task
-	if err := json.Unmarshal(taskData, &task); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+	// Only tasks that have not finished yet can be cancelled
+	if t.Status != StatusPending && t.Status != StatusBlocked && t.Status != StatusRunning {
+		return fmt.Errorf("cannot cancel task with status %s", t.Status)
 	}
 
-	return &task, nil
-}
+	// A process that reused the PID of the task must not be signalled, the
+	// task's own process is gone
+	running := t.Status == StatusRunning && t.PID != nil && t.ownsProcess()
 
-// ListTasks returns all tasks
-func ListTasks() ([]*Task, error) {
-	tasksDir := filepath.Join(DataDirectory, "tasks")
+	// Update the task status. For a running task this is saved before the
+	// process is signalled so the runner keeps the cancelled status.
+	t.Status = StatusCancelled
+	if !running {
+		now := time.Now()
+		t.FinishedAt = &now
+		t.PID = nil
+		t.PIDStart = nil
+		return t.Save()
+	}
 
-	// Read the tasks directory
-	files, err := os.ReadDir(tasksDir)
+	if err := t.Save(); err != nil {
+		return err
+	}
+
+	// If the task is running, we need to kill the process
+	final, err := proc.TerminateGroup(*t.PID, t.PIDStart, sig, grace)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read tasks directory: %w", err)
+		return fmt.Errorf("failed to terminate process %d: %w", *t.PID, err)
 	}
 
-	// Load each task
-	tasks := make([]*Task, 0, len(files))
-	for _, file := range files {
-		if filepath.Ext(file.Name()) != ".json" {
-			continue
+	// The runner records the final state when the process exits. If it does
+	// not do so shortly (e.g. the runner itself is gone) record it here.
+	for i := 0; i < 10; i++ {
+		if current, err := LoadTask(t.ID); err == nil && current.FinishedAt != nil {
+			*t = *current
+			return nil
 		}
+		time.Sleep(100 * time.Millisecond)
+	}
 
-		// Extract the task ID from the filename
-		taskID := file.Name()[:len(file.Name())-5] // Remove .json extension
+	now := time.Now()
+	t.FinishedAt = &now
+	t.PID = nil
+	t.PIDStart = nil
+	t.Signal = proc.SignalName(final)
 
-		// Load the task
-		task, err := LoadTask(taskID)
-		if err != nil {
-			// Log the error but continue loading other tasks
-			fmt.Fprintf(os.Stderr, "Error loading task %s: %v\n", taskID, err)
-			continue
-		}
+	// Save the updated task
+	return t.Save()
+}
 
-		tasks = append(tasks, task)
+// ownsProcess reports whether the recorded process of a running task is
+// still the one that was started for it. Without a recorded start time, or
+// once the process is gone, there is nothing to tell them apart by.
+func (t *Task) ownsProcess() bool {
+	if t.PIDStart == nil {
+		return true
 	}
 
-	return tasks, nil
+	startTime, err := proc.StartTime(*t.PID)
+	return err != nil || startTime == *t.PIDStart
 }
 
-// Cancel marks a task as cancelled
-func (t *Task) Cancel() error {
-	// Only pending or running tasks can be cancelled
-	if t.Status != StatusPending && t.Status != StatusRunning {
-		return fmt.Errorf("cannot cancel task with status %s", t.Status)
+// ParseCondition validates a dependency condition
+func ParseCondition(value string) (string, error) {
+	switch value {
+	case ConditionSuccess, ConditionFailure, ConditionCompletion:
+		return value, nil
 	}
 
-	// If the task is running, we need to kill the process
-	if t.Status == StatusRunning && t.PID != nil {
-		// TODO: Implement process killing
+	return "", fmt.Errorf("invalid condition %q (expected success, failure or completion)", value)
+}
+
+// ParseStatus converts a status name into a TaskStatus
+func ParseStatus(value string) (TaskStatus, error) {
+	switch TaskStatus(value) {
+	case StatusPending, StatusRunning, StatusCompleted, StatusFailed, StatusCancelled,
+		StatusLost, StatusTimedOut, StatusBlocked, StatusSkipped:
+		return TaskStatus(value), nil
 	}
 
-	// Update the task status
-	t.Status = StatusCancelled
-	now := time.Now()
-	t.FinishedAt = &now
+	return "", fmt.Errorf("invalid status %q", value)
+}
 
-	// Save the updated task
-	return t.Save()
+// ParsePriority converts a priority name into a TaskPriority
+func ParsePriority(value string) (TaskPriority, error) {
+	switch TaskPriority(value) {
+	case PriorityLow, PriorityNormal, PriorityHigh:
+		return TaskPriority(value), nil
+	}
+
+	return "", fmt.Errorf("invalid priority %q (expected low, normal or high)", value)
+}
+
+// Rank orders priorities from low to high, so that a task with a higher
+// rank runs first. Unknown priorities rank below low.
+func (p TaskPriority) Rank() int {
+	switch p {
+	case PriorityHigh:
+		return 3
+	case PriorityNormal:
+		return 2
+	case PriorityLow:
+		return 1
+	default:
+		return 0
+	}
 }
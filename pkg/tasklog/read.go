@@ -0,0 +1,493 @@
+package tasklog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+)
+
+// maxLine is the longest line returned as a whole, longer lines are split
+const maxLine = 64 * 1024
+
+// blockSize is how much of a log is read at once when searching backwards
+const blockSize = 64 * 1024
+
+// PollInterval is how often Follow checks the logs for new output
+const PollInterval = 250 * time.Millisecond
+
+// Line is a line of output of a task
+type Line struct {
+	Stream string
+	// Text is the line without its line end. It is only valid until the
+	// function it was passed to returns.
+	Text []byte
+	// Newline is false for the end of a log that has no line end (yet) and
+	// for the pieces of a line longer than 64 KiB
+	Newline bool
+}
+
+// Options select the output Read returns
+type Options struct {
+	// Tail is the number of lines to return from the end, 0 returns all
+	Tail int
+	// Since skips output written before the time. Output is timed to within
+	// SampleInterval, lines of tasks run by older versions by the time their
+	// log was last written.
+	Since time.Time
+}
+
+// Log reads the current logs of a task
+type Log struct {
+	streams []*stream
+}
+
+// stream is the log of one output stream of a task
+type stream struct {
+	name      string
+	path      string
+	timesPath string
+	// file is nil until the task has created the log
+	file   *os.File
+	reader *bufio.Reader
+	// pos is the offset in the log up to which it has been read
+	pos int64
+	// pending is the start of a line whose end has not been read yet
+	pending []byte
+}
+
+// segment is a part of a log and the time by which it had been written
+type segment struct {
+	stream     *stream
+	start, end int64
+	time       time.Time
+}
+
+// Open opens the current logs of the given streams of a task, all streams
+// if none are given. Logs the task has not created yet are picked up by Follow.
+func Open(dataDir, taskID string, streams []string) (*Log, error) {
+	if len(streams) == 0 {
+		streams = Streams
+	}
+
+	l := &Log{}
+	for _, name := range streams {
+		s := &stream{
+			name:      name,
+			path:      Path(dataDir, taskID, name),
+			timesPath: timesPath(dataDir, taskID, name),
+		}
+		if err := s.open(); err != nil {
+			l.Close()
+			return nil, err
+		}
+		l.streams = append(l.streams, s)
+	}
+
+	return l, nil
+}
+
+// Exists reports whether the task has created any of the logs
+func (l *Log) Exists() bool {
+	for _, s := range l.streams {
+		if s.file != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Close closes the logs
+func (l *Log) Close() {
+	for _, s := range l.streams {
+		if s.file != nil {
+			s.file.Close()
+			s.file = nil
+		}
+	}
+}
+
+// Read calls emit for the selected output in the order it was written.
+// With final the last line of a log is returned even if it has no line end,
+// otherwise it is left for Follow to complete.
+func (l *Log) Read(opts Options, final bool, emit func(Line) error) error {
+	var segments []segment
+	for _, s := range l.streams {
+		if s.file == nil {
+			continue
+		}
+
+		selected, err := s.position(opts)
+		if err != nil {
+			return err
+		}
+		segments = append(segments, selected...)
+	}
+
+	// Streams were added in order, so lines of the same sample keep it
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].time.Before(segments[j].time)
+	})
+
+	// The last lines of all streams are among the last lines of each
+	out := emit
+	var last *ring
+	if opts.Tail > 0 {
+		last = newRing(opts.Tail)
+		out = last.add
+	}
+
+	for _, seg := range segments {
+		s := seg.stream
+		for s.pos < seg.end {
+			line, ok, err := s.next(false)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			if err := out(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	if final {
+		for _, s := range l.streams {
+			line, ok, err := s.flush()
+			if err != nil {
+				return err
+			}
+			if ok {
+				if err := out(line); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if last != nil {
+		return last.emit(emit)
+	}
+
+	return nil
+}
+
+// Follow reads output as the task writes it until finished reports that the
+// task will not write any more, then returns the rest. The logs of a retried
+// task are read to their end before those of its next attempt.
+func (l *Log) Follow(finished func() (bool, error), emit func(Line) error) error {
+	for {
+		done, err := finished()
+		if err != nil {
+			return err
+		}
+
+		for _, s := range l.streams {
+			if err := s.poll(done, emit); err != nil {
+				return err
+			}
+		}
+
+		if done {
+			return nil
+		}
+		time.Sleep(PollInterval)
+	}
+}
+
+// open opens the log if the task has created it
+func (s *stream) open() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s log: %w", s.name, err)
+	}
+
+	s.file = f
+	s.reader = bufio.NewReaderSize(f, maxLine)
+	s.pos = 0
+	s.pending = s.pending[:0]
+
+	return nil
+}
+
+// position moves the log to the first line selected by the options and
+// returns the segments from there on
+func (s *stream) position(opts Options) ([]segment, error) {
+	info, err := s.file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s log: %w", s.name, err)
+	}
+	size := info.Size()
+
+	segments := s.segments(size, info.ModTime())
+
+	start := int64(0)
+	if !opts.Since.IsZero() {
+		start = size
+		for _, seg := range segments {
+			if !seg.time.Before(opts.Since) {
+				start = seg.start
+				break
+			}
+		}
+		// A sample may have caught a line half written
+		if start, err = s.lineStart(start); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Tail > 0 {
+		tail, err := s.tailStart(size, opts.Tail)
+		if err != nil {
+			return nil, err
+		}
+		start = max(start, tail)
+	}
+
+	if _, err := s.file.Seek(start, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read %s log: %w", s.name, err)
+	}
+	s.reader.Reset(s.file)
+	s.pos = start
+	s.pending = s.pending[:0]
+
+	selected := make([]segment, 0, len(segments))
+	for _, seg := range segments {
+		if seg.end > start {
+			selected = append(selected, seg)
+		}
+	}
+
+	return selected, nil
+}
+
+// segments splits the log up to size at the recorded samples. Output that
+// was not recorded, because the task is still running or was run by an
+// older version, is timed by the last change of the log.
+func (s *stream) segments(size int64, modTime time.Time) []segment {
+	var segments []segment
+	start := int64(0)
+
+	if f, err := os.Open(s.timesPath); err == nil {
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var end, nanos int64
+			if _, err := fmt.Sscanf(scanner.Text(), "%d %d", &end, &nanos); err != nil || end <= start {
+				continue
+			}
+			if end > size {
+				break
+			}
+			segments = append(segments, segment{stream: s, start: start, end: end, time: time.Unix(0, nanos)})
+			start = end
+		}
+	}
+
+	if start < size {
+		segments = append(segments, segment{stream: s, start: start, end: size, time: modTime})
+	}
+
+	return segments
+}
+
+// lineStart returns the offset of the start of the line containing offset
+func (s *stream) lineStart(offset int64) (int64, error) {
+	buf := make([]byte, blockSize)
+	for end := offset; end > 0; {
+		start := max(end-blockSize, 0)
+		n, err := s.file.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("failed to read %s log: %w", s.name, err)
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+
+	return 0, nil
+}
+
+// tailStart returns the offset of the first of the last n lines of the log
+func (s *stream) tailStart(size int64, n int) (int64, error) {
+	buf := make([]byte, blockSize)
+
+	// The line end of the last line does not start another line
+	end := size
+	if end > 0 {
+		if _, err := s.file.ReadAt(buf[:1], end-1); err == nil && buf[0] == '\n' {
+			end--
+		}
+	}
+
+	for end > 0 {
+		start := max(end-blockSize, 0)
+		read, err := s.file.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("failed to read %s log: %w", s.name, err)
+		}
+
+		block := buf[:read]
+		for {
+			i := bytes.LastIndexByte(block, '\n')
+			if i < 0 {
+				break
+			}
+			if n--; n == 0 {
+				return start + int64(i) + 1, nil
+			}
+			block = block[:i]
+		}
+		end = start
+	}
+
+	return 0, nil
+}
+
+// next reads the next line. ok is false if no complete line has been
+// written yet, unless final is set and the log has a last line without line
+// end, which is then returned.
+func (s *stream) next(final bool) (Line, bool, error) {
+	for {
+		chunk, err := s.reader.ReadSlice('\n')
+		s.pos += int64(len(chunk))
+
+		switch {
+		case err == nil:
+			text := chunk[:len(chunk)-1]
+			if len(s.pending) > 0 {
+				text = append(s.pending, text...)
+				s.pending = s.pending[:0]
+			}
+			return Line{Stream: s.name, Text: text, Newline: true}, true, nil
+		case err == bufio.ErrBufferFull || err == io.EOF:
+			// The chunk is only valid until the next read
+			s.pending = append(s.pending, chunk...)
+			if len(s.pending) >= maxLine || (err == io.EOF && final) {
+				return s.flush()
+			}
+			if err == io.EOF {
+				return Line{}, false, nil
+			}
+		default:
+			return Line{}, false, fmt.Errorf("failed to read %s log: %w", s.name, err)
+		}
+	}
+}
+
+// flush returns the start of a line whose end has not been written, if any
+func (s *stream) flush() (Line, bool, error) {
+	if len(s.pending) == 0 {
+		return Line{}, false, nil
+	}
+
+	text := s.pending
+	s.pending = s.pending[:0]
+	return Line{Stream: s.name, Text: text}, true, nil
+}
+
+// poll emits the lines written since the last poll. When a retried task
+// has renamed the log, the rest of it is read and the log of the next
+// attempt is opened.
+func (s *stream) poll(final bool, emit func(Line) error) error {
+	if s.file == nil {
+		if err := s.open(); err != nil || s.file == nil {
+			return err
+		}
+	}
+
+	if err := s.drain(final, emit); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(s.path)
+	current, currentErr := s.file.Stat()
+	if currentErr != nil || (err == nil && os.SameFile(info, current)) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read %s log: %w", s.name, err)
+	}
+
+	// The attempt has finished, so the renamed log is complete
+	if err := s.drain(true, emit); err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = nil
+
+	if err := s.open(); err != nil || s.file == nil {
+		return err
+	}
+	return s.drain(final, emit)
+}
+
+// drain emits all complete lines that can be read
+func (s *stream) drain(final bool, emit func(Line) error) error {
+	for {
+		line, ok, err := s.next(final)
+		if err != nil || !ok {
+			return err
+		}
+		if err := emit(line); err != nil {
+			return err
+		}
+	}
+}
+
+// ring keeps the last lines passed to add. It grows up to its size as lines
+// come in, a large --tail on a short log costs no more than the log.
+type ring struct {
+	lines []Line
+	size  int
+	// next is the oldest line, replaced by the next add once the ring is full
+	next int
+}
+
+// newRing returns a ring keeping n lines
+func newRing(n int) *ring {
+	return &ring{size: n}
+}
+
+// add keeps a copy of the line, replacing the oldest one when full
+func (r *ring) add(line Line) error {
+	if len(r.lines) < r.size {
+		line.Text = append([]byte(nil), line.Text...)
+		r.lines = append(r.lines, line)
+		return nil
+	}
+
+	line.Text = append(r.lines[r.next].Text[:0], line.Text...)
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % r.size
+
+	return nil
+}
+
+// emit passes the kept lines to emit, oldest first
+func (r *ring) emit(emit func(Line) error) error {
+	lines := make([]Line, 0, len(r.lines))
+	lines = append(lines, r.lines[r.next:]...)
+	lines = append(lines, r.lines[:r.next]...)
+
+	for _, line := range lines {
+		if err := emit(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
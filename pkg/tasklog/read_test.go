@@ -0,0 +1,246 @@
+package tasklog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sample is a line of a times file
+type sample struct {
+	size int
+	at   int
+}
+
+// logFile is the log of a stream with its times file and modification time,
+// times are seconds after a base time
+type logFile struct {
+	content string
+	samples []sample
+	// noTimes leaves out the times file, as older versions did
+	noTimes  bool
+	modified int
+}
+
+// readAll reads the logs of a task and formats every line as
+// "<stream> <text>", marking lines without line end with a trailing "|"
+func readAll(t *testing.T, l *Log, opts Options, final bool) []string {
+	t.Helper()
+	lines := make([]string, 0)
+	err := l.Read(opts, final, func(line Line) error {
+		s := line.Stream + " " + string(line.Text)
+		if !line.Newline {
+			s += "|"
+		}
+		lines = append(lines, s)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	return lines
+}
+
+func TestRead(t *testing.T) {
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return base.Add(time.Duration(seconds) * time.Second) }
+
+	// Output written in turns: o1 e1 o2 e2 o3
+	interleaved := map[string]logFile{
+		Stdout: {content: "o1\no2\no3\n", samples: []sample{{3, 1}, {6, 3}, {9, 5}}, modified: 5},
+		Stderr: {content: "e1\ne2\n", samples: []sample{{3, 2}, {6, 4}}, modified: 4},
+	}
+	long := strings.Repeat("x", maxLine+10)
+
+	tests := []struct {
+		name    string
+		logs    map[string]logFile
+		streams []string
+		opts    Options
+		final   bool
+		want    []string
+	}{
+		{
+			name: "merged in the order written",
+			logs: interleaved,
+			want: []string{"stdout o1", "stderr e1", "stdout o2", "stderr e2", "stdout o3"},
+		},
+		{
+			name:    "one stream",
+			logs:    interleaved,
+			streams: []string{Stderr},
+			want:    []string{"stderr e1", "stderr e2"},
+		},
+		{
+			name: "tail of the merged output",
+			logs: interleaved,
+			opts: Options{Tail: 2},
+			want: []string{"stderr e2", "stdout o3"},
+		},
+		{
+			name: "tail longer than the output",
+			logs: interleaved,
+			opts: Options{Tail: 100},
+			want: []string{"stdout o1", "stderr e1", "stdout o2", "stderr e2", "stdout o3"},
+		},
+		{
+			name: "since",
+			logs: interleaved,
+			opts: Options{Since: at(3)},
+			want: []string{"stdout o2", "stderr e2", "stdout o3"},
+		},
+		{
+			name: "since and tail",
+			logs: interleaved,
+			opts: Options{Since: at(3), Tail: 2},
+			want: []string{"stderr e2", "stdout o3"},
+		},
+		{
+			name: "since after all output",
+			logs: interleaved,
+			opts: Options{Since: at(10)},
+			want: []string{},
+		},
+		{
+			name: "since a sample taken in the middle of a line",
+			logs: map[string]logFile{
+				Stdout: {content: "o1\no2\n", samples: []sample{{4, 1}}, modified: 3},
+			},
+			opts: Options{Since: at(2)},
+			want: []string{"stdout o2"},
+		},
+		{
+			name: "output after the last sample is timed by the log",
+			logs: map[string]logFile{
+				Stdout: {content: "o1\no2\n", samples: []sample{{3, 1}}, modified: 4},
+				Stderr: {content: "e1\n", samples: []sample{{3, 2}}, modified: 2},
+			},
+			want: []string{"stdout o1", "stderr e1", "stdout o2"},
+		},
+		{
+			name: "logs without times files",
+			logs: map[string]logFile{
+				Stdout: {content: "o1\no2\n", noTimes: true, modified: 2},
+				Stderr: {content: "e1\n", noTimes: true, modified: 1},
+			},
+			want: []string{"stderr e1", "stdout o1", "stdout o2"},
+		},
+		{
+			name: "last line without line end is left for follow",
+			logs: map[string]logFile{
+				Stdout: {content: "o1\npartial", noTimes: true, modified: 1},
+			},
+			want: []string{"stdout o1"},
+		},
+		{
+			name: "last line without line end when final",
+			logs: map[string]logFile{
+				Stdout: {content: "o1\npartial", noTimes: true, modified: 1},
+			},
+			final: true,
+			want:  []string{"stdout o1", "stdout partial|"},
+		},
+		{
+			name: "tail counts the last line without line end",
+			logs: map[string]logFile{
+				Stdout: {content: "o1\no2\npartial", noTimes: true, modified: 1},
+			},
+			opts:  Options{Tail: 2},
+			final: true,
+			want:  []string{"stdout o2", "stdout partial|"},
+		},
+		{
+			name: "long lines are split",
+			logs: map[string]logFile{
+				Stdout: {content: long + "\nend\n", noTimes: true, modified: 1},
+			},
+			want: []string{"stdout " + long[:maxLine] + "|", "stdout xxxxxxxxxx", "stdout end"},
+		},
+		{
+			name: "no logs yet",
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(dataDir, "logs"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			for stream, f := range tt.logs {
+				path := Path(dataDir, "t1", stream)
+				if err := os.WriteFile(path, []byte(f.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, at(f.modified), at(f.modified)); err != nil {
+					t.Fatal(err)
+				}
+				if f.noTimes {
+					continue
+				}
+
+				var times strings.Builder
+				for _, s := range f.samples {
+					fmt.Fprintf(&times, "%d %d\n", s.size, at(s.at).UnixNano())
+				}
+				if err := os.WriteFile(timesPath(dataDir, "t1", stream), []byte(times.String()), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			l, err := Open(dataDir, "t1", tt.streams)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer l.Close()
+
+			if l.Exists() != (len(tt.logs) > 0) {
+				t.Errorf("Exists() = %v with %d log(s)", l.Exists(), len(tt.logs))
+			}
+
+			if got := readAll(t, l, tt.opts, tt.final); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRing(t *testing.T) {
+	tests := []struct {
+		size  int
+		lines []string
+		want  []string
+	}{
+		{size: 3, lines: []string{}, want: []string{}},
+		{size: 3, lines: []string{"a", "b"}, want: []string{"a", "b"}},
+		{size: 3, lines: []string{"a", "b", "c"}, want: []string{"a", "b", "c"}},
+		{size: 3, lines: []string{"a", "b", "c", "d", "e"}, want: []string{"c", "d", "e"}},
+		{size: 1, lines: []string{"a", "b"}, want: []string{"b"}},
+	}
+
+	for _, tt := range tests {
+		r := newRing(tt.size)
+
+		// The text passed to add is reused, as the log reader does
+		buf := make([]byte, 0, 8)
+		for _, line := range tt.lines {
+			buf = append(buf[:0], line...)
+			r.add(Line{Text: buf})
+		}
+
+		got := make([]string, 0)
+		r.emit(func(line Line) error {
+			got = append(got, string(line.Text))
+			return nil
+		})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ring(%d) of %q = %q, want %q", tt.size, tt.lines, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package tasklog names, records and reads the stdout and stderr logs of
+// tasks. Tasks write their output straight to the log files, so while a task
+// runs a recorder notes every second how far each log has grown. These times
+// files let readers select output by time and merge stdout and stderr in the
+// order they were written, without loading whole logs into memory.
+package tasklog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Output streams of a task
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+// Streams are the output streams of a task in the order lines written at
+// the same time are shown
+var Streams = []string{Stdout, Stderr}
+
+// SampleInterval is how often the growth of the logs of a running task is recorded
+const SampleInterval = time.Second
+
+// FileName returns the file name of a task log. Attempt 0 is the current
+// attempt, earlier attempts that were retried keep their number.
+func FileName(taskID string, attempt int, stream string) string {
+	if attempt == 0 {
+		return fmt.Sprintf("%s.%s.log", taskID, stream)
+	}
+
+	return fmt.Sprintf("%s.attempt-%d.%s.log", taskID, attempt, stream)
+}
+
+// TimesFileName returns the file name of the times file of a task log
+func TimesFileName(taskID string, attempt int, stream string) string {
+	return FileName(taskID, attempt, stream+".times")
+}
+
+// Path returns the path of the current log of a stream
+func Path(dataDir, taskID, stream string) string {
+	return filepath.Join(dataDir, "logs", FileName(taskID, 0, stream))
+}
+
+// timesPath returns the path of the times file of the current log of a stream
+func timesPath(dataDir, taskID, stream string) string {
+	return filepath.Join(dataDir, "logs", TimesFileName(taskID, 0, stream))
+}
+
+// Recorder notes how far the logs of a running task have grown over time.
+// Every line of a times file holds the size of the log and the time in
+// nanoseconds since the epoch at which the log was seen to have that size.
+type Recorder struct {
+	paths []string
+	times []*os.File
+	sizes []int64
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// Record starts recording the growth of the current logs of a task until
+// Stop is called. A stream whose times file cannot be created is not recorded,
+// its output can then only be read as a whole.
+func Record(dataDir, taskID string) *Recorder {
+	r := &Recorder{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	for _, stream := range Streams {
+		f, err := os.Create(timesPath(dataDir, taskID, stream))
+		if err != nil {
+			continue
+		}
+		r.paths = append(r.paths, Path(dataDir, taskID, stream))
+		r.times = append(r.times, f)
+		r.sizes = append(r.sizes, 0)
+	}
+
+	go r.run()
+
+	return r
+}
+
+// run samples the logs until the recorder is stopped
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(SampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			r.sample(now)
+		case <-r.stop:
+			// The task has exited, record where its output ends
+			r.sample(time.Now())
+			for _, f := range r.times {
+				f.Close()
+			}
+			return
+		}
+	}
+}
+
+// sample records the size of every log that has grown since the last sample
+func (r *Recorder) sample(now time.Time) {
+	for i, path := range r.paths {
+		info, err := os.Stat(path)
+		if err != nil || info.Size() <= r.sizes[i] {
+			continue
+		}
+
+		r.sizes[i] = info.Size()
+		fmt.Fprintf(r.times[i], "%d %d\n", r.sizes[i], now.UnixNano())
+	}
+}
+
+// Stop takes a last sample and closes the times files. It must be called
+// once the task has exited and before its logs are renamed.
+func (r *Recorder) Stop() {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+}
@@ -0,0 +1,20 @@
+// Package term finds out how wide the terminal sysrow writes to is, so that
+// tables can be fitted to it.
+package term
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Width returns the number of columns of the terminal f is connected to.
+// The COLUMNS environment variable wins if it is set. It returns false if f
+// is not a terminal, e.g. when the output is piped.
+func Width(f *os.File) (int, bool) {
+	if columns, err := strconv.Atoi(strings.TrimSpace(os.Getenv("COLUMNS"))); err == nil && columns > 0 {
+		return columns, true
+	}
+
+	return terminalWidth(f)
+}
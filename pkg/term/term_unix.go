@@ -0,0 +1,28 @@
+//go:build !windows
+
+package term
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// winsize is the result of the TIOCGWINSZ ioctl
+type winsize struct {
+	rows    uint16
+	columns uint16
+	xpixel  uint16
+	ypixel  uint16
+}
+
+// terminalWidth asks the terminal driver for the window size
+func terminalWidth(f *os.File) (int, bool) {
+	var ws winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 || ws.columns == 0 {
+		return 0, false
+	}
+
+	return int(ws.columns), true
+}